@@ -0,0 +1,56 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/h4ck3rk3y/go-8/chip8/asm"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+func asmCommand(args []string) error {
+	flags := flag.NewFlagSet("asm", flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: go-8 asm [flags] source\n\n")
+		flags.PrintDefaults()
+	}
+	out := flags.String("out", "", "the ROM to write (default is the source with a .ch8 extension)")
+	sym := flags.String("sym", "", "the symbol map to write (default is the source with a .sym extension)")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return errors.New("asm needs a source file")
+	}
+	source := flags.Arg(0)
+	base := strings.TrimSuffix(source, filepath.Ext(source))
+	if *out == "" {
+		*out = base + ".ch8"
+	}
+	if *sym == "" {
+		*sym = base + ".sym"
+	}
+
+	program, err := asm.AssembleFile(source)
+	if err != nil {
+		return err
+	}
+	if program.Origin != 0x200 {
+		fmt.Fprintf(os.Stderr, "go-8: %s starts at 0x%03X but ROMs are loaded at 0x200\n", source, program.Origin)
+	}
+	if err := ioutil.WriteFile(*out, program.Code, 0644); err != nil {
+		return err
+	}
+	f, err := os.Create(*sym)
+	if err != nil {
+		return err
+	}
+	if err := program.WriteSymbols(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
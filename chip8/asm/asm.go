@@ -0,0 +1,347 @@
+// Package asm is an assembler for CHIP-8, SUPER-CHIP and XO-CHIP programs
+// written with Cowgod's mnemonics, the same ones the chip8 package's
+// disassembler lists programs in.
+//
+// A line is an optional label, an instruction or directive and an optional
+// comment starting with a semicolon:
+//
+//	loop:   LD V0, K        ; wait for a key
+//	        JP loop
+//
+// Numbers can be decimal, hex written 0x1F, $1F or #1F, or binary written
+// 0b101 or %101, and operands can add and subtract numbers, labels and
+// constants. The directives are:
+//
+//	name EQU value       define a constant
+//	ORG address          carry on assembling at address
+//	DB 1, 0x80, "text"   bytes of data
+//	DW 0x1234, label     big endian words of data
+//	INCLUDE "file"       assemble another file here
+package asm
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Error is a problem with a line of source.
+type Error struct {
+	File string
+	Line int
+	Err  string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s:%d: %s", e.File, e.Line, e.Err)
+}
+
+// Program is an assembled program.
+type Program struct {
+	Origin  uint16         // the address Code is loaded at
+	Code    []byte         // the program, ready to be loaded
+	Symbols map[string]int // the address of every label
+}
+
+// WriteSymbols writes the program's labels, one to a line with its address
+// in front, in order of address.
+func (p *Program) WriteSymbols(w io.Writer) error {
+	names := make([]string, 0, len(p.Symbols))
+	for name := range p.Symbols {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		a, b := p.Symbols[names[i]], p.Symbols[names[j]]
+		if a != b {
+			return a < b
+		}
+		return names[i] < names[j]
+	})
+	b := bufio.NewWriter(w)
+	for _, name := range names {
+		fmt.Fprintf(b, "0x%03X %s\n", p.Symbols[name], name)
+	}
+	return b.Flush()
+}
+
+// maxIncludeDepth stops files from including each other forever.
+const maxIncludeDepth = 16
+
+// statement is an instruction or directive on a line of source.
+type statement struct {
+	file     string
+	line     int
+	address  int
+	mnemonic string   // upper cased
+	operands []string // as written
+}
+
+// assembler holds what's been found out about a program between passes.
+type assembler struct {
+	statements []statement
+	labels     map[string]int
+	constants  map[string]statement // EQU statements, evaluated when used
+	address    int
+	origin     int
+	started    bool // whether anything has been put at address yet
+	evaluating map[string]bool
+}
+
+// Assemble assembles the source read from r. The name is used in errors and
+// files it includes are found relative to it.
+func Assemble(name string, r io.Reader) (*Program, error) {
+	a := &assembler{
+		labels:     map[string]int{},
+		constants:  map[string]statement{},
+		address:    0x200,
+		origin:     0x200,
+		evaluating: map[string]bool{},
+	}
+	if err := a.read(name, r, 0); err != nil {
+		return nil, err
+	}
+	return a.encode()
+}
+
+// AssembleFile assembles the source in the file at path.
+func AssembleFile(path string) (*Program, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return Assemble(path, f)
+}
+
+// read is the first pass over a file. It finds every label's address and
+// the statements that make up the program.
+func (a *assembler) read(name string, r io.Reader, depth int) error {
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		fail := func(format string, args ...interface{}) error {
+			return &Error{File: name, Line: line, Err: fmt.Sprintf(format, args...)}
+		}
+		text := stripComment(scanner.Text())
+
+		// A label is a name followed by a colon at the start of the line.
+		if colon := strings.Index(text, ":"); colon >= 0 && isName(strings.TrimSpace(text[:colon])) {
+			label := strings.TrimSpace(text[:colon])
+			if err := a.define(label); err != nil {
+				return fail("%v", err)
+			}
+			a.labels[label] = a.address
+			text = text[colon+1:]
+		}
+		fields := strings.Fields(text)
+		if len(fields) == 0 {
+			continue
+		}
+
+		// A constant is a name followed by EQU.
+		if len(fields) >= 2 && strings.ToUpper(fields[1]) == "EQU" {
+			if !isName(fields[0]) {
+				return fail("bad constant name %q", fields[0])
+			}
+			if err := a.define(fields[0]); err != nil {
+				return fail("%v", err)
+			}
+			rest := strings.TrimSpace(text[strings.Index(text, fields[0])+len(fields[0]):])
+			value := strings.TrimSpace(rest[len("EQU"):])
+			if value == "" {
+				return fail("EQU needs a value")
+			}
+			a.constants[fields[0]] = statement{file: name, line: line, mnemonic: "EQU", operands: []string{value}}
+			continue
+		}
+
+		s := statement{file: name, line: line, address: a.address, mnemonic: strings.ToUpper(fields[0])}
+		rest := strings.TrimSpace(text[strings.Index(text, fields[0])+len(fields[0]):])
+		if rest != "" {
+			var err error
+			if s.operands, err = splitOperands(rest); err != nil {
+				return fail("%v", err)
+			}
+		}
+
+		switch s.mnemonic {
+		case "INCLUDE":
+			if len(s.operands) != 1 {
+				return fail("INCLUDE needs a file name")
+			}
+			file, err := unquote(s.operands[0])
+			if err != nil {
+				return fail("%v", err)
+			}
+			if depth >= maxIncludeDepth {
+				return fail("includes go more than %d files deep", maxIncludeDepth)
+			}
+			path := filepath.Join(filepath.Dir(name), file)
+			f, err := os.Open(path)
+			if err != nil {
+				return fail("%v", err)
+			}
+			err = a.read(path, f, depth+1)
+			f.Close()
+			if err != nil {
+				return err
+			}
+			continue
+		case "ORG":
+			if len(s.operands) != 1 {
+				return fail("ORG needs an address")
+			}
+			// Labels further on aren't known yet, so the address can
+			// only use what's above it.
+			address, err := a.value(s.operands[0])
+			if err != nil {
+				return fail("%v", err)
+			}
+			if address < 0 || address > 0xFFFF {
+				return fail("ORG address 0x%X is outside memory", address)
+			}
+			if !a.started {
+				a.origin = address
+			} else if address < a.address {
+				return fail("ORG 0x%X goes back over code at 0x%X", address, a.address)
+			}
+			a.address = address
+			continue
+		}
+
+		size, err := sizeOf(s)
+		if err != nil {
+			return fail("%v", err)
+		}
+		a.statements = append(a.statements, s)
+		a.started = true
+		a.address += size
+		if a.address > 0x10000 {
+			return fail("program runs past the end of memory")
+		}
+	}
+	return scanner.Err()
+}
+
+// define checks name can be used for a new label or constant.
+func (a *assembler) define(name string) error {
+	if isRegister(name) || keywords[strings.ToUpper(name)] {
+		return fmt.Errorf("%s is a reserved name", name)
+	}
+	if _, ok := a.labels[name]; ok {
+		return fmt.Errorf("%s is already defined", name)
+	}
+	if _, ok := a.constants[name]; ok {
+		return fmt.Errorf("%s is already defined", name)
+	}
+	return nil
+}
+
+// encode is the second pass, which turns every statement into bytes now
+// that every label is known.
+func (a *assembler) encode() (*Program, error) {
+	code := make([]byte, a.address-a.origin)
+	for _, s := range a.statements {
+		b, err := a.encodeStatement(s)
+		if err != nil {
+			return nil, &Error{File: s.file, Line: s.line, Err: err.Error()}
+		}
+		copy(code[s.address-a.origin:], b)
+	}
+	return &Program{Origin: uint16(a.origin), Code: code, Symbols: a.labels}, nil
+}
+
+// sizeOf is how many bytes s assembles to.
+func sizeOf(s statement) (int, error) {
+	switch s.mnemonic {
+	case "DB":
+		size := 0
+		for _, operand := range s.operands {
+			if text, err := unquote(operand); err == nil {
+				size += len(text)
+			} else {
+				size++
+			}
+		}
+		return size, nil
+	case "DW":
+		return 2 * len(s.operands), nil
+	case "LD":
+		if len(s.operands) == 2 && strings.HasPrefix(strings.ToUpper(s.operands[1]), "LONG ") {
+			return 4, nil
+		}
+	}
+	if _, ok := instructions[s.mnemonic]; !ok {
+		return 0, fmt.Errorf("unknown instruction %s", s.mnemonic)
+	}
+	return 2, nil
+}
+
+// stripComment drops everything from the first semicolon that isn't in
+// quotes.
+func stripComment(line string) string {
+	quoted := false
+	for i, c := range line {
+		switch {
+		case c == '"':
+			quoted = !quoted
+		case c == ';' && !quoted:
+			return line[:i]
+		}
+	}
+	return line
+}
+
+// splitOperands splits operands at commas that aren't in quotes.
+func splitOperands(s string) ([]string, error) {
+	var operands []string
+	quoted := false
+	start := 0
+	for i, c := range s {
+		switch {
+		case c == '"':
+			quoted = !quoted
+		case c == ',' && !quoted:
+			operands = append(operands, strings.TrimSpace(s[start:i]))
+			start = i + 1
+		}
+	}
+	if quoted {
+		return nil, fmt.Errorf("string isn't closed")
+	}
+	operands = append(operands, strings.TrimSpace(s[start:]))
+	for _, operand := range operands {
+		if operand == "" {
+			return nil, fmt.Errorf("missing operand")
+		}
+	}
+	return operands, nil
+}
+
+// unquote returns the text in a double quoted string.
+func unquote(s string) (string, error) {
+	if len(s) < 2 || s[0] != '"' || s[len(s)-1] != '"' {
+		return "", fmt.Errorf("want a string in double quotes, not %s", s)
+	}
+	return s[1 : len(s)-1], nil
+}
+
+// isName reports whether s can be a label or constant.
+func isName(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i, c := range s {
+		switch {
+		case c == '_' || c == '.':
+		case c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z':
+		case c >= '0' && c <= '9' && i > 0:
+		default:
+			return false
+		}
+	}
+	return true
+}
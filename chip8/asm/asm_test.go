@@ -0,0 +1,140 @@
+package asm
+
+import (
+	"bytes"
+	"github.com/h4ck3rk3y/go-8/chip8"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func assemble(t *testing.T, source string) *Program {
+	p, err := Assemble("test.s", strings.NewReader(source))
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	return p
+}
+
+func TestAssembleInstructions(t *testing.T) {
+	tests := []struct {
+		source string
+		want   []byte
+	}{
+		{"CLS", []byte{0x00, 0xE0}},
+		{"ld v1, 0x20", []byte{0x61, 0x20}},
+		{"DRW V0, V1, 5", []byte{0xD0, 0x15}},
+		{"CALL 0x2F0", []byte{0x22, 0xF0}},
+		{"JP V0, $300", []byte{0xB3, 0x00}},
+		{"ADD VB, -2", []byte{0x7B, 0xFE}},
+		{"SHR V3", []byte{0x83, 0x36}},
+		{"SHL V3, V4", []byte{0x83, 0x4E}},
+		{"LD [I], V3", []byte{0xF3, 0x55}},
+		{"LD V3, [I]", []byte{0xF3, 0x65}},
+		{"LD V3, K", []byte{0xF3, 0x0A}},
+		{"LD HF, V2", []byte{0xF2, 0x30}},
+		{"ADD I, VA", []byte{0xFA, 0x1E}},
+		{"SCD 4", []byte{0x00, 0xC4}},
+		{"LOAD V2, V5", []byte{0x52, 0x53}},
+		{"PLANE 3", []byte{0xF3, 0x01}},
+		{"LD I, LONG 0x1234", []byte{0xF0, 0x00, 0x12, 0x34}},
+		{"DB 1, %1000_0000, \"hi\"", nil},
+		{"DB 1, #80, \"hi;\"", []byte{0x01, 0x80, 'h', 'i', ';'}},
+		{"DW 0x1234, 0b101", []byte{0x12, 0x34, 0x00, 0x05}},
+	}
+	for _, test := range tests {
+		p, err := Assemble("test.s", strings.NewReader(test.source))
+		if test.want == nil {
+			assert.Error(t, err, test.source)
+			continue
+		}
+		if assert.NoError(t, err, test.source) {
+			assert.Equal(t, test.want, p.Code, test.source)
+		}
+	}
+}
+
+func TestAssembleLabelsAndConstants(t *testing.T) {
+	p := assemble(t, `
+SPEED equ STEP + 1   ; constants can use ones defined later
+STEP  EQU 2
+start:
+	LD V0, SPEED
+loop:	ADD V0, -STEP
+	JP loop
+	LD I, sprite+1
+sprite:
+	DB 0x80, 0x40
+`)
+	assert.Equal(t, []byte{0x60, 0x03, 0x70, 0xFE, 0x12, 0x02, 0xA2, 0x09, 0x80, 0x40}, p.Code)
+	assert.Equal(t, uint16(0x200), p.Origin)
+	assert.Equal(t, map[string]int{"start": 0x200, "loop": 0x202, "sprite": 0x208}, p.Symbols)
+
+	var b bytes.Buffer
+	assert.NoError(t, p.WriteSymbols(&b))
+	assert.Equal(t, "0x200 start\n0x202 loop\n0x208 sprite\n", b.String())
+}
+
+func TestAssembleOrg(t *testing.T) {
+	p := assemble(t, "\tORG 0x300\n\tCLS\n\tORG 0x306\n\tRET\n")
+	assert.Equal(t, uint16(0x300), p.Origin)
+	assert.Equal(t, []byte{0x00, 0xE0, 0, 0, 0, 0, 0x00, 0xEE}, p.Code)
+}
+
+func TestAssembleErrorsHaveLineNumbers(t *testing.T) {
+	tests := []struct {
+		source string
+		line   int
+		want   string
+	}{
+		{"CLS\nLD V1, 0x100", 2, "outside"},
+		{"JP 0x1000", 1, "outside"},
+		{"DRW V0, V1, 16", 1, "outside"},
+		{"CLS\n\nJP nowhere", 3, "isn't defined"},
+		{"FLY V1", 1, "unknown instruction"},
+		{"LD DT, 5", 1, "can't take operands"},
+		{"a:\na:", 2, "already defined"},
+		{"JP V1, 0x300", 1, "V0"},
+		{"X EQU Y\nY EQU X\nLD V0, X", 3, "itself"},
+		{"CLS\nORG 0x100", 2, "goes back"},
+		{"DB \"open", 1, "closed"},
+	}
+	for _, test := range tests {
+		_, err := Assemble("test.s", strings.NewReader(test.source))
+		if assert.IsType(t, &Error{}, err, test.source) {
+			e := err.(*Error)
+			assert.Equal(t, test.line, e.Line, test.source)
+			assert.Contains(t, e.Err, test.want, test.source)
+			assert.Equal(t, "test.s", e.File)
+		}
+	}
+}
+
+func TestAssembleInclude(t *testing.T) {
+	dir, err := ioutil.TempDir("", "asm")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "sprites.s"), []byte("ball: DB 0x80\n"), 0644))
+	main := filepath.Join(dir, "main.s")
+	assert.NoError(t, ioutil.WriteFile(main, []byte("LD I, ball\nINCLUDE \"sprites.s\"\n"), 0644))
+
+	p, err := AssembleFile(main)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{0xA2, 0x02, 0x80}, p.Code)
+
+	assert.NoError(t, ioutil.WriteFile(main, []byte("INCLUDE \"main.s\"\n"), 0644))
+	_, err = AssembleFile(main)
+	assert.Error(t, err)
+}
+
+func TestDisassemblyAssemblesBackToPong(t *testing.T) {
+	rom, err := ioutil.ReadFile("../../roms/PONG")
+	assert.NoError(t, err)
+	var listing bytes.Buffer
+	assert.NoError(t, chip8.Disassemble(&listing, rom, 0x200, chip8.VariantChip8))
+	p := assemble(t, listing.String())
+	assert.Equal(t, rom, p.Code)
+}
@@ -0,0 +1,336 @@
+package asm
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// keywords are the operands that name something other than a register or
+// a value, along with LONG which marks a 16 bit address.
+var keywords = map[string]bool{
+	"I": true, "[I]": true, "DT": true, "ST": true, "K": true,
+	"F": true, "HF": true, "B": true, "R": true, "LONG": true,
+}
+
+// encoder turns the operands of an instruction, already checked to be the
+// right kinds, into its opcode.
+type encoder func(a *assembler, operands []string) ([]byte, error)
+
+// instructions maps a mnemonic and the kinds of its operands, written as V
+// for a register, n for a value and the keyword for anything else, to how
+// it is encoded. "LD V,n" is LD V1, 0x20 for example.
+var instructions = map[string]map[string]encoder{
+	"CLS":   {"": fixed(0x00E0)},
+	"RET":   {"": fixed(0x00EE)},
+	"SCR":   {"": fixed(0x00FB)},
+	"SCL":   {"": fixed(0x00FC)},
+	"EXIT":  {"": fixed(0x00FD)},
+	"LOW":   {"": fixed(0x00FE)},
+	"HIGH":  {"": fixed(0x00FF)},
+	"SCD":   {"n": nibble(0x00C0)},
+	"SCU":   {"n": nibble(0x00D0)},
+	"SYS":   {"n": address(0x0000)},
+	"JP":    {"n": address(0x1000), "V,n": jumpV0},
+	"CALL":  {"n": address(0x2000)},
+	"SE":    {"V,n": registerByte(0x3000), "V,V": registers(0x5000)},
+	"SNE":   {"V,n": registerByte(0x4000), "V,V": registers(0x9000)},
+	"SAVE":  {"V,V": registers(0x5002)},
+	"LOAD":  {"V,V": registers(0x5003)},
+	"OR":    {"V,V": registers(0x8001)},
+	"AND":   {"V,V": registers(0x8002)},
+	"XOR":   {"V,V": registers(0x8003)},
+	"SUB":   {"V,V": registers(0x8005)},
+	"SHR":   {"V,V": registers(0x8006), "V": shift(0x8006)},
+	"SUBN":  {"V,V": registers(0x8007)},
+	"SHL":   {"V,V": registers(0x800E), "V": shift(0x800E)},
+	"RND":   {"V,n": registerByte(0xC000)},
+	"DRW":   {"V,V,n": draw},
+	"SKP":   {"V": register(0xE09E)},
+	"SKNP":  {"V": register(0xE0A1)},
+	"PLANE": {"n": plane},
+	"AUDIO": {"": fixed(0xF002)},
+	"PITCH": {"V": register(0xF03A)},
+	"ADD": {
+		"V,n": registerByte(0x7000),
+		"V,V": registers(0x8004),
+		"I,V": second(register(0xF01E)),
+	},
+	"LD": {
+		"V,n":    registerByte(0x6000),
+		"V,V":    registers(0x8000),
+		"I,n":    second(address(0xA000)),
+		"I,LONG": longI,
+		"V,DT":   first(register(0xF007)),
+		"V,K":    first(register(0xF00A)),
+		"DT,V":   second(register(0xF015)),
+		"ST,V":   second(register(0xF018)),
+		"F,V":    second(register(0xF029)),
+		"HF,V":   second(register(0xF030)),
+		"B,V":    second(register(0xF033)),
+		"[I],V":  second(register(0xF055)),
+		"V,[I]":  first(register(0xF065)),
+		"R,V":    second(register(0xF075)),
+		"V,R":    first(register(0xF085)),
+	},
+}
+
+// encodeStatement assembles an instruction or data directive.
+func (a *assembler) encodeStatement(s statement) ([]byte, error) {
+	switch s.mnemonic {
+	case "DB":
+		return a.data(s.operands, 1)
+	case "DW":
+		return a.data(s.operands, 2)
+	}
+	var kinds []string
+	for _, operand := range s.operands {
+		kinds = append(kinds, kind(operand))
+	}
+	shape := strings.Join(kinds, ",")
+	encode, ok := instructions[s.mnemonic][shape]
+	if !ok {
+		return nil, fmt.Errorf("%s can't take operands %s", s.mnemonic, strings.Join(s.operands, ", "))
+	}
+	return encode(a, s.operands)
+}
+
+// data assembles the values of a DB, size 1, or DW, size 2.
+func (a *assembler) data(operands []string, size int) ([]byte, error) {
+	var b []byte
+	for _, operand := range operands {
+		if text, err := unquote(operand); err == nil && size == 1 {
+			b = append(b, text...)
+			continue
+		}
+		v, err := a.value(operand)
+		if err != nil {
+			return nil, err
+		}
+		if size == 1 {
+			if err := inRange(operand, v, -0x80, 0xFF); err != nil {
+				return nil, err
+			}
+			b = append(b, byte(v))
+			continue
+		}
+		if err := inRange(operand, v, -0x8000, 0xFFFF); err != nil {
+			return nil, err
+		}
+		b = append(b, byte(v>>8), byte(v))
+	}
+	return b, nil
+}
+
+// kind is how an operand shows up in the instructions table.
+func kind(operand string) string {
+	upper := strings.ToUpper(operand)
+	switch {
+	case isRegister(operand):
+		return "V"
+	case strings.HasPrefix(upper, "LONG "):
+		return "LONG"
+	case keywords[upper]:
+		return upper
+	}
+	return "n"
+}
+
+// isRegister reports whether s is one of V0 to VF.
+func isRegister(s string) bool {
+	return len(s) == 2 && (s[0] == 'V' || s[0] == 'v') && strings.ContainsAny(s[1:], "0123456789ABCDEFabcdef")
+}
+
+// registerNumber is the number of a register operand.
+func registerNumber(s string) uint16 {
+	n, _ := strconv.ParseUint(s[1:], 16, 4)
+	return uint16(n)
+}
+
+func opcode(op uint16) []byte {
+	return []byte{byte(op >> 8), byte(op)}
+}
+
+func fixed(op uint16) encoder {
+	return func(a *assembler, operands []string) ([]byte, error) {
+		return opcode(op), nil
+	}
+}
+
+func nibble(op uint16) encoder {
+	return func(a *assembler, operands []string) ([]byte, error) {
+		n, err := a.operand(operands[0], 0, 0xF)
+		return opcode(op | n), err
+	}
+}
+
+func address(op uint16) encoder {
+	return func(a *assembler, operands []string) ([]byte, error) {
+		n, err := a.operand(operands[0], 0, 0xFFF)
+		return opcode(op | n), err
+	}
+}
+
+func register(op uint16) encoder {
+	return func(a *assembler, operands []string) ([]byte, error) {
+		return opcode(op | registerNumber(operands[0])<<8), nil
+	}
+}
+
+func registers(op uint16) encoder {
+	return func(a *assembler, operands []string) ([]byte, error) {
+		return opcode(op | registerNumber(operands[0])<<8 | registerNumber(operands[1])<<4), nil
+	}
+}
+
+func registerByte(op uint16) encoder {
+	return func(a *assembler, operands []string) ([]byte, error) {
+		n, err := a.operand(operands[1], -0x80, 0xFF)
+		return opcode(op | registerNumber(operands[0])<<8 | n&0xFF), err
+	}
+}
+
+// shift is SHR or SHL written with one register, which is used for both.
+func shift(op uint16) encoder {
+	return func(a *assembler, operands []string) ([]byte, error) {
+		x := registerNumber(operands[0])
+		return opcode(op | x<<8 | x<<4), nil
+	}
+}
+
+// first encodes with just the first operand, when the second is a keyword.
+func first(e encoder) encoder {
+	return func(a *assembler, operands []string) ([]byte, error) {
+		return e(a, operands[:1])
+	}
+}
+
+// second encodes with just the second operand, when the first is a keyword.
+func second(e encoder) encoder {
+	return func(a *assembler, operands []string) ([]byte, error) {
+		return e(a, operands[1:])
+	}
+}
+
+func jumpV0(a *assembler, operands []string) ([]byte, error) {
+	if registerNumber(operands[0]) != 0 {
+		return nil, fmt.Errorf("JP can only add V0 to an address, not %s", operands[0])
+	}
+	return address(0xB000)(a, operands[1:])
+}
+
+func draw(a *assembler, operands []string) ([]byte, error) {
+	n, err := a.operand(operands[2], 0, 0xF)
+	return opcode(0xD000 | registerNumber(operands[0])<<8 | registerNumber(operands[1])<<4 | n), err
+}
+
+func plane(a *assembler, operands []string) ([]byte, error) {
+	n, err := a.operand(operands[0], 0, 3)
+	return opcode(0xF001 | n<<8), err
+}
+
+func longI(a *assembler, operands []string) ([]byte, error) {
+	n, err := a.operand(strings.TrimSpace(operands[1][len("LONG"):]), 0, 0xFFFF)
+	return []byte{0xF0, 0x00, byte(n >> 8), byte(n)}, err
+}
+
+// operand evaluates s and checks it's between min and max.
+func (a *assembler) operand(s string, min, max int) (uint16, error) {
+	v, err := a.value(s)
+	if err != nil {
+		return 0, err
+	}
+	return uint16(v), inRange(s, v, min, max)
+}
+
+func inRange(s string, v, min, max int) error {
+	if v < min || v > max {
+		if s == strconv.Itoa(v) {
+			return fmt.Errorf("%s is outside %d to %d", s, min, max)
+		}
+		return fmt.Errorf("%s is %d, which is outside %d to %d", s, v, min, max)
+	}
+	return nil
+}
+
+// value evaluates an expression of numbers, labels and constants added
+// and subtracted.
+func (a *assembler) value(s string) (int, error) {
+	total := 0
+	sign := 1
+	expectTerm := true
+	for rest := strings.TrimSpace(s); rest != ""; rest = strings.TrimSpace(rest) {
+		if !expectTerm {
+			switch rest[0] {
+			case '+':
+				sign = 1
+			case '-':
+				sign = -1
+			default:
+				return 0, fmt.Errorf("bad expression %q", s)
+			}
+			rest = rest[1:]
+			expectTerm = true
+			continue
+		}
+		if rest[0] == '-' {
+			sign = -sign
+			rest = rest[1:]
+			continue
+		}
+		end := strings.IndexAny(rest, "+- \t")
+		if end < 0 {
+			end = len(rest)
+		}
+		v, err := a.term(rest[:end])
+		if err != nil {
+			return 0, err
+		}
+		total += sign * v
+		sign = 1
+		rest = rest[end:]
+		expectTerm = false
+	}
+	if expectTerm {
+		return 0, fmt.Errorf("bad expression %q", s)
+	}
+	return total, nil
+}
+
+// term is the value of a number, label or constant.
+func (a *assembler) term(s string) (int, error) {
+	if v, ok := a.labels[s]; ok {
+		return v, nil
+	}
+	if c, ok := a.constants[s]; ok {
+		if a.evaluating[s] {
+			return 0, fmt.Errorf("%s is defined in terms of itself", s)
+		}
+		a.evaluating[s] = true
+		defer delete(a.evaluating, s)
+		v, err := a.value(c.operands[0])
+		if err != nil {
+			return 0, fmt.Errorf("%s (%s:%d): %v", s, c.file, c.line, err)
+		}
+		return v, nil
+	}
+	if isName(s) {
+		return 0, fmt.Errorf("%s isn't defined", s)
+	}
+	digits, base := s, 10
+	switch {
+	case strings.HasPrefix(s, "0x"), strings.HasPrefix(s, "0X"):
+		digits, base = s[2:], 16
+	case strings.HasPrefix(s, "$"), strings.HasPrefix(s, "#"):
+		digits, base = s[1:], 16
+	case strings.HasPrefix(s, "0b"), strings.HasPrefix(s, "0B"):
+		digits, base = s[2:], 2
+	case strings.HasPrefix(s, "%"):
+		digits, base = s[1:], 2
+	}
+	v, err := strconv.ParseUint(digits, base, 32)
+	if err != nil {
+		return 0, fmt.Errorf("bad number %s", s)
+	}
+	return int(v), nil
+}
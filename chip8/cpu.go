@@ -0,0 +1,805 @@
+package chip8
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+)
+
+const (
+	height      = 0x40 // rows on the display in high resolution mode
+	width       = 0x80 // columns on the display in high resolution mode
+	loresHeight = 0x20
+	loresWidth  = 0x40
+	bigFontAt   = 0x50 // where the SUPER-CHIP font starts in memory
+	patternSize = 16   // bytes in the XO-CHIP audio pattern buffer
+	basePitch   = 64   // XO-CHIP pitch for a 4000Hz pattern playback rate
+)
+
+// ErrExit is returned once a program has stopped the interpreter with 00FD.
+var ErrExit = errors.New("program exited")
+
+// Machine is a CHIP-8 interpreter along with the memory, display and keypad
+// of the machine it runs on. The registers V and I are there to be read and
+// written directly; everything else goes through methods.
+type Machine struct {
+	pc           uint16              // program counter
+	memory       [0x10000]byte       // 4k memory, or 64k on XO-CHIP
+	stack        [16]uint16          // 16 level stack
+	sp           uint16              // stack pointer
+	V            [16]byte            // 16 registers
+	I            uint16              // The address register
+	delayTimer   byte                // The delay timer counts down at 60hz
+	soundTimer   byte                //sound timer counts down at 60hz
+	display      [height][width]byte // 128x64 grid, a bit for each of the two planes
+	plane        byte                // XO-CHIP planes being drawn to
+	pattern      [patternSize]byte   // XO-CHIP audio pattern buffer
+	pitch        byte                // XO-CHIP playback rate of the pattern
+	hires        bool                // 128x64 rather than 64x32
+	rpl          [16]byte            // SUPER-CHIP RPL user flags
+	keys         [16]byte            // state of the keys
+	draw         bool                // to draw or not
+	waiting      bool                // FX0A is waiting for a key to be pressed and let go
+	waitKey      byte                // the key pressed while waiting, noKey until there is one
+	waitIgnore   uint16              // keys held when the wait started, until they're let go
+	opcodePolicy OpcodePolicy        // what to do with opcodes we can't decode
+	quirks       Quirks              // which flavour of CHIP-8 to behave like
+	variant      Variant             // which instructions are available
+	logger       *log.Logger         // where LogUnknown sends opcodes, nil for the standard logger
+	screen       Display             // told about changes to display
+	keypad       Keypad              // where keys are read from, if not set with SetKey
+	sound        Sound               // the buzzer
+	buzzing      bool                // whether sound was last told to beep
+	randomMode   RandomMode          // how CXNN makes random numbers
+	seed         int64               // what the random number generator started from
+	rng          uint32              // state of the random number generator
+	watcher      MemoryWatcher       // told about the memory instructions use
+	tracer       Tracer              // told about every instruction executed
+}
+
+var fontset = [...]byte{
+	0xF0, 0x90, 0x90, 0x90, 0xF0, // 0
+	0x20, 0x60, 0x20, 0x20, 0x70, // 1
+	0xF0, 0x10, 0xF0, 0x80, 0xF0, // 2
+	0xF0, 0x10, 0xF0, 0x10, 0xF0, // 3
+	0x90, 0x90, 0xF0, 0x10, 0x10, // 4
+	0xF0, 0x80, 0xF0, 0x10, 0xF0, // 5
+	0xF0, 0x80, 0xF0, 0x90, 0xF0, // 6
+	0xF0, 0x10, 0x20, 0x40, 0x40, // 7
+	0xF0, 0x90, 0xF0, 0x90, 0xF0, // 8
+	0xF0, 0x90, 0xF0, 0x10, 0xF0, // 9
+	0xF0, 0x90, 0xF0, 0x90, 0x90, // A
+	0xE0, 0x90, 0xE0, 0x90, 0xE0, // B
+	0xF0, 0x80, 0x80, 0x80, 0xF0, // C
+	0xE0, 0x90, 0x90, 0x90, 0xE0, // D
+	0xF0, 0x80, 0xF0, 0x80, 0xF0, // E
+	0xF0, 0x80, 0xF0, 0x80, 0x80, // F
+}
+
+// bigFontset is the SUPER-CHIP 8x10 font for high resolution mode. SUPER-CHIP
+// itself only had the digits, A to F come from Octo.
+var bigFontset = [...]byte{
+	0x3C, 0x7E, 0xE7, 0xC3, 0xC3, 0xC3, 0xC3, 0xE7, 0x7E, 0x3C, // 0
+	0x18, 0x38, 0x58, 0x18, 0x18, 0x18, 0x18, 0x18, 0x18, 0x3C, // 1
+	0x3E, 0x7F, 0xC3, 0x06, 0x0C, 0x18, 0x30, 0x60, 0xFF, 0xFF, // 2
+	0x3C, 0x7E, 0xC3, 0x03, 0x0E, 0x0E, 0x03, 0xC3, 0x7E, 0x3C, // 3
+	0x06, 0x0E, 0x1E, 0x36, 0x66, 0xC6, 0xFF, 0xFF, 0x06, 0x06, // 4
+	0xFF, 0xFF, 0xC0, 0xC0, 0xFC, 0xFE, 0x03, 0xC3, 0x7E, 0x3C, // 5
+	0x3E, 0x7C, 0xE0, 0xC0, 0xFC, 0xFE, 0xC3, 0xC3, 0x7E, 0x3C, // 6
+	0xFF, 0xFF, 0x03, 0x06, 0x0C, 0x18, 0x30, 0x60, 0x60, 0x60, // 7
+	0x3C, 0x7E, 0xC3, 0xC3, 0x7E, 0x7E, 0xC3, 0xC3, 0x7E, 0x3C, // 8
+	0x3C, 0x7E, 0xC3, 0xC3, 0x7F, 0x3F, 0x03, 0x03, 0x3E, 0x7C, // 9
+	0x7E, 0xFF, 0xC3, 0xC3, 0xC3, 0xFF, 0xFF, 0xC3, 0xC3, 0xC3, // A
+	0xFC, 0xFC, 0xC3, 0xC3, 0xFC, 0xFC, 0xC3, 0xC3, 0xFC, 0xFC, // B
+	0x3C, 0xFF, 0xC3, 0xC0, 0xC0, 0xC0, 0xC0, 0xC3, 0xFF, 0x3C, // C
+	0xFC, 0xFE, 0xC3, 0xC3, 0xC3, 0xC3, 0xC3, 0xC3, 0xFE, 0xFC, // D
+	0xFF, 0xFF, 0xC0, 0xC0, 0xFF, 0xFF, 0xC0, 0xC0, 0xFF, 0xFF, // E
+	0xFF, 0xFF, 0xC0, 0xC0, 0xFF, 0xFF, 0xC0, 0xC0, 0xC0, 0xC0, // F
+}
+
+// New returns a machine ready to have a program loaded. Unless options say
+// otherwise it's a CHIP-8 with modern quirks that halts on unknown opcodes.
+func New(options ...Option) *Machine {
+	m := &Machine{pc: 0x200, quirks: QuirksModern, variant: VariantChip8, plane: 0x01, pitch: basePitch, seed: timeSeed()}
+	for _, option := range options {
+		option(m)
+	}
+	m.seedRandom()
+	m.loadFontSet()
+	return m
+}
+
+func (m *Machine) loadFontSet() {
+	for i := 0x00; i < 0x50; i++ {
+		m.memory[i] = fontset[i]
+	}
+	for i, b := range bigFontset {
+		m.memory[bigFontAt+i] = b
+	}
+}
+
+func (m *Machine) clearDisplay() {
+	for x := 0x00; x < height; x++ {
+		for y := 0x00; y < width; y++ {
+			m.display[x][y] = 0x00
+		}
+	}
+}
+
+// screenWidth is the number of columns in use in the current resolution.
+func (m *Machine) screenWidth() int {
+	if m.hires {
+		return width
+	}
+	return loresWidth
+}
+
+// screenHeight is the number of rows in use in the current resolution.
+func (m *Machine) screenHeight() int {
+	if m.hires {
+		return height
+	}
+	return loresHeight
+}
+
+// clearPlanes blanks the selected planes, leaving the others alone.
+func (m *Machine) clearPlanes() {
+	for y := 0x00; y < height; y++ {
+		for x := 0x00; x < width; x++ {
+			m.display[y][x] = m.display[y][x] &^ m.plane
+		}
+	}
+}
+
+// scroll moves the picture on the selected planes dx columns right and dy
+// rows down, blanking whatever it uncovers.
+func (m *Machine) scroll(dx, dy int) {
+	old := m.display
+	w, h := m.screenWidth(), m.screenHeight()
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			fromX, fromY := x-dx, y-dy
+			moved := byte(0x00)
+			if fromX >= 0 && fromX < w && fromY >= 0 && fromY < h {
+				moved = old[fromY][fromX] & m.plane
+			}
+			m.display[y][x] = m.display[y][x]&^m.plane | moved
+		}
+	}
+}
+
+// noKey is waitKey before a key has been pressed.
+const noKey = 0xFF
+
+// heldKeys has bit n set for each key n held down.
+func (m *Machine) heldKeys() uint16 {
+	var held uint16
+	for key, down := range m.keys {
+		if down == 0x01 {
+			held |= 1 << uint(key)
+		}
+	}
+	return held
+}
+
+// waitForKey moves FX0A's wait for a key on, returning the key once one
+// has been pressed and let go, as on the COSMAC VIP. Keys already held
+// down when the wait starts don't count until they've been let go, so a
+// key held down doesn't run through one wait after another.
+func (m *Machine) waitForKey() (byte, bool) {
+	m.pollKeys()
+	held := m.heldKeys()
+	if !m.waiting {
+		m.waiting = true
+		m.waitKey = noKey
+		m.waitIgnore = held
+		return 0, false
+	}
+	m.waitIgnore &= held
+	if m.waitKey == noKey {
+		for key := uint(0); key < 16; key++ {
+			if held&^m.waitIgnore&(1<<key) != 0 {
+				m.waitKey = byte(key)
+				break
+			}
+		}
+		return 0, false
+	}
+	if held&(1<<m.waitKey) != 0 {
+		return 0, false
+	}
+	m.waiting = false
+	return m.waitKey, true
+}
+
+// supports reports whether the instructions added by variant are available.
+func (m *Machine) supports(variant Variant) bool {
+	return m.variant >= variant
+}
+
+// memorySize is how much of memory the program can address.
+func (m *Machine) memorySize() int {
+	return m.variant.memorySize()
+}
+
+// Load copies the program read from r into memory at 0x200 and returns its
+// size.
+func (m *Machine) Load(r io.Reader) (int, error) {
+	room := m.memorySize() - 0x200
+	// Read a byte more than there's room for to find out if it's too big.
+	memory := make([]byte, room+1)
+	n, err := io.ReadFull(r, memory)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return 0, err
+	}
+	if n == 0 {
+		return 0, errors.New("program is empty")
+	}
+	if n > room {
+		return 0, fmt.Errorf("program is bigger than the %d bytes a %v has room for", room, m.variant)
+	}
+	for index, b := range memory[:room] {
+		m.memory[index+0x200] = b
+	}
+	return n, nil
+}
+
+// LoadFile copies the program in the file rom into memory at 0x200 and
+// returns its size.
+func (m *Machine) LoadFile(rom string) (int, error) {
+	f, err := os.Open(rom)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	n, err := m.Load(f)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %v", rom, err)
+	}
+	return n, nil
+}
+
+// Reset puts the machine back the way New left it, program and all gone.
+func (m *Machine) Reset() {
+	m.pc = 0x200
+	m.waiting = false
+	m.delayTimer = 0
+	m.soundTimer = 0
+	m.I = 0
+	m.sp = 0
+	m.hires = false
+	m.plane = 0x01
+	m.pitch = basePitch
+	for i := 0; i < len(m.memory); i++ {
+		m.memory[i] = 0
+	}
+	for i := 0; i < len(m.stack); i++ {
+		m.stack[i] = 0
+	}
+	for i := 0; i < len(m.V); i++ {
+		m.V[i] = 0
+	}
+	for i := 0; i < len(m.keys); i++ {
+		m.keys[i] = 0
+	}
+	for i := 0; i < len(m.rpl); i++ {
+		m.rpl[i] = 0
+	}
+	for i := 0; i < len(m.pattern); i++ {
+		m.pattern[i] = 0
+	}
+	m.seedRandom()
+	m.loadFontSet()
+	m.clearDisplay()
+	m.updateBuzzer()
+}
+
+// TickTimers counts the delay and sound timers down by one. It should be
+// called 60 times a second of emulated time, however many instructions are
+// executed in between.
+func (m *Machine) TickTimers() {
+	if m.delayTimer > 0 {
+		m.delayTimer = m.delayTimer - 1
+	}
+
+	if m.soundTimer > 0 {
+		m.soundTimer = m.soundTimer - 1
+	}
+	m.updateBuzzer()
+}
+
+// RunFrame emulates a sixtieth of a second: ipf instructions followed by one
+// tick of the timers. The clock speed is 60 * ipf instructions a second.
+// If an instruction fails the frame stops there, without the timers
+// ticking, and RunFrame returns how many instructions were executed before
+// it along with the error.
+func (m *Machine) RunFrame(ipf int) (int, error) {
+	for i := 0; i < ipf; i++ {
+		if err := m.Step(); err != nil {
+			return i, err
+		}
+	}
+	m.TickTimers()
+	return ipf, nil
+}
+
+// unknownOpcode applies the opcode policy to an instruction fetched from
+// address that Step couldn't decode.
+func (m *Machine) unknownOpcode(opcode, address uint16, reason string) error {
+	err := &OpcodeError{Opcode: opcode, Address: address, Reason: reason}
+	switch m.opcodePolicy {
+	case IgnoreUnknown:
+		return nil
+	case LogUnknown:
+		if m.logger != nil {
+			m.logger.Print(err)
+		} else {
+			log.Print(err)
+		}
+		return nil
+	}
+	m.pc = address
+	return err
+}
+
+// fault stops the cpu on the instruction at address and reports why.
+func (m *Machine) fault(kind FaultKind, opcode, address uint16) error {
+	m.pc = address
+	state := *m
+	return &Fault{Kind: kind, Opcode: opcode, Address: address, State: &state}
+}
+
+// addressable reports whether n bytes starting at I are inside memory.
+func (m *Machine) addressable(n int) bool {
+	return int(m.I)+n <= m.memorySize()
+}
+
+// skip steps over the next instruction, which on XO-CHIP may be the four
+// byte F000 NNNN.
+func (m *Machine) skip() {
+	if m.supports(VariantXOChip) && int(m.pc)+1 < m.memorySize() &&
+		m.memory[m.pc] == 0xF0 && m.memory[m.pc+1] == 0x00 {
+		m.pc = m.pc + 4
+		return
+	}
+	m.pc = m.pc + 2
+}
+
+// incrementI moves I on after FX55 or FX65 has used registers V0 to Vx.
+func (m *Machine) incrementI(x uint16) {
+	switch m.quirks.MemoryIncrement {
+	case IncrementX:
+		m.I = m.I + x
+	case IncrementXPlus1:
+		m.I = m.I + x + 1
+	}
+}
+
+// drawSprite XORs a sprite of rows by cols pixels found at sprite in memory
+// onto the display at x, y. Only the plane in bit is touched and VF is set
+// if any pixel on it gets turned off.
+func (m *Machine) drawSprite(x, y, rows, cols, sprite int, bit byte) {
+	// The sprite always starts on screen, it's the rest of it that's
+	// either wrapped or clipped.
+	w, h := m.screenWidth(), m.screenHeight()
+	x, y = x%w, y%h
+	for row := 0; row < rows; row++ {
+		yIndex := y + row
+		if yIndex >= h {
+			if m.quirks.ClipSprites {
+				break
+			}
+			yIndex = yIndex - h
+		}
+		for col := 0; col < cols; col++ {
+			xIndex := x + col
+			if xIndex >= w {
+				if m.quirks.ClipSprites {
+					break
+				}
+				xIndex = xIndex - w
+			}
+			data := m.memory[sprite+(row*cols+col)/8]
+			if (data>>uint(7-col%8))&0x01 == 0x00 {
+				continue
+			}
+			if m.display[yIndex][xIndex]&bit != 0x00 {
+				m.V[0xF] = 0x01
+			}
+			m.display[yIndex][xIndex] = m.display[yIndex][xIndex] ^ bit
+		}
+	}
+}
+
+// Step executes the instruction at pc. Unknown instructions are dealt with
+// according to the opcode policy, and instructions that would overrun the
+// stack or memory stop the machine with a *Fault.
+func (m *Machine) Step() error {
+	m.draw = false
+	var entry TraceEntry
+	var before registers
+	if m.tracer != nil {
+		entry.Instruction = Decode(m.Memory(), m.pc, m.variant)
+		before = m.registers()
+	}
+	err := m.execute()
+	if m.tracer != nil {
+		entry.Changes = before.changes(m.registers())
+		entry.Err = err
+		m.tracer.Trace(entry)
+	}
+	if m.draw && m.screen != nil {
+		m.screen.Draw(m.Frame())
+	}
+	m.updateBuzzer()
+	return err
+}
+
+// execute decodes and executes the instruction at pc.
+func (m *Machine) execute() error {
+	address := m.pc
+	if int(address)+1 >= m.memorySize() {
+		return m.fault(PCOutOfRange, 0, address)
+	}
+	opcode := uint16(m.memory[m.pc])<<8 | uint16(m.memory[m.pc+1])
+	m.pc = m.pc + 2
+	switch opcode & 0xF000 {
+	case 0x0000:
+		switch {
+		case opcode == 0x00E0:
+			m.clearPlanes()
+			m.draw = true
+		case opcode == 0x00EE:
+			if m.sp == 0 {
+				return m.fault(StackUnderflow, opcode, address)
+			}
+			m.pc = m.stack[m.sp-1]
+			m.sp = m.sp - 1
+		case !m.supports(VariantSChip):
+			return m.unknownOpcode(opcode, address, "machine code routines are not supported")
+		case opcode == 0x00FB:
+			m.scroll(4, 0)
+			m.draw = true
+		case opcode == 0x00FC:
+			m.scroll(-4, 0)
+			m.draw = true
+		case opcode == 0x00FD:
+			m.pc = address
+			return ErrExit
+		case opcode == 0x00FE:
+			m.hires = false
+			m.clearDisplay()
+			m.draw = true
+		case opcode == 0x00FF:
+			m.hires = true
+			m.clearDisplay()
+			m.draw = true
+		case opcode&0xFFF0 == 0x00C0:
+			m.scroll(0, int(opcode&0x000F))
+			m.draw = true
+		case opcode&0xFFF0 == 0x00D0 && m.supports(VariantXOChip):
+			m.scroll(0, -int(opcode&0x000F))
+			m.draw = true
+		default:
+			return m.unknownOpcode(opcode, address, "machine code routines are not supported")
+		}
+	case 0x1000:
+		m.pc = opcode & 0x0FFF
+	case 0x2000:
+		if int(m.sp) >= len(m.stack) {
+			return m.fault(StackOverflow, opcode, address)
+		}
+		m.stack[m.sp] = m.pc
+		m.sp = m.sp + 1
+		m.pc = opcode & 0x0FFF
+	case 0x3000:
+		compareTo := byte(opcode & 0x00FF)
+		register := (opcode & 0x0F00) >> 8
+		if m.V[register] == compareTo {
+			m.skip()
+		}
+	case 0x4000:
+		compareTo := byte(opcode & 0x00FF)
+		register := (opcode & 0x0F00) >> 8
+		if m.V[register] != compareTo {
+			m.skip()
+		}
+	case 0x5000:
+		registerX := (opcode & 0x0F00) >> 8
+		registerY := (opcode & 0x00F0) >> 4
+		switch opcode & 0x000F {
+		case 0x0000:
+			if m.V[registerX] == m.V[registerY] {
+				m.skip()
+			}
+		case 0x0002, 0x0003:
+			if !m.supports(VariantXOChip) {
+				return m.unknownOpcode(opcode, address, "needs XO-CHIP")
+			}
+			// The range can run either way, V5 to V2 is the same
+			// registers as V2 to V5 but in the opposite order.
+			step := 1
+			count := int(registerY) - int(registerX)
+			if count < 0 {
+				step, count = -1, -count
+			}
+			if !m.addressable(count + 1) {
+				return m.fault(IOutOfRange, opcode, address)
+			}
+			for i := 0; i <= count; i++ {
+				register := int(registerX) + i*step
+				if opcode&0x000F == 0x0002 {
+					m.memory[int(m.I)+i] = m.V[register]
+				} else {
+					m.V[register] = m.memory[int(m.I)+i]
+				}
+			}
+			m.access(m.I, count+1, opcode&0x000F == 0x0002)
+		default:
+			return m.unknownOpcode(opcode, address, "no such instruction")
+		}
+	case 0x6000:
+		register := byte((opcode & 0x0F00) >> 8)
+		m.V[register] = byte(opcode & 0x00FF)
+	case 0x7000:
+		register := byte((opcode & 0x0F00) >> 8)
+		value := byte(opcode & 0x00FF)
+		m.V[register] = m.V[register] + value
+	case 0x8000:
+		switch opcode & 0x000F {
+		case 0x0000:
+			registerX := (opcode & 0x0F00) >> 8
+			registerY := (opcode & 0x00F0) >> 4
+			m.V[registerX] = m.V[registerY]
+		case 0x0001:
+			registerX := (opcode & 0x0F00) >> 8
+			registerY := (opcode & 0x00F0) >> 4
+			m.V[registerX] = m.V[registerX] | m.V[registerY]
+			if m.quirks.VFReset {
+				m.V[0xF] = 0
+			}
+		case 0x0002:
+			registerX := (opcode & 0x0F00) >> 8
+			registerY := (opcode & 0x00F0) >> 4
+			m.V[registerX] = m.V[registerX] & m.V[registerY]
+			if m.quirks.VFReset {
+				m.V[0xF] = 0
+			}
+		case 0x0003:
+			registerX := (opcode & 0x0F00) >> 8
+			registerY := (opcode & 0x00F0) >> 4
+			m.V[registerX] = m.V[registerX] ^ m.V[registerY]
+			if m.quirks.VFReset {
+				m.V[0xF] = 0
+			}
+		// The flags are worked out from the registers as they were and
+		// written last, so they win when VF is also the result.
+		case 0x0004:
+			registerX := byte((opcode & 0x0F00) >> 8)
+			registerY := byte((opcode & 0x00F0) >> 4)
+			carry := byte(0)
+			if uint16(m.V[registerX])+uint16(m.V[registerY]) > 0xFF {
+				carry = 1
+			}
+			m.V[registerX] = m.V[registerX] + m.V[registerY]
+			m.V[0xF] = carry
+		case 0x0005:
+			registerX := (opcode & 0x0F00) >> 8
+			registerY := (opcode & 0x00F0) >> 4
+			// VF is set when there's no borrow, equal registers included.
+			noBorrow := byte(0)
+			if m.V[registerX] >= m.V[registerY] {
+				noBorrow = 1
+			}
+			m.V[registerX] = m.V[registerX] - m.V[registerY]
+			m.V[0xF] = noBorrow
+		case 0x0006:
+			registerX := (opcode & 0x0F00) >> 8
+			source := registerX
+			if m.quirks.ShiftUsesVY {
+				source = (opcode & 0x00F0) >> 4
+			}
+			shifted := m.V[source] & 0x1
+			m.V[registerX] = m.V[source] >> 1
+			m.V[0xF] = shifted
+		case 0x0007:
+			registerX := (opcode & 0x0F00) >> 8
+			registerY := (opcode & 0x00F0) >> 4
+			noBorrow := byte(0)
+			if m.V[registerY] >= m.V[registerX] {
+				noBorrow = 1
+			}
+			m.V[registerX] = m.V[registerY] - m.V[registerX]
+			m.V[0xF] = noBorrow
+		case 0x000E:
+			registerX := (opcode & 0x0F00) >> 8
+			source := registerX
+			if m.quirks.ShiftUsesVY {
+				source = (opcode & 0x00F0) >> 4
+			}
+			shifted := m.V[source] >> 7
+			m.V[registerX] = m.V[source] << 1
+			m.V[0xF] = shifted
+		default:
+			return m.unknownOpcode(opcode, address, "no such instruction")
+		}
+	case 0x9000:
+		if opcode&0x000F != 0 {
+			return m.unknownOpcode(opcode, address, "no such instruction")
+		}
+		registerX := (opcode & 0x0F00) >> 8
+		registerY := (opcode & 0x00F0) >> 4
+		if m.V[registerX] != m.V[registerY] {
+			m.skip()
+		}
+	case 0xA000:
+		m.I = (opcode & 0x0FFF)
+	case 0xB000:
+		register := uint16(0x0)
+		if m.quirks.JumpUsesVX {
+			register = (opcode & 0x0F00) >> 8
+		}
+		m.pc = (opcode & 0x0FFF) + uint16(m.V[register])
+	case 0xC000:
+		registerX := (opcode & 0x0F00) >> 8
+		value := byte(opcode & 0x00FF)
+		m.V[registerX] = m.random() & value
+	case 0xD000:
+		registerX := (opcode & 0x0F00) >> 8
+		registerY := (opcode & 0x00F0) >> 4
+		// DXY0 draws a 16x16 sprite stored two bytes to a row, or nothing
+		// at all on the original CHIP-8.
+		rows, cols := int(opcode&0x000F), 8
+		if rows == 0 && m.supports(VariantSChip) {
+			rows, cols = 16, 16
+		}
+		// Each selected plane gets its own copy of the sprite, one after
+		// the other in memory.
+		planes := 0
+		for p := m.plane; p != 0; p = p >> 1 {
+			planes = planes + int(p&0x01)
+		}
+		size := rows * cols / 8
+		if !m.addressable(size * planes) {
+			return m.fault(IOutOfRange, opcode, address)
+		}
+		m.V[0xF] = 0x00
+		sprite := int(m.I)
+		for bit := byte(0x01); bit <= 0x02; bit = bit << 1 {
+			if m.plane&bit == 0 {
+				continue
+			}
+			m.drawSprite(int(m.V[registerX]), int(m.V[registerY]), rows, cols, sprite, bit)
+			sprite = sprite + size
+		}
+		m.access(m.I, sprite-int(m.I), false)
+		m.draw = true
+	case 0xE000:
+		switch opcode & 0x00FF {
+		case 0x009E:
+			register := (opcode & 0x0F00) >> 8
+			m.pollKeys()
+			if m.keys[m.V[register]&0x0F] == 0x01 {
+				m.skip()
+			}
+		case 0x00A1:
+			register := (opcode & 0x0F00) >> 8
+			m.pollKeys()
+			if m.keys[m.V[register]&0x0F] == 0x00 {
+				m.skip()
+			}
+		default:
+			return m.unknownOpcode(opcode, address, "no such instruction")
+		}
+	case 0xF000:
+		if !m.supports(VariantXOChip) && (opcode == 0xF000 || opcode&0x00FF == 0x0001 ||
+			opcode == 0xF002 || opcode&0x00FF == 0x003A) {
+			return m.unknownOpcode(opcode, address, "needs XO-CHIP")
+		}
+		if !m.supports(VariantSChip) && (opcode&0x00FF == 0x0030 ||
+			opcode&0x00FF == 0x0075 || opcode&0x00FF == 0x0085) {
+			return m.unknownOpcode(opcode, address, "needs SUPER-CHIP")
+		}
+		switch opcode & 0x00FF {
+		case 0x0000:
+			if opcode != 0xF000 {
+				return m.unknownOpcode(opcode, address, "no such instruction")
+			}
+			if int(m.pc)+1 >= m.memorySize() {
+				return m.fault(PCOutOfRange, opcode, address)
+			}
+			m.I = uint16(m.memory[m.pc])<<8 | uint16(m.memory[m.pc+1])
+			m.pc = m.pc + 2
+		case 0x0001:
+			register := (opcode & 0x0F00) >> 8
+			m.plane = byte(register) & 0x03
+		case 0x0002:
+			if opcode != 0xF002 {
+				return m.unknownOpcode(opcode, address, "no such instruction")
+			}
+			if !m.addressable(patternSize) {
+				return m.fault(IOutOfRange, opcode, address)
+			}
+			copy(m.pattern[:], m.memory[m.I:int(m.I)+patternSize])
+			m.access(m.I, patternSize, false)
+			m.updatePattern()
+		case 0x007:
+			register := (opcode & 0x0F00) >> 8
+			m.V[register] = m.delayTimer
+		case 0x0015:
+			register := (opcode & 0x0F00) >> 8
+			m.delayTimer = m.V[register]
+		case 0x0018:
+			register := (opcode & 0x0F00) >> 8
+			m.soundTimer = m.V[register]
+		case 0x000A:
+			// The machine stays on this instruction, with the timers
+			// still counting down, until a key has been pressed and let
+			// go.
+			register := (opcode & 0x0F00) >> 8
+			if key, ok := m.waitForKey(); ok {
+				m.V[register] = key
+			} else {
+				m.pc = address
+			}
+		case 0x001E:
+			register := (opcode & 0x0F00) >> 8
+			m.I = m.I + uint16(m.V[register])
+		case 0x0029:
+			register := (opcode & 0x0F00) >> 8
+			m.I = uint16(m.V[register] * 0x5)
+		case 0x0030:
+			register := (opcode & 0x0F00) >> 8
+			m.I = bigFontAt + uint16(m.V[register]&0x0F)*10
+		case 0x003A:
+			register := (opcode & 0x0F00) >> 8
+			m.pitch = m.V[register]
+			m.updatePattern()
+		case 0x0033:
+			register := (opcode & 0x0F00) >> 8
+			if !m.addressable(3) {
+				return m.fault(IOutOfRange, opcode, address)
+			}
+			number := m.V[register]
+			m.memory[m.I] = (number / 100) % 10
+			m.memory[m.I+1] = (number / 10) % 10
+			m.memory[m.I+2] = number % 10
+			m.access(m.I, 3, true)
+		case 0x0055:
+			register := (opcode & 0x0F00) >> 8
+			if !m.addressable(int(register) + 1) {
+				return m.fault(IOutOfRange, opcode, address)
+			}
+			for i := uint16(0x00); i <= register; i++ {
+				m.memory[m.I+i] = m.V[i]
+			}
+			m.access(m.I, int(register)+1, true)
+			m.incrementI(register)
+		case 0x0065:
+			register := (opcode & 0x0F00) >> 8
+			if !m.addressable(int(register) + 1) {
+				return m.fault(IOutOfRange, opcode, address)
+			}
+			for i := uint16(0x00); i <= register; i++ {
+				m.V[i] = m.memory[m.I+i]
+			}
+			m.access(m.I, int(register)+1, false)
+			m.incrementI(register)
+		case 0x0075:
+			register := (opcode & 0x0F00) >> 8
+			for i := uint16(0x00); i <= register; i++ {
+				m.rpl[i] = m.V[i]
+			}
+		case 0x0085:
+			register := (opcode & 0x0F00) >> 8
+			for i := uint16(0x00); i <= register; i++ {
+				m.V[i] = m.rpl[i]
+			}
+		default:
+			return m.unknownOpcode(opcode, address, "no such instruction")
+		}
+	}
+	return nil
+}
@@ -0,0 +1,1175 @@
+package chip8
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"log"
+	"testing"
+)
+
+func TestNew(t *testing.T) {
+	c := New()
+	assert.NotNil(t, c)
+}
+
+func TestLoadProgram(t *testing.T) {
+	c := New()
+	n, err := c.LoadFile("../roms/PONG")
+	assert.NoError(t, err)
+	assert.Equal(t, 246, n, "246 bytes should be read as the game is 246 bytes long")
+	for i := bigFontAt + len(bigFontset); i < 0x200; i++ {
+		assert.Equal(t, uint8(0), c.memory[i], "Should be 0 as first 512 is where emulator resides")
+	}
+}
+
+func TestLoadProgramFailsWithWrongFile(t *testing.T) {
+	c := New()
+	_, err := c.LoadFile("../roms/FOO")
+	assert.Error(t, err)
+}
+
+func TestLoadProgramFailsWhenTooBig(t *testing.T) {
+	c := New()
+	_, err := c.Load(bytes.NewReader(make([]byte, 3585)))
+	assert.EqualError(t, err, "program is bigger than the 3584 bytes a CHIP-8 has room for")
+
+	c = New(WithVariant(VariantXOChip))
+	n, err := c.Load(bytes.NewReader(make([]byte, 3585)))
+	assert.NoError(t, err)
+	assert.Equal(t, 3585, n)
+}
+
+func TestLoadProgramFailsWhenEmpty(t *testing.T) {
+	c := New()
+	_, err := c.Load(bytes.NewReader(nil))
+	assert.Error(t, err)
+}
+
+func TestReset(t *testing.T) {
+	c := New(WithSeed(7))
+	c.LoadFile("../roms/PONG")
+	c.I = 42
+	c.Reset()
+	f := New(WithSeed(7))
+	assert.Equal(t, f, c, "After reset it should be same as new")
+}
+
+func TestReturnFromSubRoutine(t *testing.T) {
+	c := New()
+	c.stack[c.sp] = 0x30
+	c.sp = c.sp + 1
+	c.memory[0x200] = 0x00
+	c.memory[0x201] = 0xEE
+	c.Step()
+	assert.Equal(t, uint16(0x30), c.pc)
+	assert.Equal(t, uint16(0x00), c.sp)
+}
+
+func TestJumpToNNN(t *testing.T) {
+	c := New()
+	c.memory[0x200] = 0x10
+	c.memory[0x201] = 0xFF
+	c.Step()
+	assert.Equal(t, uint16(0xFF), c.pc)
+}
+
+func TestCallAddr(t *testing.T) {
+	c := New()
+	c.memory[0x200] = 0x26
+	c.memory[0x201] = 0x93
+	c.Step()
+	assert.Equal(t, uint16(0x01), c.sp)
+	assert.Equal(t, uint16(0x202), c.stack[c.sp-1])
+	assert.Equal(t, uint16(0x693), c.pc)
+}
+
+func TestSkipIfVxIsKKIsTrue(t *testing.T) {
+	c := New()
+	c.memory[0x200] = 0x3B
+	c.memory[0x201] = 0x54
+	c.V[0xB] = 0x54
+	c.Step()
+	assert.Equal(t, uint16(0x204), c.pc)
+}
+
+func TestSkipIfVxIsKKIsFalse(t *testing.T) {
+	c := New()
+	c.memory[0x200] = 0x31
+	c.memory[0x201] = 0x54
+	c.V[1] = 0x95
+	c.Step()
+	assert.Equal(t, uint16(0x202), c.pc)
+}
+
+func TestSkipIfVxIsNotKKIsTrue(t *testing.T) {
+	c := New()
+	c.memory[0x200] = 0x4B
+	c.memory[0x201] = 0x54
+	c.V[0xB] = 0x54
+	c.Step()
+	assert.Equal(t, uint16(0x202), c.pc)
+}
+
+func TestSkipIfVxIsNotKKIsFalse(t *testing.T) {
+	c := New()
+	c.memory[0x200] = 0x41
+	c.memory[0x201] = 0x54
+	c.V[0x1] = 0x95
+	c.Step()
+	assert.Equal(t, uint16(0x204), c.pc)
+}
+
+func TestSkipIfVxIsVyIsTrue(t *testing.T) {
+	c := New()
+	c.memory[0x200] = 0x53
+	c.memory[0x201] = 0xB0
+	c.V[0x3] = 0x96
+	c.V[0xB] = 0x96
+	c.Step()
+	assert.Equal(t, uint16(0x204), c.pc)
+}
+
+func TestSkipIfVxIsVyIsFalse(t *testing.T) {
+	c := New()
+	c.memory[0x200] = 0x53
+	c.memory[0x201] = 0xB0
+	c.V[0x3] = 0x94
+	c.V[0xB] = 0x96
+	c.Step()
+	assert.Equal(t, uint16(0x202), c.pc)
+}
+
+func TestSetVxToKK(t *testing.T) {
+	c := New()
+	c.memory[0x200] = 0x63
+	c.memory[0x201] = 0x94
+	c.Step()
+	assert.Equal(t, byte(0x94), c.V[0x3])
+}
+
+func TestAddByteToVx(t *testing.T) {
+	c := New()
+	c.memory[0x200] = 0x7C
+	c.memory[0x201] = 0xFE
+	c.V[0xC] = 0x1
+	c.Step()
+	assert.Equal(t, byte(0xFF), c.V[0xC])
+}
+
+func TestAddByteToVxOverflow(t *testing.T) {
+	c := New()
+	c.memory[0x200] = 0x7C
+	c.memory[0x201] = 0xFF
+	c.V[0xC] = 0x90
+	c.Step()
+	assert.Equal(t, byte(0x8f), c.V[0xC])
+}
+
+func TestVxAssignVy(t *testing.T) {
+	c := New()
+	c.memory[0x200] = 0x8A
+	c.memory[0x201] = 0xB0
+	c.V[0xB] = 0x90
+	c.Step()
+	assert.Equal(t, byte(0x90), c.V[0xA])
+}
+
+func TestVxOrVy(t *testing.T) {
+	c := New()
+	c.memory[0x200] = 0x8A
+	c.memory[0x201] = 0xC1
+	c.V[0xA] = 0x11
+	c.V[0xC] = 0x43
+	c.Step()
+	assert.Equal(t, byte(0x53), c.V[0xA])
+}
+
+func TestVxAndVy(t *testing.T) {
+	c := New()
+	c.memory[0x200] = 0x8A
+	c.memory[0x201] = 0xC2
+	c.V[0xA] = 0x34
+	c.V[0xC] = 0xD3
+	c.Step()
+	assert.Equal(t, byte(0x10), c.V[0xA])
+}
+
+func TestVxXorVy(t *testing.T) {
+	c := New()
+	c.memory[0x200] = 0x8A
+	c.memory[0x201] = 0xD3
+	c.V[0xA] = 0xA3
+	c.V[0xD] = 0x3A
+	c.Step()
+	assert.Equal(t, byte(0x99), c.V[0xA])
+}
+
+func TestAddVxVyNoOverflow(t *testing.T) {
+	c := New()
+	c.memory[0x200] = 0x8B
+	c.memory[0x201] = 0xE4
+	c.V[0xB] = 0x11
+	c.V[0xE] = 0x53
+	c.Step()
+	assert.Equal(t, byte(0x64), c.V[0xB])
+	assert.Equal(t, byte(0x0), c.V[0xF])
+}
+
+func TestAddVxVyOverflow(t *testing.T) {
+	c := New()
+	c.memory[0x200] = 0x8B
+	c.memory[0x201] = 0xF4
+	c.V[0xB] = 0xAA
+	c.V[0xF] = 0xFF
+	c.Step()
+	assert.Equal(t, byte(0xA9), c.V[0xB])
+	assert.Equal(t, byte(0x1), c.V[0xF])
+}
+
+func TestSubVxVyNoBorrow(t *testing.T) {
+	c := New()
+	c.memory[0x200] = 0x87
+	c.memory[0x201] = 0x65
+	c.V[0x7] = 0x99
+	c.V[0x6] = 0x33
+	c.Step()
+	assert.Equal(t, byte(0x66), c.V[0x7])
+	assert.Equal(t, byte(0x1), c.V[0xF])
+}
+
+func TestSubVxVyBorrow(t *testing.T) {
+	c := New()
+	c.memory[0x200] = 0x87
+	c.memory[0x201] = 0x65
+	c.V[0x7] = 0x98
+	c.V[0x6] = 0xAA
+	c.Step()
+	assert.Equal(t, byte(0xEE), c.V[0x7])
+	assert.Equal(t, byte(0x0), c.V[0xF])
+}
+
+func TestSubVxVyEqualIsNoBorrow(t *testing.T) {
+	c := New()
+	c.memory[0x200] = 0x87
+	c.memory[0x201] = 0x65
+	c.V[0x7] = 0x42
+	c.V[0x6] = 0x42
+	c.Step()
+	assert.Equal(t, byte(0x0), c.V[0x7])
+	assert.Equal(t, byte(0x1), c.V[0xF])
+}
+
+func TestArithmeticFlagWinsOverVF(t *testing.T) {
+	// 8FY4, 8FY5 and 8FY7 leave the flag in VF, not the result.
+	c := New()
+	copy(c.memory[0x200:], []byte{0x8F, 0x14, 0x8F, 0x15, 0x8F, 0x17})
+	c.V[0xF] = 0xFF
+	c.V[0x1] = 0x01
+	c.Step()
+	assert.Equal(t, byte(0x1), c.V[0xF])
+	c.V[0xF] = 0x05
+	c.Step()
+	assert.Equal(t, byte(0x1), c.V[0xF])
+	c.V[0xF] = 0x05
+	c.Step()
+	assert.Equal(t, byte(0x0), c.V[0xF])
+}
+
+func TestAddVxVyCarryWithSmallResult(t *testing.T) {
+	c := New()
+	c.memory[0x200] = 0x81
+	c.memory[0x201] = 0x24
+	c.V[0x1] = 0xFF
+	c.V[0x2] = 0x01
+	c.Step()
+	assert.Equal(t, byte(0x00), c.V[0x1])
+	assert.Equal(t, byte(0x1), c.V[0xF])
+}
+
+func TestShrVxLsbIsOne(t *testing.T) {
+	c := New()
+	c.memory[0x200] = 0x8B
+	c.memory[0x201] = 0xC6
+	c.V[0xB] = 0x99
+	c.Step()
+	assert.Equal(t, byte(0x1), c.V[0xF])
+	assert.Equal(t, byte(0x4C), c.V[0xB])
+}
+
+func TestShrVxLsbIsNotOne(t *testing.T) {
+	c := New()
+	c.memory[0x200] = 0x8B
+	c.memory[0x201] = 0xC6
+	c.V[0xB] = 0x98
+	c.Step()
+	assert.Equal(t, byte(0x0), c.V[0xF])
+	assert.Equal(t, byte(0x4C), c.V[0xB])
+}
+
+func TestVySubVxNoBorrow(t *testing.T) {
+	c := New()
+	c.memory[0x200] = 0x8B
+	c.memory[0x201] = 0xC7
+	c.V[0xB] = 0x89
+	c.V[0xC] = 0x95
+	c.Step()
+	assert.Equal(t, byte(0x1), c.V[0xF])
+	assert.Equal(t, byte(0xC), c.V[0xB])
+}
+
+func TestVySubVxBorrow(t *testing.T) {
+	c := New()
+	c.memory[0x200] = 0x8B
+	c.memory[0x201] = 0xC7
+	c.V[0xB] = 0x01
+	c.V[0xC] = 0x00
+	c.Step()
+	assert.Equal(t, byte(0x0), c.V[0xF])
+	assert.Equal(t, byte(0xFF), c.V[0xB])
+}
+
+func TestShlVxMsbIsOne(t *testing.T) {
+	c := New()
+	c.memory[0x200] = 0x8A
+	c.memory[0x201] = 0xCE
+	c.V[0xA] = 0xAB
+	c.Step()
+	assert.Equal(t, byte(0x01), c.V[0xF])
+	assert.Equal(t, byte(0x56), c.V[0xA])
+}
+
+func TestShlVxMsbIsNotOne(t *testing.T) {
+	c := New()
+	c.memory[0x200] = 0x8A
+	c.memory[0x201] = 0xCE
+	c.V[0xA] = 0x3B
+	c.Step()
+	assert.Equal(t, byte(0x00), c.V[0xF])
+	assert.Equal(t, byte(0x76), c.V[0xA])
+}
+
+func TestSneVxVyNotEqual(t *testing.T) {
+	c := New()
+	c.memory[0x200] = 0x9B
+	c.memory[0x201] = 0xD0
+	c.V[0xB] = 0xDD
+	c.V[0xD] = 0xCC
+	c.Step()
+	assert.Equal(t, uint16(0x204), c.pc)
+}
+
+func TestSneVxVyEqual(t *testing.T) {
+	c := New()
+	c.memory[0x200] = 0x9B
+	c.memory[0x201] = 0xD0
+	c.V[0xB] = 0xDD
+	c.V[0xD] = 0xDD
+	c.Step()
+	assert.Equal(t, uint16(0x202), c.pc)
+}
+
+func TestLoadAddress(t *testing.T) {
+	c := New()
+	c.memory[0x200] = 0xAB
+	c.memory[0x201] = 0x34
+	c.Step()
+	assert.Equal(t, uint16(0xB34), c.I)
+}
+
+func TestJumpToLocationPlusV0(t *testing.T) {
+	c := New()
+	c.memory[0x200] = 0xB1
+	c.memory[0x201] = 0x94
+	c.V[0x0] = 0x6
+	c.Step()
+	assert.Equal(t, uint16(0x19A), c.pc)
+}
+
+func TestSetVxToRandomNumberAndKK(t *testing.T) {
+	c := New(WithSeed(42))
+	c.memory[0x200] = 0xCA
+	c.memory[0x201] = 0x0F
+	c.Step()
+	assert.Equal(t, uint16(0x202), c.pc)
+	assert.Equal(t, byte(0x00), c.V[0xA]&0xF0)
+
+	// The same seed makes the same number.
+	d := New(WithSeed(42))
+	d.memory[0x200] = 0xCA
+	d.memory[0x201] = 0x0F
+	d.Step()
+	assert.Equal(t, c.V[0xA], d.V[0xA])
+}
+
+func TestLoadFontSet(t *testing.T) {
+	c := New()
+	c.loadFontSet()
+	for i := 0x00; i < 0x50; i++ {
+		assert.Equal(t, fontset[i], c.memory[i])
+	}
+	for i, b := range bigFontset {
+		assert.Equal(t, b, c.memory[bigFontAt+i])
+	}
+}
+
+func TestClearDisplay(t *testing.T) {
+	c := New()
+	c.memory[0x200] = 0x00
+	c.memory[0x201] = 0xE0
+	c.display[0][0] = 0x1
+	c.display[9][23] = 0x1
+	c.Step()
+	for x := 0x00; x < 0x20; x++ {
+		for y := 0x00; y < 0x40; y++ {
+			assert.Equal(t, byte(0), c.display[x][y])
+		}
+	}
+}
+
+func TestDXYNNoWrapAroundNoCollision(t *testing.T) {
+	c := New()
+	c.memory[0x200] = 0xD3
+	c.memory[0x201] = 0xD2
+	c.I = 0x300
+	c.V[0x3] = 0
+	c.V[0xD] = 0
+	c.memory[0x300] = 0x11
+	c.memory[0x301] = 0x88
+	c.clearDisplay()
+	c.Step()
+	assert.Equal(t, byte(0x00), c.V[0xF])
+	assert.Equal(t, byte(0x01), c.display[0][3])
+	assert.Equal(t, byte(0x01), c.display[0][7])
+	assert.Equal(t, byte(0x01), c.display[1][0])
+	assert.Equal(t, byte(0x01), c.display[1][4])
+}
+
+func TestDXYNNoWrapAroundYesCollision(t *testing.T) {
+	c := New()
+	c.memory[0x200] = 0xD3
+	c.memory[0x201] = 0xD2
+	c.I = 0x300
+	c.memory[0x300] = 0x11
+	c.memory[0x301] = 0x88
+	c.V[0x3] = 0
+	c.V[0xD] = 0
+	c.clearDisplay()
+	c.display[0][3] = 0x01
+	c.Step()
+	assert.Equal(t, byte(0x01), c.V[0xF])
+	assert.Equal(t, byte(0x00), c.display[0][3])
+	assert.Equal(t, byte(0x01), c.display[0][7])
+	assert.Equal(t, byte(0x01), c.display[1][0])
+	assert.Equal(t, byte(0x01), c.display[1][4])
+}
+
+func TestDXYNWithWrapAroundNoCollision(t *testing.T) {
+	c := New()
+	c.memory[0x200] = 0xD3
+	c.memory[0x201] = 0xD2
+	c.I = 0x300
+	c.memory[0x300] = 0x11
+	c.memory[0x301] = 0x88
+	c.V[0x3] = 0x3F
+	c.V[0xD] = 0x1F
+	c.clearDisplay()
+	c.Step()
+	assert.Equal(t, byte(0x00), c.V[0xF])
+	assert.Equal(t, byte(0x01), c.display[31][2])
+	assert.Equal(t, byte(0x01), c.display[31][6])
+	assert.Equal(t, byte(0x01), c.display[0][3])
+	assert.Equal(t, byte(0x01), c.display[0][63])
+}
+
+func TestDXYNWithWrapAroundYesCollision(t *testing.T) {
+	c := New()
+	c.memory[0x200] = 0xD3
+	c.memory[0x201] = 0xD2
+	c.I = 0x300
+	c.memory[0x300] = 0x11
+	c.memory[0x301] = 0x88
+	c.V[0x3] = 0x3F
+	c.V[0xD] = 0x1F
+	c.clearDisplay()
+	c.display[31][2] = 0x01
+	c.Step()
+	assert.Equal(t, byte(0x01), c.V[0xF])
+	assert.Equal(t, byte(0x00), c.display[31][2])
+	assert.Equal(t, byte(0x01), c.display[31][6])
+	assert.Equal(t, byte(0x01), c.display[0][3])
+	assert.Equal(t, byte(0x01), c.display[0][63])
+}
+
+func TestSetDelayTimer(t *testing.T) {
+	c := New()
+	c.memory[0x200] = 0xFD
+	c.memory[0x201] = 0x15
+	c.V[0xD] = 0x33
+	c.Step()
+	assert.Equal(t, byte(0x33), c.delayTimer)
+}
+
+func TestSetVxToDelayTimer(t *testing.T) {
+	c := New()
+	c.memory[0x200] = 0xFD
+	c.memory[0x201] = 0x07
+	c.delayTimer = 0x44
+	c.Step()
+	assert.Equal(t, byte(0x44), c.V[0xD])
+}
+
+func TestSetSoundTimer(t *testing.T) {
+	c := New()
+	c.memory[0x200] = 0xFD
+	c.memory[0x201] = 0x18
+	c.V[0xD] = 0x99
+	c.Step()
+	assert.Equal(t, byte(0x99), c.soundTimer)
+}
+
+func TestSetIToIPlusVx(t *testing.T) {
+	c := New()
+	c.memory[0x200] = 0xFD
+	c.memory[0x201] = 0x1E
+	c.I = 0x32
+	c.V[0xD] = 0x33
+	c.Step()
+	assert.Equal(t, uint16(0x65), c.I)
+}
+
+func TestSetIToLocationOfDigit(t *testing.T) {
+	c := New()
+	c.memory[0x200] = 0xFD
+	c.memory[0x201] = 0x29
+	c.V[0xD] = 0x7
+	c.Step()
+	assert.Equal(t, uint16(0x23), c.I)
+}
+
+func TestSetBCDRepresentation(t *testing.T) {
+	c := New()
+	c.memory[0x200] = 0xFB
+	c.memory[0x201] = 0x33
+	c.I = 0x90
+	c.V[0xB] = 0x7B
+	c.Step()
+	assert.Equal(t, byte(0x1), c.memory[c.I])
+	assert.Equal(t, byte(0x2), c.memory[c.I+1])
+	assert.Equal(t, byte(0x3), c.memory[c.I+2])
+}
+
+func TestLoadRegisterToMemory(t *testing.T) {
+	c := New()
+	c.memory[0x200] = 0xF3
+	c.memory[0x201] = 0x55
+	c.I = 0x90
+	c.V[0x0] = 0x55
+	c.V[0x1] = 0x93
+	c.V[0x2] = 0x42
+	c.V[0x3] = 0x2A
+	c.Step()
+	assert.Equal(t, byte(0x55), c.memory[c.I])
+	assert.Equal(t, byte(0x93), c.memory[c.I+1])
+	assert.Equal(t, byte(0x42), c.memory[c.I+2])
+	assert.Equal(t, byte(0x2A), c.memory[c.I+3])
+}
+
+func TestLoadMemoryToRegisters(t *testing.T) {
+	c := New()
+	c.memory[0x200] = 0xF2
+	c.memory[0x201] = 0x65
+	c.I = 0x90
+	c.memory[c.I] = 0xff
+	c.memory[c.I+1] = 0x42
+	c.memory[c.I+2] = 0x34
+	c.Step()
+	assert.Equal(t, byte(0xff), c.V[0x00])
+	assert.Equal(t, byte(0x42), c.V[0x01])
+	assert.Equal(t, byte(0x34), c.V[0x02])
+}
+
+func TestSkipIfVxIsPressedIsTrue(t *testing.T) {
+	c := New()
+	c.memory[0x200] = 0xED
+	c.memory[0x201] = 0x9E
+	c.V[0xD] = 0xD
+	c.keys[0xD] = 0x01
+	c.Step()
+	assert.Equal(t, uint16(0x204), c.pc)
+}
+
+func TestSkipIfVxIsPressedIsFalse(t *testing.T) {
+	c := New()
+	c.memory[0x200] = 0xED
+	c.memory[0x201] = 0x9E
+	c.V[0xD] = 0xD
+	c.Step()
+	assert.Equal(t, uint16(0x202), c.pc)
+}
+
+func TestSkipifVxIsNotPressedIsTrue(t *testing.T) {
+	c := New()
+	c.memory[0x200] = 0xEB
+	c.memory[0x201] = 0xA1
+	c.V[0xB] = 0xA
+	c.keys[0xA] = 0x00
+	c.Step()
+	assert.Equal(t, uint16(0x204), c.pc)
+}
+
+func TestSkipifVxIsNotPressedIsFalse(t *testing.T) {
+	c := New()
+	c.memory[0x200] = 0xEB
+	c.memory[0x201] = 0xA1
+	c.V[0xB] = 0xA
+	c.keys[0xA] = 0x01
+	c.Step()
+	assert.Equal(t, uint16(0x202), c.pc)
+}
+
+func TestTickTimers(t *testing.T) {
+	c := New()
+	c.delayTimer = 55
+	c.soundTimer = 1
+	c.TickTimers()
+	assert.Equal(t, uint16(0x200), c.pc)
+	assert.Equal(t, byte(54), c.delayTimer)
+	assert.Equal(t, byte(0), c.soundTimer)
+	c.TickTimers()
+	assert.Equal(t, byte(53), c.delayTimer)
+	assert.Equal(t, byte(0), c.soundTimer)
+}
+
+func TestRunFrameTicksTimersOnce(t *testing.T) {
+	c := New()
+	for i := 0; i < 20; i += 2 {
+		c.memory[0x200+i] = 0x70
+		c.memory[0x201+i] = 0x01
+	}
+	c.delayTimer = 55
+	c.soundTimer = 2
+	n, err := c.RunFrame(10)
+	assert.NoError(t, err)
+	assert.Equal(t, 10, n)
+	assert.Equal(t, uint16(0x214), c.pc)
+	assert.Equal(t, byte(10), c.V[0x0])
+	assert.Equal(t, byte(54), c.delayTimer)
+	assert.Equal(t, byte(1), c.soundTimer)
+}
+
+func TestDelayTimerIndependentOfSpeed(t *testing.T) {
+	// A loop waiting on the delay timer takes as many frames to finish
+	// however many instructions are executed each frame.
+	for _, ipf := range []int{7, 30, 500} {
+		c := New()
+		copy(c.memory[0x200:], []byte{
+			0x60, 0x3C, // LD V0, 60
+			0xF0, 0x15, // LD DT, V0
+			0xF1, 0x07, // LD V1, DT
+			0x31, 0x00, // SE V1, 0
+			0x12, 0x04, // JP 0x204
+			0x12, 0x0A, // JP 0x20A
+		})
+		frames := 0
+		for c.pc != 0x20A {
+			_, err := c.RunFrame(ipf)
+			assert.NoError(t, err)
+			frames++
+		}
+		assert.Equal(t, 61, frames, "ipf %d", ipf)
+	}
+}
+
+func TestWaitForKeyPressAndRelease(t *testing.T) {
+	c := New()
+	c.memory[0x200] = 0xFA
+	c.memory[0x201] = 0x0A
+	c.Step()
+	assert.True(t, c.WaitingForKey())
+	assert.Equal(t, uint16(0x200), c.PC())
+
+	c.SetKey(0x7, true)
+	c.Step()
+	c.Step()
+	assert.True(t, c.WaitingForKey(), "the key hasn't been let go")
+	assert.Equal(t, uint16(0x200), c.PC())
+
+	c.SetKey(0x7, false)
+	c.Step()
+	assert.False(t, c.WaitingForKey())
+	assert.Equal(t, byte(0x7), c.V[0xA])
+	assert.Equal(t, uint16(0x202), c.PC())
+}
+
+func TestWaitForKeyIgnoresKeysAlreadyHeld(t *testing.T) {
+	c := New()
+	copy(c.memory[0x200:], []byte{0xF1, 0x0A})
+	c.SetKey(0x3, true)
+	c.Step()
+	c.Step()
+	c.SetKey(0x3, false)
+	c.Step()
+	assert.True(t, c.WaitingForKey(), "3 was down before the wait")
+
+	c.SetKey(0x3, true)
+	c.Step()
+	c.SetKey(0x3, false)
+	c.Step()
+	assert.False(t, c.WaitingForKey())
+	assert.Equal(t, byte(0x3), c.V[0x1])
+}
+
+func TestTimersRunWhileWaitingForKey(t *testing.T) {
+	c := New()
+	copy(c.memory[0x200:], []byte{0xF0, 0x0A})
+	c.SetTimers(10, 5)
+	for i := 0; i < 3; i++ {
+		_, err := c.RunFrame(10)
+		assert.NoError(t, err)
+	}
+	assert.True(t, c.WaitingForKey())
+	assert.Equal(t, byte(7), c.DelayTimer())
+	assert.Equal(t, byte(2), c.SoundTimer())
+}
+
+func TestUnknownOpcodeHalts(t *testing.T) {
+	c := New()
+	c.memory[0x200] = 0x5A
+	c.memory[0x201] = 0xB1
+	err := c.Step()
+	if assert.IsType(t, &OpcodeError{}, err) {
+		opErr := err.(*OpcodeError)
+		assert.Equal(t, uint16(0x5AB1), opErr.Opcode)
+		assert.Equal(t, uint16(0x200), opErr.Address)
+	}
+	assert.Equal(t, uint16(0x200), c.pc)
+}
+
+func TestMachineCodeRoutineIsUnknown(t *testing.T) {
+	c := New()
+	c.memory[0x200] = 0x02
+	c.memory[0x201] = 0x34
+	assert.Error(t, c.Step())
+}
+
+func TestUnknownOpcodeIgnored(t *testing.T) {
+	c := New(WithOpcodePolicy(IgnoreUnknown))
+	c.memory[0x200] = 0xE0
+	c.memory[0x201] = 0xFF
+	assert.NoError(t, c.Step())
+	assert.Equal(t, uint16(0x202), c.pc)
+}
+
+func TestUnknownOpcodeLogged(t *testing.T) {
+	var logged bytes.Buffer
+	c := New(WithOpcodePolicy(LogUnknown), WithLogger(log.New(&logged, "", 0)))
+	c.memory[0x200] = 0xF0
+	c.memory[0x201] = 0xFF
+	assert.NoError(t, c.Step())
+	assert.Equal(t, uint16(0x202), c.pc)
+	assert.Contains(t, logged.String(), "F0FF")
+}
+
+func TestRunStopsOnUnknownOpcode(t *testing.T) {
+	c := New()
+	c.memory[0x200] = 0x8A
+	c.memory[0x201] = 0xB8
+	c.delayTimer = 5
+	n, err := c.RunFrame(10)
+	assert.Error(t, err)
+	assert.Equal(t, 0, n)
+	assert.Equal(t, byte(5), c.delayTimer)
+}
+
+func TestCallFaultsOnStackOverflow(t *testing.T) {
+	c := New()
+	c.memory[0x200] = 0x23
+	c.memory[0x201] = 0x00
+	c.sp = 16
+	err := c.Step()
+	if assert.IsType(t, &Fault{}, err) {
+		fault := err.(*Fault)
+		assert.Equal(t, StackOverflow, fault.Kind)
+		assert.Equal(t, uint16(0x2300), fault.Opcode)
+		assert.Equal(t, uint16(0x200), fault.Address)
+		assert.Equal(t, c, fault.State)
+	}
+	assert.Equal(t, uint16(0x200), c.pc)
+	assert.Equal(t, uint16(16), c.sp)
+}
+
+func TestReturnFaultsOnStackUnderflow(t *testing.T) {
+	c := New()
+	c.memory[0x200] = 0x00
+	c.memory[0x201] = 0xEE
+	err := c.Step()
+	if assert.IsType(t, &Fault{}, err) {
+		assert.Equal(t, StackUnderflow, err.(*Fault).Kind)
+	}
+	assert.Equal(t, uint16(0x200), c.pc)
+}
+
+func TestFetchFaultsWhenPCOutOfRange(t *testing.T) {
+	c := New()
+	c.pc = 0xFFF
+	err := c.Step()
+	if assert.IsType(t, &Fault{}, err) {
+		assert.Equal(t, PCOutOfRange, err.(*Fault).Kind)
+	}
+}
+
+func TestMemoryInstructionsFaultWhenIOutOfRange(t *testing.T) {
+	for _, opcode := range []uint16{0xD015, 0xF033, 0xF355, 0xF365} {
+		c := New()
+		c.memory[0x200] = byte(opcode >> 8)
+		c.memory[0x201] = byte(opcode)
+		c.I = 0xFFE
+		err := c.Step()
+		if assert.IsType(t, &Fault{}, err, "%04X", opcode) {
+			assert.Equal(t, IOutOfRange, err.(*Fault).Kind)
+		}
+		assert.Equal(t, uint16(0x200), c.pc)
+	}
+}
+
+func TestSkipIfPressedMasksKeyIndex(t *testing.T) {
+	c := New()
+	c.memory[0x200] = 0xED
+	c.memory[0x201] = 0x9E
+	c.V[0xD] = 0x1D
+	c.keys[0xD] = 0x01
+	assert.NoError(t, c.Step())
+	assert.Equal(t, uint16(0x204), c.pc)
+}
+
+func TestVFResetQuirk(t *testing.T) {
+	c := New(WithVariant(VariantChip8), WithQuirks(QuirksCosmacVIP))
+	c.memory[0x200] = 0x8A
+	c.memory[0x201] = 0xC1
+	c.V[0xA] = 0x11
+	c.V[0xC] = 0x43
+	c.V[0xF] = 0x01
+	c.Step()
+	assert.Equal(t, byte(0x53), c.V[0xA])
+	assert.Equal(t, byte(0x00), c.V[0xF])
+}
+
+func TestShiftUsesVYQuirk(t *testing.T) {
+	c := New(WithVariant(VariantChip8), WithQuirks(QuirksCosmacVIP))
+	c.memory[0x200] = 0x8A
+	c.memory[0x201] = 0xB6
+	c.memory[0x202] = 0x8A
+	c.memory[0x203] = 0xBE
+	c.V[0xA] = 0xFF
+	c.V[0xB] = 0x81
+	c.Step()
+	assert.Equal(t, byte(0x40), c.V[0xA])
+	assert.Equal(t, byte(0x01), c.V[0xF])
+	c.Step()
+	assert.Equal(t, byte(0x02), c.V[0xA])
+	assert.Equal(t, byte(0x01), c.V[0xF])
+}
+
+func TestMemoryIncrementQuirk(t *testing.T) {
+	for increment, want := range map[MemoryIncrement]uint16{
+		IncrementNone:   0x300,
+		IncrementX:      0x302,
+		IncrementXPlus1: 0x303,
+	} {
+		c := New(WithQuirks(Quirks{MemoryIncrement: increment}))
+		c.memory[0x200] = 0xF2
+		c.memory[0x201] = 0x55
+		c.memory[0x202] = 0xF2
+		c.memory[0x203] = 0x65
+		c.I = 0x300
+		c.Step()
+		assert.Equal(t, want, c.I)
+		c.Step()
+		assert.Equal(t, want+want-0x300, c.I)
+	}
+}
+
+func TestJumpUsesVXQuirk(t *testing.T) {
+	c := New(WithVariant(VariantSChip), WithQuirks(QuirksSChip11))
+	c.memory[0x200] = 0xB3
+	c.memory[0x201] = 0x10
+	c.V[0x0] = 0x6
+	c.V[0x3] = 0x4
+	c.Step()
+	assert.Equal(t, uint16(0x314), c.pc)
+}
+
+func TestDXYNClipSpritesQuirk(t *testing.T) {
+	c := New(WithVariant(VariantChip8), WithQuirks(QuirksCosmacVIP))
+	c.memory[0x200] = 0xD3
+	c.memory[0x201] = 0xD2
+	c.I = 0x300
+	c.memory[0x300] = 0x11
+	c.memory[0x301] = 0x88
+	c.V[0x3] = 0x3F
+	c.V[0xD] = 0x1F
+	c.Step()
+	assert.Equal(t, byte(0x00), c.display[31][2])
+	assert.Equal(t, byte(0x00), c.display[0][3])
+	assert.Equal(t, byte(0x00), c.display[0][63])
+}
+
+func TestDXYNStartsOnScreen(t *testing.T) {
+	c := New(WithVariant(VariantChip8), WithQuirks(QuirksCosmacVIP))
+	c.memory[0x200] = 0xD3
+	c.memory[0x201] = 0xD1
+	c.I = 0x300
+	c.memory[0x300] = 0x80
+	c.V[0x3] = 0xC5
+	c.V[0xD] = 0x41
+	c.Step()
+	assert.Equal(t, byte(0x01), c.display[1][5])
+}
+
+func TestHighAndLowResolution(t *testing.T) {
+	c := New(WithVariant(VariantSChip), WithQuirks(QuirksSChip11))
+	c.memory[0x200] = 0x00
+	c.memory[0x201] = 0xFF
+	c.memory[0x202] = 0x00
+	c.memory[0x203] = 0xFE
+	c.display[0][0] = 0x01
+	c.Step()
+	assert.True(t, c.hires)
+	assert.Equal(t, 128, c.screenWidth())
+	assert.Equal(t, 64, c.screenHeight())
+	assert.Equal(t, byte(0x00), c.display[0][0])
+	c.Step()
+	assert.False(t, c.hires)
+	assert.Equal(t, 64, c.screenWidth())
+	assert.Equal(t, 32, c.screenHeight())
+}
+
+func TestScrollDown(t *testing.T) {
+	c := New(WithVariant(VariantSChip), WithQuirks(QuirksSChip11))
+	c.memory[0x200] = 0x00
+	c.memory[0x201] = 0xC3
+	c.display[0][5] = 0x01
+	c.display[31][5] = 0x01
+	c.Step()
+	assert.Equal(t, byte(0x00), c.display[0][5])
+	assert.Equal(t, byte(0x01), c.display[3][5])
+	assert.Equal(t, byte(0x00), c.display[31][5])
+	assert.Equal(t, byte(0x00), c.display[34][5])
+}
+
+func TestScrollRightAndLeft(t *testing.T) {
+	c := New(WithVariant(VariantSChip), WithQuirks(QuirksSChip11))
+	c.hires = true
+	c.memory[0x200] = 0x00
+	c.memory[0x201] = 0xFB
+	c.memory[0x202] = 0x00
+	c.memory[0x203] = 0xFC
+	c.display[10][0] = 0x01
+	c.display[10][127] = 0x01
+	c.Step()
+	assert.Equal(t, byte(0x00), c.display[10][0])
+	assert.Equal(t, byte(0x01), c.display[10][4])
+	assert.Equal(t, byte(0x00), c.display[10][127])
+	c.Step()
+	assert.Equal(t, byte(0x01), c.display[10][0])
+	assert.Equal(t, byte(0x00), c.display[10][4])
+}
+
+func TestExit(t *testing.T) {
+	c := New(WithVariant(VariantSChip), WithQuirks(QuirksSChip11))
+	c.memory[0x200] = 0x00
+	c.memory[0x201] = 0xFD
+	assert.Equal(t, ErrExit, c.Step())
+	assert.Equal(t, uint16(0x200), c.pc)
+}
+
+func TestDXY0DrawsBigSprite(t *testing.T) {
+	c := New(WithVariant(VariantSChip), WithQuirks(QuirksSChip11))
+	c.hires = true
+	c.memory[0x200] = 0xD0
+	c.memory[0x201] = 0x10
+	c.I = 0x300
+	c.memory[0x300] = 0x80
+	c.memory[0x301] = 0x01
+	c.memory[0x31E] = 0x80
+	c.memory[0x31F] = 0x01
+	c.V[0x0] = 100
+	c.V[0x1] = 40
+	c.Step()
+	assert.Equal(t, byte(0x00), c.V[0xF])
+	assert.Equal(t, byte(0x01), c.display[40][100])
+	assert.Equal(t, byte(0x01), c.display[40][115])
+	assert.Equal(t, byte(0x01), c.display[55][100])
+	assert.Equal(t, byte(0x01), c.display[55][115])
+	assert.Equal(t, byte(0x00), c.display[41][100])
+}
+
+func TestSetIToLocationOfBigDigit(t *testing.T) {
+	c := New(WithVariant(VariantSChip), WithQuirks(QuirksSChip11))
+	c.memory[0x200] = 0xFD
+	c.memory[0x201] = 0x30
+	c.V[0xD] = 0x7
+	c.Step()
+	assert.Equal(t, uint16(bigFontAt+70), c.I)
+}
+
+func TestSaveAndLoadRPLFlags(t *testing.T) {
+	c := New(WithVariant(VariantSChip), WithQuirks(QuirksSChip11))
+	c.memory[0x200] = 0xF2
+	c.memory[0x201] = 0x75
+	c.memory[0x202] = 0xF2
+	c.memory[0x203] = 0x85
+	c.V[0x0] = 0x11
+	c.V[0x1] = 0x22
+	c.V[0x2] = 0x33
+	c.Step()
+	c.V[0x0], c.V[0x1], c.V[0x2] = 0, 0, 0
+	c.Step()
+	assert.Equal(t, byte(0x11), c.V[0x0])
+	assert.Equal(t, byte(0x22), c.V[0x1])
+	assert.Equal(t, byte(0x33), c.V[0x2])
+}
+
+func TestSuperChipInstructionsNeedSuperChip(t *testing.T) {
+	for _, opcode := range []uint16{0x00FF, 0x00C2, 0xF130, 0xF275} {
+		c := New()
+		c.memory[0x200] = byte(opcode >> 8)
+		c.memory[0x201] = byte(opcode)
+		assert.IsType(t, &OpcodeError{}, c.Step(), "%04X", opcode)
+	}
+}
+
+func TestXOChipInstructionsNeedXOChip(t *testing.T) {
+	for _, opcode := range []uint16{0xF000, 0x5122, 0x5123, 0xF201, 0xF002, 0xF13A, 0x00D1} {
+		c := New(WithVariant(VariantSChip), WithQuirks(QuirksSChip11))
+		c.memory[0x200] = byte(opcode >> 8)
+		c.memory[0x201] = byte(opcode)
+		assert.IsType(t, &OpcodeError{}, c.Step(), "%04X", opcode)
+	}
+}
+
+func TestLoadLongAddress(t *testing.T) {
+	c := New(WithVariant(VariantXOChip), WithQuirks(QuirksXOChip))
+	c.memory[0x200] = 0xF0
+	c.memory[0x201] = 0x00
+	c.memory[0x202] = 0xBE
+	c.memory[0x203] = 0xEF
+	c.Step()
+	assert.Equal(t, uint16(0xBEEF), c.I)
+	assert.Equal(t, uint16(0x204), c.pc)
+}
+
+func TestSkipOverLongAddress(t *testing.T) {
+	c := New(WithVariant(VariantXOChip), WithQuirks(QuirksXOChip))
+	c.memory[0x200] = 0x30
+	c.memory[0x201] = 0x00
+	c.memory[0x202] = 0xF0
+	c.memory[0x203] = 0x00
+	c.Step()
+	assert.Equal(t, uint16(0x206), c.pc)
+}
+
+func TestSaveAndLoadRegisterRange(t *testing.T) {
+	c := New(WithVariant(VariantXOChip), WithQuirks(QuirksXOChip))
+	c.memory[0x200] = 0x52
+	c.memory[0x201] = 0x42
+	c.memory[0x202] = 0x57
+	c.memory[0x203] = 0x53
+	c.I = 0x400
+	c.V[0x2], c.V[0x3], c.V[0x4] = 0x22, 0x33, 0x44
+	c.Step()
+	assert.Equal(t, []byte{0x22, 0x33, 0x44}, c.memory[0x400:0x403])
+	assert.Equal(t, uint16(0x400), c.I)
+	c.Step()
+	assert.Equal(t, byte(0x44), c.V[0x5])
+	assert.Equal(t, byte(0x33), c.V[0x6])
+	assert.Equal(t, byte(0x22), c.V[0x7])
+}
+
+func TestDrawOnBothPlanes(t *testing.T) {
+	c := New(WithVariant(VariantXOChip), WithQuirks(QuirksXOChip))
+	c.memory[0x200] = 0xF3
+	c.memory[0x201] = 0x01
+	c.memory[0x202] = 0xD0
+	c.memory[0x203] = 0x01
+	c.I = 0x300
+	c.memory[0x300] = 0x80
+	c.memory[0x301] = 0xC0
+	c.Step()
+	c.Step()
+	assert.Equal(t, byte(0x03), c.display[0][0])
+	assert.Equal(t, byte(0x02), c.display[0][1])
+}
+
+func TestClearOnlySelectedPlane(t *testing.T) {
+	c := New(WithVariant(VariantXOChip), WithQuirks(QuirksXOChip))
+	c.memory[0x200] = 0xF2
+	c.memory[0x201] = 0x01
+	c.memory[0x202] = 0x00
+	c.memory[0x203] = 0xE0
+	c.display[4][4] = 0x03
+	c.Step()
+	c.Step()
+	assert.Equal(t, byte(0x01), c.display[4][4])
+}
+
+func TestLoadAudioPatternAndPitch(t *testing.T) {
+	c := New(WithVariant(VariantXOChip), WithQuirks(QuirksXOChip))
+	c.memory[0x200] = 0xF0
+	c.memory[0x201] = 0x02
+	c.memory[0x202] = 0xF4
+	c.memory[0x203] = 0x3A
+	c.I = 0x300
+	for i := 0; i < patternSize; i++ {
+		c.memory[0x300+i] = byte(i)
+	}
+	c.V[0x4] = 0x70
+	c.Step()
+	c.Step()
+	assert.Equal(t, byte(0x0F), c.pattern[0xF])
+	assert.Equal(t, byte(0x70), c.pitch)
+}
+
+func TestXOChipAddressesAllMemory(t *testing.T) {
+	c := New(WithVariant(VariantXOChip), WithQuirks(QuirksXOChip))
+	c.pc = 0xFFF0
+	c.memory[0xFFF0] = 0x60
+	c.memory[0xFFF1] = 0x42
+	assert.NoError(t, c.Step())
+	assert.Equal(t, byte(0x42), c.V[0x0])
+
+	c = New()
+	c.pc = 0x1000
+	assert.IsType(t, &Fault{}, c.Step())
+}
+
+func TestStateAccessors(t *testing.T) {
+	c := New(WithVariant(VariantSChip))
+	c.memory[0x200] = 0x23
+	c.memory[0x201] = 0x00
+	c.memory[0x300] = 0x00
+	c.memory[0x301] = 0xFF
+	c.Step()
+	c.Step()
+	assert.Equal(t, VariantSChip, c.Variant())
+	assert.Equal(t, uint16(0x302), c.PC())
+	assert.Equal(t, 1, c.SP())
+	assert.Equal(t, []uint16{0x202}, c.Stack())
+	assert.Equal(t, 128, c.Width())
+	assert.Equal(t, 64, c.Height())
+	assert.Len(t, c.Memory(), 0x1000)
+	c.Memory()[0x400] = 0x42
+	assert.Equal(t, byte(0x42), c.memory[0x400])
+}
+
+func TestDebuggerSetters(t *testing.T) {
+	c := New()
+	c.SetPC(0x300)
+	assert.Equal(t, uint16(0x300), c.PC())
+	assert.NoError(t, c.SetSP(16))
+	assert.Equal(t, 16, c.SP())
+	assert.Error(t, c.SetSP(17))
+	c.SetTimers(3, 4)
+	assert.Equal(t, byte(3), c.DelayTimer())
+	assert.Equal(t, byte(4), c.SoundTimer())
+}
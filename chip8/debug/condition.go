@@ -0,0 +1,146 @@
+package debug
+
+import (
+	"fmt"
+	"github.com/h4ck3rk3y/go-8/chip8"
+	"strconv"
+	"strings"
+)
+
+// Condition is a test of the machine's state for a conditional breakpoint,
+// such as "v3 == 5" or "i >= 0x300 && vf != 0".
+type Condition struct {
+	text        string
+	comparisons []comparison // all of which have to hold
+}
+
+type comparison struct {
+	left, right operand
+	operator    string
+}
+
+// operand reads a value from the machine.
+type operand func(m *chip8.Machine) int
+
+// operators are the comparisons a condition can make, longest first so
+// that <= isn't read as <.
+var operators = []string{"==", "!=", "<=", ">=", "<", ">"}
+
+// ParseCondition reads a condition. It's one or more comparisons joined by
+// &&, each comparing two of:
+//
+//	v0 to vf   a register
+//	i, pc, sp  the address register, program counter and stack pointer
+//	dt, st     the delay and sound timers
+//	[i]        the byte of memory I points to
+//	[0x300]    a byte of memory
+//	5, 0x1F    a number
+//
+// with ==, !=, <, <=, > or >=.
+func ParseCondition(s string) (*Condition, error) {
+	c := &Condition{text: strings.TrimSpace(s)}
+	for _, part := range strings.Split(s, "&&") {
+		cmp, err := parseComparison(part)
+		if err != nil {
+			return nil, err
+		}
+		c.comparisons = append(c.comparisons, cmp)
+	}
+	return c, nil
+}
+
+func parseComparison(s string) (comparison, error) {
+	for i := 0; i < len(s); i++ {
+		for _, operator := range operators {
+			if !strings.HasPrefix(s[i:], operator) {
+				continue
+			}
+			left, err := parseOperand(s[:i])
+			if err != nil {
+				return comparison{}, err
+			}
+			right, err := parseOperand(s[i+len(operator):])
+			if err != nil {
+				return comparison{}, err
+			}
+			return comparison{left: left, right: right, operator: operator}, nil
+		}
+	}
+	return comparison{}, fmt.Errorf("%q doesn't compare anything, use one of %s", strings.TrimSpace(s), strings.Join(operators, " "))
+}
+
+func parseOperand(s string) (operand, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	switch s {
+	case "":
+		return nil, fmt.Errorf("comparison is missing a side")
+	case "i":
+		return func(m *chip8.Machine) int { return int(m.I) }, nil
+	case "pc":
+		return func(m *chip8.Machine) int { return int(m.PC()) }, nil
+	case "sp":
+		return func(m *chip8.Machine) int { return m.SP() }, nil
+	case "dt":
+		return func(m *chip8.Machine) int { return int(m.DelayTimer()) }, nil
+	case "st":
+		return func(m *chip8.Machine) int { return int(m.SoundTimer()) }, nil
+	case "[i]":
+		return func(m *chip8.Machine) int { return peek(m, int(m.I)) }, nil
+	}
+	if len(s) == 2 && s[0] == 'v' {
+		if r, err := strconv.ParseUint(s[1:], 16, 4); err == nil {
+			return func(m *chip8.Machine) int { return int(m.V[r]) }, nil
+		}
+	}
+	if strings.HasPrefix(s, "[") && strings.HasSuffix(s, "]") {
+		address, err := strconv.ParseUint(strings.TrimSpace(s[1:len(s)-1]), 0, 16)
+		if err != nil {
+			return nil, fmt.Errorf("bad address in %s", s)
+		}
+		return func(m *chip8.Machine) int { return peek(m, int(address)) }, nil
+	}
+	n, err := strconv.ParseInt(s, 0, 32)
+	if err != nil {
+		return nil, fmt.Errorf("%s isn't a register, number or address", s)
+	}
+	return func(m *chip8.Machine) int { return int(n) }, nil
+}
+
+// peek is the byte at address, or -1 past the end of memory.
+func peek(m *chip8.Machine, address int) int {
+	memory := m.Memory()
+	if address >= len(memory) {
+		return -1
+	}
+	return int(memory[address])
+}
+
+// Holds reports whether the condition is true for m.
+func (c *Condition) Holds(m *chip8.Machine) bool {
+	for _, cmp := range c.comparisons {
+		left, right := cmp.left(m), cmp.right(m)
+		var holds bool
+		switch cmp.operator {
+		case "==":
+			holds = left == right
+		case "!=":
+			holds = left != right
+		case "<":
+			holds = left < right
+		case "<=":
+			holds = left <= right
+		case ">":
+			holds = left > right
+		case ">=":
+			holds = left >= right
+		}
+		if !holds {
+			return false
+		}
+	}
+	return true
+}
+
+func (c *Condition) String() string {
+	return c.text
+}
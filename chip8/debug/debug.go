@@ -0,0 +1,365 @@
+// Package debug runs a chip8.Machine under a debugger: it stops on
+// breakpoints, which can have a condition on the registers, and on
+// watchpoints on memory, and it steps into, over and out of subroutines.
+//
+// The debugger drives the machine itself, one instruction at a time,
+// ticking the timers every IPF instructions the way a frontend would every
+// frame.
+package debug
+
+import (
+	"errors"
+	"fmt"
+	"github.com/h4ck3rk3y/go-8/chip8"
+	"sort"
+	"sync/atomic"
+)
+
+// ErrNotInSubroutine is returned by StepOut and RunToReturn when there's no
+// subroutine to get out of.
+var ErrNotInSubroutine = errors.New("not in a subroutine")
+
+// Reason is why the machine stopped.
+type Reason int
+
+const (
+	// Stepped is a step, step over or step out finishing.
+	Stepped Reason = iota
+	// HitBreakpoint is pc reaching a breakpoint whose condition holds.
+	HitBreakpoint
+	// HitWatchpoint is an instruction touching watched memory.
+	HitWatchpoint
+	// AtReturn is RunToReturn reaching the subroutine's return.
+	AtReturn
+	// Failed is the machine returning an error, including the program
+	// exiting.
+	Failed
+	// Interrupted is Interrupt being called.
+	Interrupted
+)
+
+// Stop is where the machine stopped and why.
+type Stop struct {
+	Reason     Reason
+	PC         uint16
+	Breakpoint *Breakpoint // set for HitBreakpoint
+	Watchpoint *Watchpoint // set for HitWatchpoint
+	Access     Access      // the access that hit the watchpoint
+	Err        error       // set for Failed
+}
+
+func (s Stop) String() string {
+	switch s.Reason {
+	case HitBreakpoint:
+		return fmt.Sprintf("breakpoint at 0x%03X", s.PC)
+	case HitWatchpoint:
+		return fmt.Sprintf("%v hit watchpoint on %v", s.Access, s.Watchpoint)
+	case AtReturn:
+		return fmt.Sprintf("return at 0x%03X", s.PC)
+	case Failed:
+		return s.Err.Error()
+	case Interrupted:
+		return fmt.Sprintf("interrupted at 0x%03X", s.PC)
+	}
+	return fmt.Sprintf("stepped to 0x%03X", s.PC)
+}
+
+// Breakpoint stops the machine before it executes the instruction at
+// Address, if Condition holds.
+type Breakpoint struct {
+	Address   uint16
+	Condition *Condition // nil to always stop
+	Hits      int        // times it has stopped the machine
+}
+
+func (b *Breakpoint) String() string {
+	if b.Condition == nil {
+		return fmt.Sprintf("0x%03X", b.Address)
+	}
+	return fmt.Sprintf("0x%03X if %v", b.Address, b.Condition)
+}
+
+// WatchKind is the accesses a watchpoint stops on.
+type WatchKind int
+
+const (
+	// WatchWrite stops on writes.
+	WatchWrite WatchKind = 1 << iota
+	// WatchRead stops on reads.
+	WatchRead
+	// WatchAccess stops on reads and writes.
+	WatchAccess = WatchRead | WatchWrite
+)
+
+func (k WatchKind) String() string {
+	switch k {
+	case WatchWrite:
+		return "write"
+	case WatchRead:
+		return "read"
+	}
+	return "access"
+}
+
+// Watchpoint stops the machine after an instruction reads or writes any of
+// Length bytes starting at Address.
+type Watchpoint struct {
+	Address uint16
+	Length  int
+	Kind    WatchKind
+	Hits    int
+}
+
+func (w *Watchpoint) String() string {
+	if w.Length == 1 {
+		return fmt.Sprintf("0x%03X (%v)", w.Address, w.Kind)
+	}
+	return fmt.Sprintf("0x%03X-0x%03X (%v)", w.Address, int(w.Address)+w.Length-1, w.Kind)
+}
+
+// overlaps reports whether a touches the memory w watches.
+func (w *Watchpoint) overlaps(a Access) bool {
+	return int(a.Address) < int(w.Address)+w.Length && int(w.Address) < int(a.Address)+a.Length
+}
+
+// Access is a read or write of memory by an instruction.
+type Access struct {
+	Address uint16
+	Length  int
+	Write   bool
+}
+
+func (a Access) String() string {
+	verb := "read"
+	if a.Write {
+		verb = "write"
+	}
+	if a.Length == 1 {
+		return fmt.Sprintf("%s of 0x%03X", verb, a.Address)
+	}
+	return fmt.Sprintf("%s of 0x%03X-0x%03X", verb, a.Address, int(a.Address)+a.Length-1)
+}
+
+// Debugger runs a machine, stopping where it's been asked to.
+type Debugger struct {
+	m           *chip8.Machine
+	ipf         int
+	cycles      int // instructions executed, to know when to tick the timers
+	breakpoints map[uint16]*Breakpoint
+	watchpoints []*Watchpoint
+	hit         *Stop // the watchpoint hit by the instruction being executed
+	interrupted int32 // set by Interrupt, read and cleared atomically
+}
+
+// New returns a debugger for m that ticks its timers every ipf
+// instructions, or every instruction if ipf is less than 1. It watches m's
+// memory from then on.
+func New(m *chip8.Machine, ipf int) *Debugger {
+	if ipf < 1 {
+		ipf = 1
+	}
+	d := &Debugger{m: m, ipf: ipf, breakpoints: map[uint16]*Breakpoint{}}
+	m.SetMemoryWatcher(d)
+	return d
+}
+
+// Cycles is the number of instructions the debugger has executed.
+func (d *Debugger) Cycles() int {
+	return d.cycles
+}
+
+// Machine is the machine being debugged.
+func (d *Debugger) Machine() *chip8.Machine {
+	return d.m
+}
+
+// Break sets a breakpoint at address, replacing any that's there. The
+// condition can be empty, for a breakpoint that always stops, or one that
+// ParseCondition understands.
+func (d *Debugger) Break(address uint16, condition string) (*Breakpoint, error) {
+	b := &Breakpoint{Address: address}
+	if condition != "" {
+		c, err := ParseCondition(condition)
+		if err != nil {
+			return nil, err
+		}
+		b.Condition = c
+	}
+	d.breakpoints[address] = b
+	return b, nil
+}
+
+// ClearBreak removes the breakpoint at address, reporting whether there
+// was one.
+func (d *Debugger) ClearBreak(address uint16) bool {
+	_, ok := d.breakpoints[address]
+	delete(d.breakpoints, address)
+	return ok
+}
+
+// Breakpoints returns the breakpoints in order of address.
+func (d *Debugger) Breakpoints() []*Breakpoint {
+	breakpoints := make([]*Breakpoint, 0, len(d.breakpoints))
+	for _, b := range d.breakpoints {
+		breakpoints = append(breakpoints, b)
+	}
+	sort.Slice(breakpoints, func(i, j int) bool {
+		return breakpoints[i].Address < breakpoints[j].Address
+	})
+	return breakpoints
+}
+
+// Watch sets a watchpoint on length bytes starting at address.
+func (d *Debugger) Watch(address uint16, length int, kind WatchKind) *Watchpoint {
+	w := &Watchpoint{Address: address, Length: length, Kind: kind}
+	d.watchpoints = append(d.watchpoints, w)
+	return w
+}
+
+// ClearWatch removes the watchpoints starting at address, reporting
+// whether there were any.
+func (d *Debugger) ClearWatch(address uint16) bool {
+	kept := d.watchpoints[:0]
+	for _, w := range d.watchpoints {
+		if w.Address != address {
+			kept = append(kept, w)
+		}
+	}
+	removed := len(kept) < len(d.watchpoints)
+	d.watchpoints = kept
+	return removed
+}
+
+// Watchpoints returns the watchpoints in the order they were set.
+func (d *Debugger) Watchpoints() []*Watchpoint {
+	return append([]*Watchpoint(nil), d.watchpoints...)
+}
+
+// Access is called by the machine for every memory access, and notes the
+// first watchpoint it hits.
+func (d *Debugger) Access(address uint16, n int, write bool) {
+	if d.hit != nil {
+		return
+	}
+	a := Access{Address: address, Length: n, Write: write}
+	for _, w := range d.watchpoints {
+		if (write && w.Kind&WatchWrite != 0 || !write && w.Kind&WatchRead != 0) && w.overlaps(a) {
+			w.Hits++
+			d.hit = &Stop{Reason: HitWatchpoint, Watchpoint: w, Access: a}
+			return
+		}
+	}
+}
+
+// Interrupt stops Continue, or any other run, after the instruction it's
+// on. It's safe to call from another goroutine, such as a signal handler.
+func (d *Debugger) Interrupt() {
+	atomic.StoreInt32(&d.interrupted, 1)
+}
+
+// step executes one instruction, returning where it stopped if it failed
+// or hit a watchpoint.
+func (d *Debugger) step() *Stop {
+	d.hit = nil
+	err := d.m.Step()
+	d.cycles++
+	if d.cycles%d.ipf == 0 {
+		d.m.TickTimers()
+	}
+	if err != nil {
+		return &Stop{Reason: Failed, PC: d.m.PC(), Err: err}
+	}
+	if d.hit != nil {
+		d.hit.PC = d.m.PC()
+		return d.hit
+	}
+	return nil
+}
+
+// run executes instructions until done says to stop with the reason it
+// gives, or a breakpoint, watchpoint, error or interrupt stops it first.
+// Breakpoints are checked before done, so that landing on one is never
+// missed, and the first instruction is always executed, so that a run can
+// start from a breakpoint.
+func (d *Debugger) run(done func() (Reason, bool)) Stop {
+	atomic.StoreInt32(&d.interrupted, 0)
+	for {
+		if stop := d.step(); stop != nil {
+			return *stop
+		}
+		pc := d.m.PC()
+		if b, ok := d.breakpoints[pc]; ok && (b.Condition == nil || b.Condition.Holds(d.m)) {
+			b.Hits++
+			return Stop{Reason: HitBreakpoint, PC: pc, Breakpoint: b}
+		}
+		if reason, ok := done(); ok {
+			return Stop{Reason: reason, PC: pc}
+		}
+		if atomic.SwapInt32(&d.interrupted, 0) != 0 {
+			return Stop{Reason: Interrupted, PC: pc}
+		}
+	}
+}
+
+// Step executes one instruction, going into subroutines it calls.
+func (d *Debugger) Step() Stop {
+	return d.run(func() (Reason, bool) {
+		return Stepped, true
+	})
+}
+
+// StepOver executes one instruction, running the whole of any subroutine
+// it calls.
+func (d *Debugger) StepOver() Stop {
+	pc := d.m.PC()
+	memory := d.m.Memory()
+	if int(pc)+1 >= len(memory) || memory[pc]&0xF0 != 0x20 {
+		return d.Step()
+	}
+	sp := d.m.SP()
+	return d.run(func() (Reason, bool) {
+		return Stepped, d.m.SP() == sp && d.m.PC() == pc+2
+	})
+}
+
+// StepOut runs until the current subroutine has returned.
+func (d *Debugger) StepOut() Stop {
+	sp := d.m.SP()
+	if sp == 0 {
+		return Stop{Reason: Failed, PC: d.m.PC(), Err: ErrNotInSubroutine}
+	}
+	return d.run(func() (Reason, bool) {
+		return Stepped, d.m.SP() < sp
+	})
+}
+
+// RunToReturn runs until the current subroutine is about to return, which
+// leaves its state there to look at before it's gone.
+func (d *Debugger) RunToReturn() Stop {
+	sp := d.m.SP()
+	if sp == 0 {
+		return Stop{Reason: Failed, PC: d.m.PC(), Err: ErrNotInSubroutine}
+	}
+	return d.run(func() (Reason, bool) {
+		pc := d.m.PC()
+		memory := d.m.Memory()
+		return AtReturn, d.m.SP() == sp && int(pc)+1 < len(memory) && memory[pc] == 0x00 && memory[pc+1] == 0xEE
+	})
+}
+
+// RunFor executes up to n instructions, stopping early for the same reasons
+// Continue does, which lets a frontend run a frame at a time with
+// breakpoints set.
+func (d *Debugger) RunFor(n int) Stop {
+	return d.run(func() (Reason, bool) {
+		n--
+		return Stepped, n <= 0
+	})
+}
+
+// Continue runs until a breakpoint, watchpoint, error or interrupt.
+func (d *Debugger) Continue() Stop {
+	return d.run(func() (Reason, bool) {
+		return 0, false
+	})
+}
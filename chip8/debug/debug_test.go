@@ -0,0 +1,182 @@
+package debug
+
+import (
+	"bytes"
+	"github.com/h4ck3rk3y/go-8/chip8"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+// program counts V0 up forever, calling a subroutine that stores it at
+// 0x300 each time round.
+var program = []byte{
+	0x70, 0x01, // 200: ADD V0, 1
+	0x22, 0x08, // 202: CALL 208
+	0x12, 0x00, // 204: JP 200
+	0x00, 0x00, // 206:
+	0xA3, 0x00, // 208: LD I, 0x300
+	0xF0, 0x55, // 20A: LD [I], V0
+	0x00, 0xEE, // 20C: RET
+}
+
+func newDebugger(t *testing.T) *Debugger {
+	m := chip8.New(chip8.WithSeed(1))
+	_, err := m.Load(bytes.NewReader(program))
+	assert.NoError(t, err)
+	return New(m, 10)
+}
+
+func TestStepGoesIntoCalls(t *testing.T) {
+	d := newDebugger(t)
+	d.Step()
+	stop := d.Step()
+	assert.Equal(t, Stepped, stop.Reason)
+	assert.Equal(t, uint16(0x208), stop.PC)
+	assert.Equal(t, 1, d.Machine().SP())
+}
+
+func TestStepOverRunsTheWholeCall(t *testing.T) {
+	d := newDebugger(t)
+	d.Step()
+	stop := d.StepOver()
+	assert.Equal(t, Stepped, stop.Reason)
+	assert.Equal(t, uint16(0x204), stop.PC)
+	assert.Equal(t, byte(1), d.Machine().Memory()[0x300])
+}
+
+func TestStepOutAndRunToReturn(t *testing.T) {
+	d := newDebugger(t)
+	assert.Equal(t, ErrNotInSubroutine, d.StepOut().Err)
+	d.Step()
+	d.Step()
+	stop := d.RunToReturn()
+	assert.Equal(t, AtReturn, stop.Reason)
+	assert.Equal(t, uint16(0x20C), stop.PC)
+	stop = d.StepOut()
+	assert.Equal(t, Stepped, stop.Reason)
+	assert.Equal(t, uint16(0x204), stop.PC)
+	assert.Equal(t, 0, d.Machine().SP())
+}
+
+func TestContinueStopsAtBreakpoints(t *testing.T) {
+	d := newDebugger(t)
+	b, err := d.Break(0x208, "")
+	assert.NoError(t, err)
+	stop := d.Continue()
+	assert.Equal(t, HitBreakpoint, stop.Reason)
+	assert.Equal(t, b, stop.Breakpoint)
+	assert.Equal(t, uint16(0x208), stop.PC)
+
+	// Carrying on from a breakpoint goes round to it again.
+	stop = d.Continue()
+	assert.Equal(t, uint16(0x208), stop.PC)
+	assert.Equal(t, byte(2), d.Machine().V[0])
+	assert.Equal(t, 2, b.Hits)
+
+	assert.True(t, d.ClearBreak(0x208))
+	assert.False(t, d.ClearBreak(0x208))
+	assert.Empty(t, d.Breakpoints())
+}
+
+func TestConditionalBreakpoint(t *testing.T) {
+	d := newDebugger(t)
+	_, err := d.Break(0x20C, "v0 == 5 && [0x300] >= 5")
+	assert.NoError(t, err)
+	stop := d.Continue()
+	assert.Equal(t, HitBreakpoint, stop.Reason)
+	assert.Equal(t, byte(5), d.Machine().V[0])
+}
+
+func TestWatchpoints(t *testing.T) {
+	d := newDebugger(t)
+	d.Watch(0x2FF, 2, WatchRead)
+	w := d.Watch(0x300, 1, WatchWrite)
+	stop := d.Continue()
+	assert.Equal(t, HitWatchpoint, stop.Reason)
+	assert.Equal(t, w, stop.Watchpoint)
+	assert.Equal(t, Access{Address: 0x300, Length: 1, Write: true}, stop.Access)
+	assert.Equal(t, uint16(0x20C), stop.PC)
+	assert.Equal(t, "write of 0x300 hit watchpoint on 0x300 (write)", stop.String())
+
+	assert.True(t, d.ClearWatch(0x300))
+	assert.Len(t, d.Watchpoints(), 1)
+}
+
+func TestErrorsStopTheRun(t *testing.T) {
+	d := newDebugger(t)
+	d.Machine().Memory()[0x204] = 0x00
+	d.Machine().Memory()[0x205] = 0xFD // EXIT, which CHIP-8 doesn't have
+	stop := d.Continue()
+	assert.Equal(t, Failed, stop.Reason)
+	assert.IsType(t, &chip8.OpcodeError{}, stop.Err)
+	assert.Equal(t, uint16(0x204), stop.PC)
+}
+
+func TestInterrupt(t *testing.T) {
+	d := newDebugger(t)
+	d.Interrupt()
+	// Interrupts from before a run don't stop it.
+	assert.Equal(t, Stepped, d.Step().Reason)
+	go d.Interrupt()
+	assert.Equal(t, Interrupted, d.Continue().Reason)
+}
+
+func TestTimersTickEveryIPFInstructions(t *testing.T) {
+	d := newDebugger(t)
+	d.Machine().Memory()[0x200] = 0xF0
+	d.Machine().Memory()[0x201] = 0x15 // LD DT, V0
+	d.Machine().V[0] = 10
+	for i := 0; i < 10; i++ {
+		d.Step()
+	}
+	assert.Equal(t, byte(9), d.Machine().DelayTimer())
+}
+
+func TestIPFBelowOneTicksEveryInstruction(t *testing.T) {
+	for _, ipf := range []int{0, -5} {
+		m := chip8.New()
+		m.Load(bytes.NewReader([]byte{0xF0, 0x15, 0x12, 0x02})) // LD DT, V0; JP 202
+		m.V[0] = 10
+		d := New(m, ipf)
+		d.Step()
+		d.Step()
+		assert.Equal(t, byte(8), m.DelayTimer(), "ipf %d ticks after both", ipf)
+	}
+}
+
+func TestParseConditionErrors(t *testing.T) {
+	for _, s := range []string{"v0", "v0 ==", "vg == 1", "[0x10000] == 1", "v0 == 1 &&"} {
+		_, err := ParseCondition(s)
+		assert.Error(t, err, s)
+	}
+}
+
+func TestSymbols(t *testing.T) {
+	symbols, err := ReadSymbols(strings.NewReader("0x202 main\n0x208 draw\n"))
+	assert.NoError(t, err)
+	address, err := symbols.Address("draw")
+	assert.NoError(t, err)
+	assert.Equal(t, uint16(0x208), address)
+	address, err = symbols.Address("0x300")
+	assert.NoError(t, err)
+	assert.Equal(t, uint16(0x300), address)
+	_, err = symbols.Address("nowhere")
+	assert.Error(t, err)
+	assert.Equal(t, "main", symbols.Name(0x202))
+	assert.Equal(t, "draw+4", symbols.Name(0x20C))
+	assert.Equal(t, "", symbols.Name(0x200))
+}
+
+func TestRunForStopsEarlyAtBreakpoints(t *testing.T) {
+	d := newDebugger(t)
+	stop := d.RunFor(3)
+	assert.Equal(t, Stepped, stop.Reason)
+	assert.Equal(t, uint16(0x20A), stop.PC)
+
+	// The breakpoint is on the instruction the run would have ended on.
+	d.Break(0x20C, "")
+	stop = d.RunFor(2)
+	assert.Equal(t, HitBreakpoint, stop.Reason)
+	assert.Equal(t, uint16(0x20C), stop.PC)
+}
@@ -0,0 +1,84 @@
+package debug
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Symbols names addresses, as listed in the .sym files the asm and octo
+// commands write.
+type Symbols map[string]int
+
+// ReadSymbols reads a symbol map, one address and name to a line.
+func ReadSymbols(r io.Reader) (Symbols, error) {
+	symbols := Symbols{}
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("line %d: want an address and a name", line)
+		}
+		address, err := strconv.ParseUint(fields[0], 0, 16)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: bad address %q", line, fields[0])
+		}
+		symbols[fields[1]] = int(address)
+	}
+	return symbols, scanner.Err()
+}
+
+// ReadSymbolsFile reads the symbol map in the file at path.
+func ReadSymbolsFile(path string) (Symbols, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	symbols, err := ReadSymbols(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return symbols, nil
+}
+
+// Address is the address s stands for, which can be a symbol or a number.
+func (s Symbols) Address(text string) (uint16, error) {
+	if address, ok := s[text]; ok {
+		return uint16(address), nil
+	}
+	address, err := strconv.ParseUint(text, 0, 16)
+	if err != nil {
+		return 0, fmt.Errorf("%s isn't an address or a symbol", text)
+	}
+	return uint16(address), nil
+}
+
+// Name is the symbol at address, or the closest one before it with the
+// distance added on, such as "draw+4". It's empty if no symbol comes
+// before address.
+func (s Symbols) Name(address uint16) string {
+	best, bestAddress := "", -1
+	names := make([]string, 0, len(s))
+	for name := range s {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		a := s[name]
+		if a <= int(address) && a > bestAddress {
+			best, bestAddress = name, a
+		}
+	}
+	if best == "" || bestAddress == int(address) {
+		return best
+	}
+	return fmt.Sprintf("%s+%d", best, int(address)-bestAddress)
+}
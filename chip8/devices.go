@@ -0,0 +1,107 @@
+package chip8
+
+// Display shows what a program draws.
+type Display interface {
+	// Draw is called with the new picture after each instruction that
+	// changes what's on the display.
+	Draw(frame Frame)
+}
+
+// Keypad is the hex keypad programs read their input from.
+type Keypad interface {
+	// IsPressed reports whether key 0 to F is held down.
+	IsPressed(key byte) bool
+}
+
+// Sound is the buzzer.
+type Sound interface {
+	// Beep is called with true when the sound timer starts counting and
+	// with false when it gets to zero.
+	Beep(on bool)
+}
+
+// PatternSound is a Sound that can also play XO-CHIP audio patterns rather
+// than a fixed beep.
+type PatternSound interface {
+	Sound
+	// Pattern is called when a program changes its audio pattern or pitch,
+	// with the 128 one bit samples to loop over and how many of them to
+	// play every second.
+	Pattern(pattern [16]byte, rate float64)
+}
+
+// MemoryWatcher is told about the memory instructions read and write, for
+// debuggers to stop on.
+type MemoryWatcher interface {
+	// Access is called after an instruction reads, or writes if write is
+	// true, n bytes starting at address. Fetching instructions doesn't
+	// count.
+	Access(address uint16, n int, write bool)
+}
+
+// Frame is a copy of the display at one point in time.
+type Frame struct {
+	Width  int    // columns in the current resolution
+	Height int    // rows in the current resolution
+	Pixels []byte // Width*Height pixels, row by row, as Machine.Pixel returns them
+}
+
+// At returns the pixel at column x and row y.
+func (f Frame) At(x, y int) byte {
+	return f.Pixels[y*f.Width+x]
+}
+
+// Frame returns a copy of what's on the display.
+func (m *Machine) Frame() Frame {
+	w, h := m.screenWidth(), m.screenHeight()
+	frame := Frame{Width: w, Height: h, Pixels: make([]byte, w*h)}
+	for y := 0; y < h; y++ {
+		copy(frame.Pixels[y*w:(y+1)*w], m.display[y][:w])
+	}
+	return frame
+}
+
+// pollKeys refreshes the keypad state from the Keypad, if there is one.
+// Without one keys only change through SetKey.
+func (m *Machine) pollKeys() {
+	if m.keypad == nil {
+		return
+	}
+	for key := range m.keys {
+		m.SetKey(byte(key), m.keypad.IsPressed(byte(key)))
+	}
+}
+
+// updateBuzzer tells the Sound when the buzzer has started or stopped.
+func (m *Machine) updateBuzzer() {
+	on := m.soundTimer > 0
+	if on == m.buzzing {
+		return
+	}
+	m.buzzing = on
+	if m.sound != nil {
+		m.sound.Beep(on)
+	}
+}
+
+// updatePattern passes a new audio pattern or pitch on to the Sound if it
+// can play them.
+func (m *Machine) updatePattern() {
+	if sound, ok := m.sound.(PatternSound); ok {
+		sound.Pattern(m.pattern, m.PatternRate())
+	}
+}
+
+// SetMemoryWatcher has the machine tell watcher about every memory access
+// its instructions make from now on, or stop telling anyone if it's nil.
+func (m *Machine) SetMemoryWatcher(watcher MemoryWatcher) {
+	m.watcher = watcher
+}
+
+// access tells the MemoryWatcher, if there is one, about an access to n
+// bytes of memory at address.
+func (m *Machine) access(address uint16, n int, write bool) {
+	if m.watcher != nil {
+		m.watcher.Access(address, n, write)
+	}
+}
@@ -0,0 +1,122 @@
+package chip8
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+type testDisplay struct {
+	frames []Frame
+}
+
+func (d *testDisplay) Draw(frame Frame) {
+	d.frames = append(d.frames, frame)
+}
+
+type testKeypad map[byte]bool
+
+func (k testKeypad) IsPressed(key byte) bool {
+	return k[key]
+}
+
+type testSound struct {
+	beeps    []bool
+	patterns int
+	rate     float64
+}
+
+func (s *testSound) Beep(on bool) {
+	s.beeps = append(s.beeps, on)
+}
+
+func (s *testSound) Pattern(pattern [16]byte, rate float64) {
+	s.patterns++
+	s.rate = rate
+}
+
+func TestDisplayIsDrawnAfterChanges(t *testing.T) {
+	display := &testDisplay{}
+	c := New(WithDisplay(display))
+	c.memory[0x200] = 0x60
+	c.memory[0x201] = 0x05
+	c.memory[0x202] = 0xD0
+	c.memory[0x203] = 0x11
+	c.I = 0x300
+	c.memory[0x300] = 0x80
+	c.Step()
+	assert.Empty(t, display.frames)
+	c.Step()
+	if assert.Len(t, display.frames, 1) {
+		frame := display.frames[0]
+		assert.Equal(t, 64, frame.Width)
+		assert.Equal(t, 32, frame.Height)
+		assert.Equal(t, byte(0x01), frame.At(5, 0))
+		assert.Equal(t, byte(0x00), frame.At(6, 0))
+	}
+}
+
+func TestKeysAreReadFromKeypad(t *testing.T) {
+	c := New(WithKeypad(testKeypad{0xD: true}))
+	c.memory[0x200] = 0xED
+	c.memory[0x201] = 0x9E
+	c.V[0xD] = 0xD
+	c.Step()
+	assert.Equal(t, uint16(0x204), c.pc)
+}
+
+func TestBeepStartsAndStops(t *testing.T) {
+	sound := &testSound{}
+	c := New(WithSound(sound))
+	c.memory[0x200] = 0xF0
+	c.memory[0x201] = 0x18
+	c.V[0x0] = 2
+	c.Step()
+	assert.Equal(t, []bool{true}, sound.beeps)
+	c.TickTimers()
+	assert.Equal(t, []bool{true}, sound.beeps)
+	c.TickTimers()
+	assert.Equal(t, []bool{true, false}, sound.beeps)
+}
+
+func TestPatternSoundGetsPatterns(t *testing.T) {
+	sound := &testSound{}
+	c := New(WithVariant(VariantXOChip), WithSound(sound))
+	c.memory[0x200] = 0xF0
+	c.memory[0x201] = 0x02
+	c.memory[0x202] = 0xF0
+	c.memory[0x203] = 0x3A
+	c.V[0x0] = 112
+	c.I = 0x300
+	c.Step()
+	assert.Equal(t, 1, sound.patterns)
+	assert.Equal(t, 4000.0, sound.rate)
+	c.Step()
+	assert.Equal(t, 2, sound.patterns)
+	assert.Equal(t, 8000.0, sound.rate)
+}
+
+type testWatcher []string
+
+func (w *testWatcher) Access(address uint16, n int, write bool) {
+	*w = append(*w, fmt.Sprintf("%03X %d %v", address, n, write))
+}
+
+func TestMemoryWatcherSeesReadsAndWrites(t *testing.T) {
+	watcher := &testWatcher{}
+	c := New()
+	c.SetMemoryWatcher(watcher)
+	program := []byte{
+		0x60, 0x7B, // LD V0, 123
+		0xA3, 0x00, // LD I, 0x300
+		0xF0, 0x33, // LD B, V0
+		0xF2, 0x65, // LD V2, [I]
+		0xD0, 0x03, // DRW V0, V0, 3
+		0xF1, 0x55, // LD [I], V1
+	}
+	copy(c.memory[0x200:], program)
+	for range program[:6] {
+		assert.NoError(t, c.Step())
+	}
+	assert.Equal(t, &testWatcher{"300 3 true", "300 3 false", "300 3 false", "300 2 true"}, watcher)
+}
@@ -0,0 +1,349 @@
+package chip8
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// flow is where execution can go after an instruction.
+type flow int
+
+const (
+	flowNext flow = iota // on to the next instruction
+	flowSkip             // on to the next instruction or the one after
+	flowJump             // to the target and nowhere else
+	flowCall             // to the target and, once it returns, the next instruction
+	flowStop             // somewhere that can't be worked out without running it
+)
+
+// Instruction is an instruction decoded for a listing.
+type Instruction struct {
+	Address uint16
+	Opcode  uint16
+	Size    int  // bytes, 4 for XO-CHIP's F000 NNNN and 2 otherwise
+	Valid   bool // false for opcodes the variant doesn't have
+	// Target is the address the instruction jumps or calls to or points
+	// I at, or -1 if it doesn't refer to one.
+	Target int
+	text   string // the instruction, with %s standing in for Target
+	flow   flow
+}
+
+// String is the instruction in Cowgod's mnemonics, such as "LD V1, 0x20".
+// Opcodes that aren't valid come out as a DW of the opcode.
+func (i Instruction) String() string {
+	return i.format(func(address uint16) string {
+		return fmt.Sprintf("0x%03X", address)
+	})
+}
+
+// format is the instruction with its target named by name.
+func (i Instruction) format(name func(address uint16) string) string {
+	if i.Target < 0 {
+		return i.text
+	}
+	return fmt.Sprintf(i.text, name(uint16(i.Target)))
+}
+
+// Decode decodes the instruction at address in memory as the variant would
+// execute it.
+func Decode(memory []byte, address uint16, variant Variant) Instruction {
+	i := Instruction{Address: address, Size: 2, Target: -1}
+	if int(address)+1 >= len(memory) {
+		i.Size = len(memory) - int(address)
+		if i.Size < 0 {
+			i.Size = 0
+		}
+		i.text = "DB"
+		if i.Size > 0 {
+			i.text = fmt.Sprintf("DB 0x%02X", memory[address])
+		}
+		return i
+	}
+	opcode := uint16(memory[address])<<8 | uint16(memory[address+1])
+	i.Opcode = opcode
+	x := (opcode & 0x0F00) >> 8
+	y := (opcode & 0x00F0) >> 4
+	n := opcode & 0x000F
+	kk := opcode & 0x00FF
+	nnn := opcode & 0x0FFF
+	schip := variant >= VariantSChip
+	xochip := variant >= VariantXOChip
+
+	valid := func(text string, args ...interface{}) {
+		i.Valid = true
+		i.text = fmt.Sprintf(text, args...)
+	}
+	target := func(text string, address uint16, f flow) {
+		i.Valid = true
+		i.text = text
+		i.Target = int(address)
+		i.flow = f
+	}
+
+	switch opcode & 0xF000 {
+	case 0x0000:
+		switch {
+		case opcode == 0x00E0:
+			valid("CLS")
+		case opcode == 0x00EE:
+			valid("RET")
+			i.flow = flowStop
+		case !schip:
+		case opcode == 0x00FB:
+			valid("SCR")
+		case opcode == 0x00FC:
+			valid("SCL")
+		case opcode == 0x00FD:
+			valid("EXIT")
+			i.flow = flowStop
+		case opcode == 0x00FE:
+			valid("LOW")
+		case opcode == 0x00FF:
+			valid("HIGH")
+		case opcode&0xFFF0 == 0x00C0:
+			valid("SCD %d", n)
+		case opcode&0xFFF0 == 0x00D0 && xochip:
+			valid("SCU %d", n)
+		}
+	case 0x1000:
+		target("JP %s", nnn, flowJump)
+	case 0x2000:
+		target("CALL %s", nnn, flowCall)
+	case 0x3000:
+		valid("SE V%X, 0x%02X", x, kk)
+		i.flow = flowSkip
+	case 0x4000:
+		valid("SNE V%X, 0x%02X", x, kk)
+		i.flow = flowSkip
+	case 0x5000:
+		switch {
+		case n == 0x0:
+			valid("SE V%X, V%X", x, y)
+			i.flow = flowSkip
+		case n == 0x2 && xochip:
+			valid("SAVE V%X, V%X", x, y)
+		case n == 0x3 && xochip:
+			valid("LOAD V%X, V%X", x, y)
+		}
+	case 0x6000:
+		valid("LD V%X, 0x%02X", x, kk)
+	case 0x7000:
+		valid("ADD V%X, 0x%02X", x, kk)
+	case 0x8000:
+		names := map[uint16]string{
+			0x0: "LD", 0x1: "OR", 0x2: "AND", 0x3: "XOR", 0x4: "ADD",
+			0x5: "SUB", 0x6: "SHR", 0x7: "SUBN", 0xE: "SHL",
+		}
+		if name, ok := names[n]; ok {
+			valid("%s V%X, V%X", name, x, y)
+		}
+	case 0x9000:
+		if n == 0x0 {
+			valid("SNE V%X, V%X", x, y)
+			i.flow = flowSkip
+		}
+	case 0xA000:
+		target("LD I, %s", nnn, flowNext)
+	case 0xB000:
+		target("JP V0, %s", nnn, flowStop)
+	case 0xC000:
+		valid("RND V%X, 0x%02X", x, kk)
+	case 0xD000:
+		valid("DRW V%X, V%X, %d", x, y, n)
+	case 0xE000:
+		switch kk {
+		case 0x9E:
+			valid("SKP V%X", x)
+			i.flow = flowSkip
+		case 0xA1:
+			valid("SKNP V%X", x)
+			i.flow = flowSkip
+		}
+	case 0xF000:
+		switch {
+		case opcode == 0xF000 && xochip:
+			if int(address)+3 < len(memory) {
+				long := uint16(memory[address+2])<<8 | uint16(memory[address+3])
+				target("LD I, LONG %s", long, flowNext)
+				i.Size = 4
+			}
+		case kk == 0x01 && xochip:
+			valid("PLANE %d", x)
+		case opcode == 0xF002 && xochip:
+			valid("AUDIO")
+		case kk == 0x07:
+			valid("LD V%X, DT", x)
+		case kk == 0x0A:
+			valid("LD V%X, K", x)
+		case kk == 0x15:
+			valid("LD DT, V%X", x)
+		case kk == 0x18:
+			valid("LD ST, V%X", x)
+		case kk == 0x1E:
+			valid("ADD I, V%X", x)
+		case kk == 0x29:
+			valid("LD F, V%X", x)
+		case kk == 0x30 && schip:
+			valid("LD HF, V%X", x)
+		case kk == 0x33:
+			valid("LD B, V%X", x)
+		case kk == 0x3A && xochip:
+			valid("PITCH V%X", x)
+		case kk == 0x55:
+			valid("LD [I], V%X", x)
+		case kk == 0x65:
+			valid("LD V%X, [I]", x)
+		case kk == 0x75 && schip:
+			valid("LD R, V%X", x)
+		case kk == 0x85 && schip:
+			valid("LD V%X, R", x)
+		}
+	}
+	if !i.Valid {
+		i.text = fmt.Sprintf("DW 0x%04X", opcode)
+		i.flow = flowStop
+		i.Target = -1
+	}
+	return i
+}
+
+// Disassemble writes a listing of a program loaded at origin, which is
+// 0x200 for everything but the oddest of programs. Code is found by
+// following the program from its first instruction and everything it
+// doesn't reach is listed as data. Addresses that are jumped to, called
+// or pointed at by I get labels, so the listing can be assembled again.
+// It returns an error if the program runs past the end of the memory the
+// variant has, 4k or XO-CHIP's 64k.
+func Disassemble(w io.Writer, program []byte, origin uint16, variant Variant) error {
+	if int(origin)+len(program) > variant.memorySize() {
+		return fmt.Errorf("program runs past the end of memory: %d bytes at 0x%03X on a %v", len(program), origin, variant)
+	}
+	memory := make([]byte, int(origin)+len(program))
+	copy(memory[origin:], program)
+	return disassemble(w, memory, int(origin), len(memory), []int{int(origin)}, variant)
+}
+
+// Disassemble writes a listing of the machine's memory from start up to
+// end, following code from start and from pc if it's in between.
+func (m *Machine) Disassemble(w io.Writer, start, end int) error {
+	if start < 0 || end > m.memorySize() || start > end {
+		return fmt.Errorf("can't disassemble 0x%03X to 0x%03X of a %v", start, end, m.variant)
+	}
+	entries := []int{start}
+	if int(m.pc) >= start && int(m.pc) < end {
+		entries = append(entries, int(m.pc))
+	}
+	return disassemble(w, m.memory[:end], start, end, entries, m.variant)
+}
+
+// disassemble lists memory from start up to end, tracing code from entries.
+func disassemble(w io.Writer, memory []byte, start, end int, entries []int, variant Variant) error {
+	code := map[int]Instruction{}
+	covered := make([]bool, end)
+	targets := map[int]bool{}
+	for queue := entries; len(queue) > 0; {
+		address := queue[0]
+		queue = queue[1:]
+		if address < start || address >= end {
+			continue
+		}
+		if _, ok := code[address]; ok {
+			continue
+		}
+		i := Decode(memory, uint16(address), variant)
+		if !i.Valid || address+i.Size > end {
+			continue
+		}
+		overlaps := false
+		for b := address; b < address+i.Size; b++ {
+			overlaps = overlaps || covered[b]
+		}
+		if overlaps {
+			continue
+		}
+		for b := address; b < address+i.Size; b++ {
+			covered[b] = true
+		}
+		code[address] = i
+		if i.Target >= 0 {
+			targets[i.Target] = true
+		}
+
+		next := address + i.Size
+		switch i.flow {
+		case flowNext:
+			queue = append(queue, next)
+		case flowSkip:
+			skipped := Decode(memory, uint16(next), variant)
+			queue = append(queue, next, next+skipped.Size)
+		case flowJump:
+			queue = append(queue, i.Target)
+		case flowCall:
+			queue = append(queue, i.Target, next)
+		}
+	}
+
+	// Work out where each line starts so that only addresses at the start
+	// of a line get labels. Runs of data are broken at targets so that
+	// they can be labelled.
+	var lines []int
+	for address := start; address < end; {
+		lines = append(lines, address)
+		if i, ok := code[address]; ok {
+			address += i.Size
+			continue
+		}
+		for n := 0; n < 8 && address < end && !covered[address]; n++ {
+			address++
+			if targets[address] {
+				break
+			}
+		}
+	}
+	labelled := map[int]bool{}
+	for _, address := range lines {
+		labelled[address] = targets[address]
+	}
+	name := func(address uint16) string {
+		if labelled[int(address)] {
+			return label(address)
+		}
+		return fmt.Sprintf("0x%03X", address)
+	}
+
+	b := bufio.NewWriter(w)
+	if start != 0x200 {
+		fmt.Fprintf(b, "\tORG 0x%03X\n", start)
+	}
+	for n, address := range lines {
+		if labelled[address] {
+			fmt.Fprintf(b, "%s:\n", label(uint16(address)))
+		}
+		if i, ok := code[address]; ok {
+			comment := fmt.Sprintf("%03X: %04X", address, i.Opcode)
+			if i.Size == 4 {
+				comment += fmt.Sprintf(" %02X%02X", memory[address+2], memory[address+3])
+			}
+			fmt.Fprintf(b, "\t%-24s ; %s\n", i.format(name), comment)
+			continue
+		}
+		stop := end
+		if n+1 < len(lines) {
+			stop = lines[n+1]
+		}
+		data := make([]string, 0, stop-address)
+		for _, v := range memory[address:stop] {
+			data = append(data, fmt.Sprintf("0x%02X", v))
+		}
+		fmt.Fprintf(b, "\t%-24s ; %03X\n", "DB "+strings.Join(data, ", "), address)
+	}
+	return b.Flush()
+}
+
+// label is the name the disassembler gives address.
+func label(address uint16) string {
+	return fmt.Sprintf("L%03X", address)
+}
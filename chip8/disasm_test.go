@@ -0,0 +1,134 @@
+package chip8
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func TestDecode(t *testing.T) {
+	tests := []struct {
+		variant Variant
+		code    []byte
+		want    string
+	}{
+		{VariantChip8, []byte{0x00, 0xE0}, "CLS"},
+		{VariantChip8, []byte{0x22, 0xF0}, "CALL 0x2F0"},
+		{VariantChip8, []byte{0x61, 0x20}, "LD V1, 0x20"},
+		{VariantChip8, []byte{0xD0, 0x15}, "DRW V0, V1, 5"},
+		{VariantChip8, []byte{0x8A, 0xB6}, "SHR VA, VB"},
+		{VariantChip8, []byte{0xB3, 0x00}, "JP V0, 0x300"},
+		{VariantChip8, []byte{0xF3, 0x55}, "LD [I], V3"},
+		{VariantChip8, []byte{0xF3, 0x0A}, "LD V3, K"},
+		{VariantChip8, []byte{0x5A, 0xB1}, "DW 0x5AB1"},
+		{VariantChip8, []byte{0x00, 0xFF}, "DW 0x00FF"},
+		{VariantSChip, []byte{0x00, 0xFF}, "HIGH"},
+		{VariantSChip, []byte{0x00, 0xC4}, "SCD 4"},
+		{VariantSChip, []byte{0xF2, 0x30}, "LD HF, V2"},
+		{VariantSChip, []byte{0xF5, 0x85}, "LD V5, R"},
+		{VariantSChip, []byte{0x52, 0x53}, "DW 0x5253"},
+		{VariantXOChip, []byte{0x52, 0x53}, "LOAD V2, V5"},
+		{VariantXOChip, []byte{0xF0, 0x00, 0x12, 0x34}, "LD I, LONG 0x1234"},
+		{VariantXOChip, []byte{0xF3, 0x01}, "PLANE 3"},
+		{VariantXOChip, []byte{0xF0, 0x02}, "AUDIO"},
+		{VariantXOChip, []byte{0xF1, 0x3A}, "PITCH V1"},
+	}
+	for _, test := range tests {
+		i := Decode(test.code, 0, test.variant)
+		assert.Equal(t, test.want, i.String(), "% X on %v", test.code, test.variant)
+		assert.Equal(t, len(test.code), i.Size)
+	}
+}
+
+func TestDisassembleFindsCodeAndData(t *testing.T) {
+	program := []byte{
+		0xA2, 0x0A, // 200: LD I, 0x20A
+		0x22, 0x08, // 202: CALL 0x208
+		0x12, 0x04, // 204: JP 0x204
+		0x5A, 0xB1, // 206: never reached
+		0xD0, 0x11, // 208: DRW V0, V1, 1
+		0x00, 0xEE, // 20A: RET, but it's the sprite I points at
+	}
+	program[10], program[11] = 0xFF, 0x81
+	var b bytes.Buffer
+	assert.NoError(t, Disassemble(&b, program, 0x200, VariantChip8))
+	want := `	LD I, L20A               ; 200: A20A
+	CALL L208                ; 202: 2208
+L204:
+	JP L204                  ; 204: 1204
+	DB 0x5A, 0xB1            ; 206
+L208:
+	DRW V0, V1, 1            ; 208: D011
+L20A:
+	DB 0xFF, 0x81            ; 20A
+`
+	assert.Equal(t, want, b.String())
+}
+
+func TestDisassembleFollowsBothSidesOfSkips(t *testing.T) {
+	program := []byte{
+		0x30, 0x00, // SE V0, 0x00
+		0x12, 0x08, // JP 0x208
+		0x00, 0xE0, // CLS
+		0x12, 0x06, // JP 0x206
+		0x00, 0xE0, // CLS
+		0x12, 0x0A, // JP 0x20A
+	}
+	var b bytes.Buffer
+	assert.NoError(t, Disassemble(&b, program, 0x200, VariantChip8))
+	assert.NotContains(t, b.String(), "DB")
+}
+
+func TestDisassembleSkipsOverLongLoads(t *testing.T) {
+	program := []byte{
+		0x30, 0x00, // SE V0, 0x00
+		0xF0, 0x00, 0x12, 0x34, // LD I, LONG 0x1234
+		0x00, 0xFD, // EXIT
+	}
+	var b bytes.Buffer
+	assert.NoError(t, Disassemble(&b, program, 0x200, VariantXOChip))
+	assert.Contains(t, b.String(), "LD I, LONG 0x1234")
+	assert.Contains(t, b.String(), "EXIT")
+	assert.NotContains(t, b.String(), "DB")
+}
+
+func TestDisassemblePong(t *testing.T) {
+	program, err := ioutil.ReadFile("../roms/PONG")
+	assert.NoError(t, err)
+	var b bytes.Buffer
+	assert.NoError(t, Disassemble(&b, program, 0x200, VariantChip8))
+	listing := b.String()
+	assert.True(t, strings.HasPrefix(listing, "\tLD VA, 0x02"))
+	assert.Contains(t, listing, "L2D4:\n\tLD I, L2F2")
+	assert.Contains(t, listing, "\tCALL L2D4")
+	assert.Contains(t, listing, "L2EA:\n\tDB 0x80, 0x80")
+}
+
+func TestDisassembleRejectsProgramsPastTheEndOfMemory(t *testing.T) {
+	var b bytes.Buffer
+	assert.Error(t, Disassemble(&b, make([]byte, 0x10), 0xFFF8, VariantXOChip))
+	assert.Empty(t, b.String())
+	assert.NoError(t, Disassemble(&b, make([]byte, 0x10), 0xFFF0, VariantXOChip))
+
+	b.Reset()
+	assert.Error(t, Disassemble(&b, make([]byte, 0xE01), 0x200, VariantChip8))
+	assert.Empty(t, b.String())
+	assert.NoError(t, Disassemble(&b, make([]byte, 0xE00), 0x200, VariantChip8))
+}
+
+func TestMachineDisassemble(t *testing.T) {
+	c := New()
+	copy(c.memory[0x300:], []byte{0x00, 0xE0, 0x13, 0x00})
+	c.pc = 0x300
+	var b bytes.Buffer
+	assert.NoError(t, c.Disassemble(&b, 0x2FE, 0x304))
+	assert.Equal(t, `	ORG 0x2FE
+	DB 0x00, 0x00            ; 2FE
+L300:
+	CLS                      ; 300: 00E0
+	JP L300                  ; 302: 1300
+`, b.String())
+	assert.Error(t, c.Disassemble(&b, 0x200, 0x2000))
+}
@@ -0,0 +1,10 @@
+// Package chip8 is a CHIP-8 interpreter, along with the SUPER-CHIP and
+// XO-CHIP extensions, that can be embedded in other programs.
+//
+// A Machine is made with New, given a program with Load or LoadFile and then
+// run a frame at a time with RunFrame, or stepped through one instruction at
+// a time with Step and TickTimers called 60 times a second of emulated time.
+// The machine draws, reads keys and beeps through the Display, Keypad and
+// Sound given to it as options, so the same interpreter can sit behind a
+// window, a terminal or a test.
+package chip8
@@ -0,0 +1,73 @@
+package chip8
+
+import "fmt"
+
+// OpcodePolicy decides what the machine does with an instruction it can't decode.
+type OpcodePolicy int
+
+const (
+	// HaltOnUnknown stops on the instruction and returns an *OpcodeError,
+	// leaving pc pointing at it.
+	HaltOnUnknown OpcodePolicy = iota
+	// LogUnknown logs the instruction and moves on to the next one.
+	LogUnknown
+	// IgnoreUnknown silently moves on to the next instruction.
+	IgnoreUnknown
+)
+
+// OpcodeError is returned by Step and RunFrame for instructions that
+// aren't part of the instruction set.
+type OpcodeError struct {
+	Opcode  uint16 // the instruction that was fetched
+	Address uint16 // where it was fetched from
+	Reason  string // why it couldn't be executed
+}
+
+func (e *OpcodeError) Error() string {
+	return fmt.Sprintf("unknown opcode %04X at 0x%03X: %s", e.Opcode, e.Address, e.Reason)
+}
+
+// FaultKind says which of the machine's limits an instruction ran into.
+type FaultKind int
+
+const (
+	// StackOverflow is a call with all 16 stack levels in use.
+	StackOverflow FaultKind = iota
+	// StackUnderflow is a return with nothing on the stack.
+	StackUnderflow
+	// PCOutOfRange is an instruction fetch past the end of memory.
+	PCOutOfRange
+	// IOutOfRange is a DXYN, FX33, FX55 or FX65 that would touch memory
+	// past the end through I.
+	IOutOfRange
+)
+
+var faultKindNames = [...]string{
+	StackOverflow:  "stack overflow",
+	StackUnderflow: "stack underflow",
+	PCOutOfRange:   "pc out of range",
+	IOutOfRange:    "I out of range",
+}
+
+func (k FaultKind) String() string {
+	if int(k) < len(faultKindNames) {
+		return faultKindNames[k]
+	}
+	return fmt.Sprintf("FaultKind(%d)", int(k))
+}
+
+// Fault is returned by Step and RunFrame when an instruction can't be
+// executed without leaving the bounds of the stack or memory. Nothing is
+// changed by the faulting instruction and pc is left pointing at it, so
+// State is the machine exactly as it was when the fault happened.
+type Fault struct {
+	Kind    FaultKind
+	Opcode  uint16   // the faulting instruction, 0 if it couldn't be fetched
+	Address uint16   // where the faulting instruction is
+	State   *Machine // a copy of the machine at the time of the fault
+}
+
+func (f *Fault) Error() string {
+	return fmt.Sprintf("%v at 0x%03X (opcode %04X, I=0x%03X, sp=%d)",
+		f.Kind, f.Address, f.Opcode, f.State.I, f.State.sp)
+}
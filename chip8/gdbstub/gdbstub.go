@@ -0,0 +1,462 @@
+// Package gdbstub lets gdb, or anything else that speaks its remote serial
+// protocol, debug a running chip8.Machine over TCP. Clients can read and
+// write the registers and memory, set breakpoints and watchpoints, single
+// step and continue.
+//
+// The machine is only ever touched on the frontend's goroutine: the
+// frontend calls RunFrame every frame instead of Machine.RunFrame, and
+// RunFrame answers whatever the client has sent before running the frame,
+// or doesn't run it at all while the client has the machine stopped.
+package gdbstub
+
+import (
+	"bufio"
+	"encoding/hex"
+	"fmt"
+	"github.com/h4ck3rk3y/go-8/chip8"
+	"github.com/h4ck3rk3y/go-8/chip8/debug"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// answerTime is how long RunFrame keeps answering a client that has the
+// machine stopped, so that a burst of packets doesn't take a frame each.
+const answerTime = 10 * time.Millisecond
+
+// eventKind is what has happened on a client's connection.
+type eventKind int
+
+const (
+	attached eventKind = iota
+	detached
+	received    // a packet
+	corrupted   // a packet with the wrong checksum
+	interrupted // ctrl-c
+)
+
+type event struct {
+	kind   eventKind
+	client *client
+	data   string
+}
+
+// client is a connected gdb.
+type client struct {
+	conn  net.Conn
+	noAck bool // whether the client has turned acknowledgements off
+}
+
+// send writes a packet to the client.
+func (c *client) send(data string) error {
+	sum := byte(0)
+	for i := 0; i < len(data); i++ {
+		sum += data[i]
+	}
+	_, err := fmt.Fprintf(c.conn, "$%s#%02x", data, sum)
+	return err
+}
+
+// Server is a gdb stub for the machine a debugger runs.
+type Server struct {
+	d       *debug.Debugger
+	events  chan event
+	client  *client // nil if no client is attached
+	stopped bool    // whether the client has the machine stopped
+}
+
+// New returns a server for the machine d debugs. Breakpoints and
+// watchpoints set on d belong to the client, which clears them when it
+// goes.
+func New(d *debug.Debugger) *Server {
+	return &Server{d: d, events: make(chan event, 16)}
+}
+
+// Serve accepts clients on l one at a time until l is closed. The machine
+// stops when a client attaches, until the client continues it.
+func (s *Server) Serve(l net.Listener) error {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return err
+		}
+		s.read(&client{conn: conn})
+	}
+}
+
+// read passes what c sends on to RunFrame until its connection closes.
+func (s *Server) read(c *client) {
+	s.events <- event{kind: attached, client: c}
+	defer func() {
+		c.conn.Close()
+		s.events <- event{kind: detached, client: c}
+	}()
+	r := bufio.NewReader(c.conn)
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			return
+		}
+		switch b {
+		case 0x03:
+			s.events <- event{kind: interrupted, client: c}
+		case '$':
+			data, err := r.ReadString('#')
+			if err != nil {
+				return
+			}
+			data = data[:len(data)-1]
+			var checksum [2]byte
+			if _, err := io.ReadFull(r, checksum[:]); err != nil {
+				return
+			}
+			sum := byte(0)
+			for i := 0; i < len(data); i++ {
+				sum += data[i]
+			}
+			if want, err := strconv.ParseUint(string(checksum[:]), 16, 8); err != nil || byte(want) != sum {
+				s.events <- event{kind: corrupted, client: c}
+				continue
+			}
+			s.events <- event{kind: received, client: c, data: data}
+		}
+		// Anything else is an acknowledgement, which TCP makes
+		// pointless.
+	}
+}
+
+// Attached reports whether a client is attached.
+func (s *Server) Attached() bool {
+	return s.client != nil
+}
+
+// RunFrame answers the client, then executes up to ipf instructions unless
+// the client has the machine stopped. Breakpoints, watchpoints and errors
+// stop the machine for the client to look at. Errors are only returned
+// when no client is attached, for the frontend to deal with as it would
+// without the server.
+func (s *Server) RunFrame(ipf int) error {
+	s.answer()
+	if s.stopped {
+		return nil
+	}
+	stop := s.d.RunFor(ipf)
+	if stop.Reason == debug.Stepped {
+		return nil
+	}
+	if s.client == nil {
+		if stop.Reason == debug.Failed {
+			return stop.Err
+		}
+		return nil
+	}
+	s.stop(stop)
+	return nil
+}
+
+// answer deals with everything the client has sent, waiting a little for
+// more if the machine is stopped.
+func (s *Server) answer() {
+	var deadline <-chan time.Time
+	if s.stopped {
+		deadline = time.After(answerTime)
+	}
+	for {
+		select {
+		case e := <-s.events:
+			s.handle(e)
+			continue
+		default:
+		}
+		if deadline == nil || !s.stopped {
+			return
+		}
+		select {
+		case e := <-s.events:
+			s.handle(e)
+		case <-deadline:
+			return
+		}
+	}
+}
+
+func (s *Server) handle(e event) {
+	switch e.kind {
+	case attached:
+		s.client = e.client
+		s.stopped = true
+		return
+	case detached:
+		if e.client == s.client {
+			s.detach()
+		}
+		return
+	}
+	if e.client != s.client {
+		return
+	}
+	switch e.kind {
+	case interrupted:
+		if !s.stopped {
+			s.stop(debug.Stop{Reason: debug.Interrupted, PC: s.d.Machine().PC()})
+		}
+	case corrupted:
+		if !e.client.noAck {
+			e.client.conn.Write([]byte("-"))
+		}
+	case received:
+		if !e.client.noAck {
+			e.client.conn.Write([]byte("+"))
+		}
+		reply, ok := s.packet(e.data)
+		if ok {
+			e.client.send(reply)
+		}
+		if e.data == "QStartNoAckMode" {
+			e.client.noAck = true
+		}
+	}
+}
+
+// detach forgets the client, its breakpoints and watchpoints, and lets the
+// machine run.
+func (s *Server) detach() {
+	for _, b := range s.d.Breakpoints() {
+		s.d.ClearBreak(b.Address)
+	}
+	for _, w := range s.d.Watchpoints() {
+		s.d.ClearWatch(w.Address)
+	}
+	s.client = nil
+	s.stopped = false
+}
+
+// stop stops the machine and tells the client why.
+func (s *Server) stop(stop debug.Stop) {
+	s.stopped = true
+	s.client.send(stopReply(stop))
+}
+
+// stopReply is the packet telling gdb why the machine stopped.
+func stopReply(stop debug.Stop) string {
+	switch stop.Reason {
+	case debug.HitWatchpoint:
+		kind := map[debug.WatchKind]string{debug.WatchWrite: "watch", debug.WatchRead: "rwatch", debug.WatchAccess: "awatch"}[stop.Watchpoint.Kind]
+		return fmt.Sprintf("T05%s:%x;", kind, stop.Watchpoint.Address)
+	case debug.Interrupted:
+		return "S02" // SIGINT
+	case debug.Failed:
+		switch stop.Err.(type) {
+		case *chip8.OpcodeError:
+			return "S04" // SIGILL
+		case *chip8.Fault:
+			return "S0b" // SIGSEGV
+		}
+		if stop.Err == chip8.ErrExit {
+			return "W00"
+		}
+		return "S06" // SIGABRT
+	}
+	return "S05" // SIGTRAP
+}
+
+// packet carries out a packet from the client and returns the reply, or
+// false if there isn't one yet.
+func (s *Server) packet(data string) (string, bool) {
+	m := s.d.Machine()
+	if data == "" {
+		return "", true
+	}
+	args := data[1:]
+	switch data[0] {
+	case '?':
+		return "S05", true
+	case 'g':
+		return readRegisters(m), true
+	case 'G':
+		return result(writeRegisters(m, args)), true
+	case 'p':
+		n, err := strconv.ParseUint(args, 16, 8)
+		if err != nil || n >= numRegs {
+			return "E01", true
+		}
+		return readRegister(m, int(n)), true
+	case 'P':
+		parts := strings.SplitN(args, "=", 2)
+		n, err := strconv.ParseUint(parts[0], 16, 8)
+		if err != nil || n >= numRegs || len(parts) != 2 {
+			return "E01", true
+		}
+		return result(writeRegister(m, int(n), parts[1])), true
+	case 'm':
+		address, length, _, err := memoryArgs(m, args)
+		if err != nil {
+			return "E01", true
+		}
+		return hex.EncodeToString(m.Memory()[address : address+length]), true
+	case 'M':
+		address, length, text, err := memoryArgs(m, args)
+		if err != nil {
+			return "E01", true
+		}
+		b, err := hex.DecodeString(text)
+		if err != nil || len(b) != length {
+			return "E01", true
+		}
+		copy(m.Memory()[address:], b)
+		return "OK", true
+	case 'Z', 'z':
+		return s.breakpoint(data[0] == 'Z', args), true
+	case 's':
+		if args != "" {
+			return "", true // resuming somewhere else isn't supported
+		}
+		stop := s.d.Step()
+		return stopReply(stop), true
+	case 'c':
+		if args != "" {
+			return "", true
+		}
+		s.stopped = false
+		return "", false
+	case 'k':
+		s.client.conn.Close()
+		return "", false
+	case 'D':
+		s.client.send("OK")
+		s.client.conn.Close()
+		return "", false
+	case 'H', 'T':
+		return "OK", true
+	}
+	switch {
+	case strings.HasPrefix(data, "qSupported"):
+		return "PacketSize=1000;qXfer:features:read+;QStartNoAckMode+", true
+	case data == "QStartNoAckMode":
+		return "OK", true
+	case data == "qAttached":
+		return "1", true
+	case data == "qC":
+		return "QC1", true
+	case data == "qfThreadInfo":
+		return "m1", true
+	case data == "qsThreadInfo":
+		return "l", true
+	case strings.HasPrefix(data, "qXfer:features:read:target.xml:"):
+		return features(strings.TrimPrefix(data, "qXfer:features:read:target.xml:")), true
+	}
+	// An empty reply tells gdb the packet isn't supported.
+	return "", true
+}
+
+// result is the reply to a packet that either works or doesn't.
+func result(err error) string {
+	if err != nil {
+		return "E01"
+	}
+	return "OK"
+}
+
+// memoryArgs reads the "addr,length" of an m packet, or "addr,length:data"
+// of an M packet, and checks it's all inside memory.
+func memoryArgs(m *chip8.Machine, args string) (address, length int, data string, err error) {
+	if colon := strings.Index(args, ":"); colon >= 0 {
+		args, data = args[:colon], args[colon+1:]
+	}
+	parts := strings.Split(args, ",")
+	if len(parts) != 2 {
+		return 0, 0, "", fmt.Errorf("want an address and length")
+	}
+	a, err := strconv.ParseUint(parts[0], 16, 32)
+	if err != nil {
+		return 0, 0, "", err
+	}
+	n, err := strconv.ParseUint(parts[1], 16, 32)
+	if err != nil {
+		return 0, 0, "", err
+	}
+	if a+n > uint64(len(m.Memory())) {
+		return 0, 0, "", fmt.Errorf("0x%X is outside memory", a+n)
+	}
+	return int(a), int(n), data, nil
+}
+
+// breakpoint sets, or clears if set is false, the breakpoint or watchpoint
+// a Z or z packet describes.
+func (s *Server) breakpoint(set bool, args string) string {
+	parts := strings.Split(args, ",")
+	if len(parts) < 3 {
+		return "E01"
+	}
+	address, err := strconv.ParseUint(parts[1], 16, 16)
+	if err != nil {
+		return "E01"
+	}
+	length, err := strconv.ParseUint(parts[2], 16, 16)
+	if err != nil {
+		return "E01"
+	}
+	switch parts[0] {
+	case "0", "1":
+		if !set {
+			s.d.ClearBreak(uint16(address))
+			return "OK"
+		}
+		if _, err := s.d.Break(uint16(address), ""); err != nil {
+			return "E01"
+		}
+		return "OK"
+	case "2", "3", "4":
+		if !set {
+			s.d.ClearWatch(uint16(address))
+			return "OK"
+		}
+		kind := map[string]debug.WatchKind{"2": debug.WatchWrite, "3": debug.WatchRead, "4": debug.WatchAccess}[parts[0]]
+		s.d.Watch(uint16(address), int(length), kind)
+		return "OK"
+	}
+	return ""
+}
+
+// features is the reply to a read of the target description, starting
+// from the offset and no longer than the length in args.
+func features(args string) string {
+	parts := strings.Split(args, ",")
+	if len(parts) != 2 {
+		return "E01"
+	}
+	offset, err := strconv.ParseUint(parts[0], 16, 32)
+	if err != nil {
+		return "E01"
+	}
+	length, err := strconv.ParseUint(parts[1], 16, 32)
+	if err != nil {
+		return "E01"
+	}
+	if offset >= uint64(len(TargetXML)) {
+		return "l"
+	}
+	end := offset + length
+	if end >= uint64(len(TargetXML)) {
+		return "l" + escape(TargetXML[offset:])
+	}
+	return "m" + escape(TargetXML[offset:end])
+}
+
+// escape escapes the characters that can't be sent as they are in binary
+// data.
+func escape(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; c {
+		case '#', '$', '}', '*':
+			b.WriteByte('}')
+			b.WriteByte(c ^ 0x20)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,138 @@
+package gdbstub
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"github.com/h4ck3rk3y/go-8/chip8"
+	"github.com/h4ck3rk3y/go-8/chip8/debug"
+	"github.com/stretchr/testify/assert"
+	"net"
+	"strings"
+	"testing"
+)
+
+// program counts V0 up forever, storing it at 0x300 each time round.
+var program = []byte{
+	0x70, 0x01, // 200: ADD V0, 1
+	0xA3, 0x00, // 202: LD I, 0x300
+	0xF0, 0x55, // 204: LD [I], V0
+	0x12, 0x00, // 206: JP 200
+}
+
+// testClient plays gdb's part of the conversation.
+type testClient struct {
+	t    *testing.T
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+func (c *testClient) send(data string) {
+	sum := byte(0)
+	for i := 0; i < len(data); i++ {
+		sum += data[i]
+	}
+	fmt.Fprintf(c.conn, "$%s#%02x", data, sum)
+}
+
+// receive reads the next packet, skipping acknowledgements.
+func (c *testClient) receive() string {
+	for {
+		b, err := c.r.ReadByte()
+		if !assert.NoError(c.t, err) {
+			return ""
+		}
+		if b != '$' {
+			continue
+		}
+		data, _ := c.r.ReadString('#')
+		c.r.Discard(2)
+		return data[:len(data)-1]
+	}
+}
+
+func (c *testClient) ask(data string) string {
+	c.send(data)
+	return c.receive()
+}
+
+func TestServer(t *testing.T) {
+	m := chip8.New(chip8.WithSeed(1))
+	_, err := m.Load(bytes.NewReader(program))
+	assert.NoError(t, err)
+	s := New(debug.New(m, 10))
+
+	// The machine runs on its own until a client attaches.
+	assert.NoError(t, s.RunFrame(10))
+	assert.False(t, s.Attached())
+
+	server, conn := net.Pipe()
+	go s.read(&client{conn: server})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for attached := false; ; {
+			s.RunFrame(10)
+			if s.Attached() {
+				attached = true
+			} else if attached {
+				return
+			}
+		}
+	}()
+
+	c := &testClient{t: t, conn: conn, r: bufio.NewReader(conn)}
+	assert.Contains(t, c.ask("qSupported:swbreak+"), "qXfer:features:read+")
+	assert.Equal(t, "OK", c.ask("QStartNoAckMode"))
+	assert.Equal(t, "l"+TargetXML, c.ask("qXfer:features:read:target.xml:0,1000"))
+	assert.Equal(t, "m<?xml", c.ask("qXfer:features:read:target.xml:0,5"))
+	assert.Equal(t, "S05", c.ask("?"))
+	assert.Equal(t, "7001a3", c.ask("m200,3"))
+	assert.Equal(t, "E01", c.ask("m1000,1"))
+
+	// Breakpoints stop before the instruction.
+	assert.Equal(t, "OK", c.ask("Z0,206,2"))
+	c.send("c")
+	assert.Equal(t, "S05", c.receive())
+	assert.Equal(t, "0602", c.ask("p11"))
+	registers := c.ask("g")
+	assert.Len(t, registers, 2*23)
+	assert.True(t, strings.HasSuffix(registers, "0003"+"0602"+"00"+"0000"), registers)
+
+	// Watchpoints stop after it.
+	assert.Equal(t, "OK", c.ask("z0,206,2"))
+	assert.Equal(t, "OK", c.ask("Z2,300,1"))
+	c.send("c")
+	assert.Equal(t, "T05watch:300;", c.receive())
+	assert.Equal(t, "0602", c.ask("p11"))
+	assert.Equal(t, "OK", c.ask("z2,300,1"))
+
+	assert.Equal(t, "OK", c.ask("P0=7f"))
+	assert.Equal(t, "7f", c.ask("p0"))
+	assert.Equal(t, "OK", c.ask("P11=0402"))
+	assert.Equal(t, "OK", c.ask("M300,2:aabb"))
+	assert.Equal(t, "aabb", c.ask("m300,2"))
+	assert.Equal(t, "S05", c.ask("s"))
+	assert.Equal(t, "7f", c.ask("m300,1"))
+	assert.Equal(t, "E01", c.ask("P12=11"))
+
+	// Ctrl-C stops a running machine.
+	c.send("c")
+	conn.Write([]byte{0x03})
+	assert.Equal(t, "S02", c.receive())
+
+	assert.Equal(t, "", c.ask("vMustReplyEmpty"))
+	assert.Equal(t, "OK", c.ask("D"))
+	<-done
+}
+
+func TestStopReplies(t *testing.T) {
+	assert.Equal(t, "W00", stopReply(debug.Stop{Reason: debug.Failed, Err: chip8.ErrExit}))
+	assert.Equal(t, "S04", stopReply(debug.Stop{Reason: debug.Failed, Err: &chip8.OpcodeError{}}))
+	assert.Equal(t, "S0b", stopReply(debug.Stop{Reason: debug.Failed, Err: &chip8.Fault{}}))
+	assert.Equal(t, "T05awatch:2a0;", stopReply(debug.Stop{Reason: debug.HitWatchpoint, Watchpoint: &debug.Watchpoint{Address: 0x2A0, Kind: debug.WatchAccess}}))
+}
+
+func TestEscape(t *testing.T) {
+	assert.Equal(t, "a}\x03}\x04}]}\n", escape("a#$}*"))
+}
@@ -0,0 +1,131 @@
+package gdbstub
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"github.com/h4ck3rk3y/go-8/chip8"
+)
+
+// TargetXML is the target description sent to gdb, which tells it what
+// registers a CHIP-8 has and how they're laid out in the g packet.
+const TargetXML = `<?xml version="1.0"?>
+<!DOCTYPE target SYSTEM "gdb-target.dtd">
+<target version="1.0">
+  <feature name="org.go-8.chip8">
+    <reg name="v0" bitsize="8" type="uint8" regnum="0"/>
+    <reg name="v1" bitsize="8" type="uint8"/>
+    <reg name="v2" bitsize="8" type="uint8"/>
+    <reg name="v3" bitsize="8" type="uint8"/>
+    <reg name="v4" bitsize="8" type="uint8"/>
+    <reg name="v5" bitsize="8" type="uint8"/>
+    <reg name="v6" bitsize="8" type="uint8"/>
+    <reg name="v7" bitsize="8" type="uint8"/>
+    <reg name="v8" bitsize="8" type="uint8"/>
+    <reg name="v9" bitsize="8" type="uint8"/>
+    <reg name="va" bitsize="8" type="uint8"/>
+    <reg name="vb" bitsize="8" type="uint8"/>
+    <reg name="vc" bitsize="8" type="uint8"/>
+    <reg name="vd" bitsize="8" type="uint8"/>
+    <reg name="ve" bitsize="8" type="uint8"/>
+    <reg name="vf" bitsize="8" type="uint8"/>
+    <reg name="i" bitsize="16" type="data_ptr"/>
+    <reg name="pc" bitsize="16" type="code_ptr"/>
+    <reg name="sp" bitsize="8" type="uint8"/>
+    <reg name="dt" bitsize="8" type="uint8"/>
+    <reg name="st" bitsize="8" type="uint8"/>
+  </feature>
+</target>
+`
+
+// Register numbers, in the order of the target description. V0 to VF are
+// 0 to 15.
+const (
+	regI = 16 + iota
+	regPC
+	regSP
+	regDT
+	regST
+	numRegs
+)
+
+// regSize is the bytes register n takes up in a g packet.
+func regSize(n int) int {
+	if n == regI || n == regPC {
+		return 2
+	}
+	return 1
+}
+
+// readRegister is register n as gdb wants it, in hex and little endian.
+func readRegister(m *chip8.Machine, n int) string {
+	var v int
+	switch {
+	case n < regI:
+		v = int(m.V[n])
+	case n == regI:
+		v = int(m.I)
+	case n == regPC:
+		v = int(m.PC())
+	case n == regSP:
+		v = m.SP()
+	case n == regDT:
+		v = int(m.DelayTimer())
+	case n == regST:
+		v = int(m.SoundTimer())
+	}
+	b := []byte{byte(v), byte(v >> 8)}
+	return hex.EncodeToString(b[:regSize(n)])
+}
+
+// writeRegister sets register n from the hex gdb sent.
+func writeRegister(m *chip8.Machine, n int, text string) error {
+	b, err := hex.DecodeString(text)
+	if err != nil || len(b) != regSize(n) {
+		return fmt.Errorf("bad value %q for register %d", text, n)
+	}
+	v := int(b[0])
+	if len(b) == 2 {
+		v |= int(b[1]) << 8
+	}
+	switch {
+	case n < regI:
+		m.V[n] = byte(v)
+	case n == regI:
+		m.I = uint16(v)
+	case n == regPC:
+		m.SetPC(uint16(v))
+	case n == regSP:
+		return m.SetSP(v)
+	case n == regDT:
+		m.SetTimers(byte(v), m.SoundTimer())
+	case n == regST:
+		m.SetTimers(m.DelayTimer(), byte(v))
+	}
+	return nil
+}
+
+// readRegisters is the reply to a g packet, every register one after the
+// other.
+func readRegisters(m *chip8.Machine) string {
+	var b bytes.Buffer
+	for n := 0; n < numRegs; n++ {
+		b.WriteString(readRegister(m, n))
+	}
+	return b.String()
+}
+
+// writeRegisters sets every register from a G packet.
+func writeRegisters(m *chip8.Machine, text string) error {
+	for n := 0; n < numRegs; n++ {
+		size := 2 * regSize(n)
+		if len(text) < size {
+			return fmt.Errorf("G packet is too short")
+		}
+		if err := writeRegister(m, n, text[:size]); err != nil {
+			return err
+		}
+		text = text[size:]
+	}
+	return nil
+}
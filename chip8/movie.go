@@ -0,0 +1,221 @@
+package chip8
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// A movie starts with a movieHeader and is followed by a big endian uint16
+// for each frame.
+const (
+	movieMagic   = "GO8M"
+	movieVersion = 1
+)
+
+// ErrNotMovie is returned by ReadMovie for data that isn't a movie at all.
+var ErrNotMovie = errors.New("not a go-8 movie")
+
+// movieHeader starts every movie, laid out for encoding/binary.
+type movieHeader struct {
+	Magic           [4]byte
+	Version         uint16
+	Variant         uint8
+	VFReset         bool
+	ShiftUsesVY     bool
+	MemoryIncrement uint8
+	JumpUsesVX      bool
+	ClipSprites     bool
+	RandomMode      uint8
+	Seed            int64
+	IPF             uint32
+	ROMHash         [sha256.Size]byte
+	StateHash       [sha256.Size]byte
+	Frames          uint32
+}
+
+// Movie is a recording of a program being played: the ROM, how the machine
+// was set up and the keys held down every frame, which is all it takes to
+// play it back exactly the same way.
+type Movie struct {
+	Variant    Variant
+	Quirks     Quirks
+	RandomMode RandomMode
+	Seed       int64
+	IPF        int               // instructions executed every frame
+	ROMHash    [sha256.Size]byte // SHA-256 of the ROM
+	Frames     []uint16          // the keys held down each frame, bit n set for key n
+	StateHash  [sha256.Size]byte // StateHash of the machine at the end, zero until Finish
+}
+
+// NewMovie starts a recording of m, which has had rom loaded and hasn't
+// run yet, being run ipf instructions a frame.
+func NewMovie(m *Machine, rom []byte, ipf int) *Movie {
+	return &Movie{
+		Variant:    m.variant,
+		Quirks:     m.quirks,
+		RandomMode: m.randomMode,
+		Seed:       m.seed,
+		IPF:        ipf,
+		ROMHash:    sha256.Sum256(rom),
+	}
+}
+
+// RunFrame holds down keys, bit n set for key n, and runs a frame of m,
+// recording the keys as the movie's next frame. It returns what
+// m.RunFrame does.
+func (mv *Movie) RunFrame(m *Machine, keys uint16) (int, error) {
+	mv.Frames = append(mv.Frames, keys)
+	return mv.PlayFrame(m, len(mv.Frames)-1)
+}
+
+// Back forgets the last frame recorded, for when the machine has been
+// rewound a frame.
+func (mv *Movie) Back() {
+	if len(mv.Frames) > 0 {
+		mv.Frames = mv.Frames[:len(mv.Frames)-1]
+	}
+}
+
+// Finish notes the state m is in at the end of the recording, to check a
+// replay against.
+func (mv *Movie) Finish(m *Machine) {
+	mv.StateHash = m.StateHash()
+}
+
+// Options are the options for a machine set up the way the recorded one
+// was.
+func (mv *Movie) Options() []Option {
+	return []Option{WithVariant(mv.Variant), WithQuirks(mv.Quirks), WithRandomMode(mv.RandomMode), WithSeed(mv.Seed)}
+}
+
+// CheckROM returns an error unless rom is the ROM the movie was recorded
+// with.
+func (mv *Movie) CheckROM(rom []byte) error {
+	if sha256.Sum256(rom) != mv.ROMHash {
+		return errors.New("the ROM isn't the one the movie was recorded with")
+	}
+	return nil
+}
+
+// PlayFrame plays frame n of the movie on m, which has to have been set up
+// with Options, had the ROM loaded and played the frames before it. Keys
+// are set with SetKey, so m shouldn't have a Keypad. It returns what
+// m.RunFrame does.
+func (mv *Movie) PlayFrame(m *Machine, n int) (int, error) {
+	// RunFrame sets the keys this way too, even on a machine with a
+	// Keypad holding the same ones down, so that a recording and its
+	// replay have the same key state whether or not the program looked
+	// at the keys.
+	for key := uint(0); key < 16; key++ {
+		m.SetKey(byte(key), mv.Frames[n]&(1<<key) != 0)
+	}
+	return m.RunFrame(mv.IPF)
+}
+
+// Replay plays the whole movie on a new machine with rom loaded, which is
+// set up with Options followed by any other options given. It returns the
+// machine as it is after the last frame, or after the frame that returned
+// an error along with the error, and how many frames were played and
+// instructions executed. The machine is nil if rom isn't the movie's or
+// can't be loaded.
+func (mv *Movie) Replay(rom []byte, options ...Option) (m *Machine, frames, instructions int, err error) {
+	if err := mv.CheckROM(rom); err != nil {
+		return nil, 0, 0, err
+	}
+	m = New(append(mv.Options(), options...)...)
+	if _, err := m.Load(bytes.NewReader(rom)); err != nil {
+		return nil, 0, 0, err
+	}
+	for n := range mv.Frames {
+		executed, err := mv.PlayFrame(m, n)
+		instructions += executed
+		if err != nil {
+			return m, n + 1, instructions, err
+		}
+	}
+	return m, len(mv.Frames), instructions, nil
+}
+
+// Write writes the movie to w.
+func (mv *Movie) Write(w io.Writer) error {
+	header := movieHeader{
+		Version:         movieVersion,
+		Variant:         uint8(mv.Variant),
+		VFReset:         mv.Quirks.VFReset,
+		ShiftUsesVY:     mv.Quirks.ShiftUsesVY,
+		MemoryIncrement: uint8(mv.Quirks.MemoryIncrement),
+		JumpUsesVX:      mv.Quirks.JumpUsesVX,
+		ClipSprites:     mv.Quirks.ClipSprites,
+		RandomMode:      uint8(mv.RandomMode),
+		Seed:            mv.Seed,
+		IPF:             uint32(mv.IPF),
+		ROMHash:         mv.ROMHash,
+		StateHash:       mv.StateHash,
+		Frames:          uint32(len(mv.Frames)),
+	}
+	copy(header.Magic[:], movieMagic)
+	if err := binary.Write(w, binary.BigEndian, &header); err != nil {
+		return err
+	}
+	return binary.Write(w, binary.BigEndian, mv.Frames)
+}
+
+// ReadMovie reads a movie written by Write.
+func ReadMovie(r io.Reader) (*Movie, error) {
+	var header movieHeader
+	if err := binary.Read(r, binary.BigEndian, &header); err != nil {
+		return nil, ErrNotMovie
+	}
+	if string(header.Magic[:]) != movieMagic {
+		return nil, ErrNotMovie
+	}
+	if header.Version != movieVersion {
+		return nil, fmt.Errorf("movie is version %d, only version %d can be played", header.Version, movieVersion)
+	}
+	if header.IPF == 0 {
+		return nil, errors.New("movie has no instructions in a frame")
+	}
+	mv := &Movie{
+		Variant: Variant(header.Variant),
+		Quirks: Quirks{
+			VFReset:         header.VFReset,
+			ShiftUsesVY:     header.ShiftUsesVY,
+			MemoryIncrement: MemoryIncrement(header.MemoryIncrement),
+			JumpUsesVX:      header.JumpUsesVX,
+			ClipSprites:     header.ClipSprites,
+		},
+		RandomMode: RandomMode(header.RandomMode),
+		Seed:       header.Seed,
+		IPF:        int(header.IPF),
+		ROMHash:    header.ROMHash,
+		StateHash:  header.StateHash,
+	}
+	// Read the frames a chunk at a time rather than trusting the header
+	// with how much to allocate.
+	for left := int(header.Frames); left > 0; {
+		chunk := make([]uint16, 4096)
+		if left < len(chunk) {
+			chunk = chunk[:left]
+		}
+		if err := binary.Read(r, binary.BigEndian, chunk); err != nil {
+			return nil, fmt.Errorf("movie is cut short: %v", err)
+		}
+		mv.Frames = append(mv.Frames, chunk...)
+		left -= len(chunk)
+	}
+	return mv, nil
+}
+
+// StateHash is a SHA-256 of the machine's save state, which two machines
+// only share if they're in the same state.
+func (m *Machine) StateHash() [sha256.Size]byte {
+	h := sha256.New()
+	m.SaveState(h)
+	var sum [sha256.Size]byte
+	copy(sum[:], h.Sum(nil))
+	return sum
+}
@@ -0,0 +1,95 @@
+package chip8
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"testing"
+)
+
+// recordPong records frames of PONG being played with one paddle going up
+// and down.
+func recordPong(t *testing.T, frames int) (*Movie, *Machine, []byte) {
+	rom, err := ioutil.ReadFile("../roms/PONG")
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	m := New(WithSeed(7), WithQuirks(QuirksCosmacVIP))
+	m.Load(bytes.NewReader(rom))
+	mv := NewMovie(m, rom, 10)
+	for i := 0; i < frames; i++ {
+		keys := uint16(1 << 0x1)
+		if i%60 >= 30 {
+			keys = 1 << 0x4
+		}
+		_, err := mv.RunFrame(m, keys)
+		assert.NoError(t, err)
+	}
+	mv.Finish(m)
+	return mv, m, rom
+}
+
+func TestMovieReplaysTheSameWay(t *testing.T) {
+	mv, m, rom := recordPong(t, 300)
+	var saved bytes.Buffer
+	assert.NoError(t, mv.Write(&saved))
+	assert.Equal(t, 93+2*300, saved.Len(), "a 93 byte header and 2 bytes a frame")
+
+	read, err := ReadMovie(bytes.NewReader(saved.Bytes()))
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, mv, read)
+	replayed, frames, instructions, err := read.Replay(rom)
+	assert.NoError(t, err)
+	assert.Equal(t, 300, frames)
+	assert.Equal(t, 300*10, instructions)
+	assert.Equal(t, m.StateHash(), replayed.StateHash())
+	assert.Equal(t, read.StateHash, replayed.StateHash())
+	assert.Equal(t, QuirksCosmacVIP, replayed.Quirks())
+	assert.Equal(t, int64(7), replayed.Seed())
+}
+
+func TestMovieBackForgetsFrames(t *testing.T) {
+	mv, _, rom := recordPong(t, 100)
+	for i := 0; i < 40; i++ {
+		mv.Back()
+	}
+	assert.Len(t, mv.Frames, 60)
+	shorter, _, _ := recordPong(t, 60)
+	a, _, _, err := mv.Replay(rom)
+	assert.NoError(t, err)
+	b, _, _, err := shorter.Replay(rom)
+	assert.NoError(t, err)
+	assert.Equal(t, a.StateHash(), b.StateHash())
+}
+
+func TestMovieNeedsTheSameROM(t *testing.T) {
+	mv, _, rom := recordPong(t, 10)
+	rom[0] ^= 0xFF
+	_, _, _, err := mv.Replay(rom)
+	assert.EqualError(t, err, "the ROM isn't the one the movie was recorded with")
+}
+
+func TestReadMovieRejectsBadMovies(t *testing.T) {
+	_, err := ReadMovie(bytes.NewReader([]byte("GO8S not a movie")))
+	assert.Equal(t, ErrNotMovie, err)
+
+	mv, _, _ := recordPong(t, 10)
+	var saved bytes.Buffer
+	mv.Write(&saved)
+	_, err = ReadMovie(bytes.NewReader(saved.Bytes()[:saved.Len()-1]))
+	assert.Error(t, err)
+
+	newer := append([]byte{}, saved.Bytes()...)
+	newer[5] = movieVersion + 1
+	_, err = ReadMovie(bytes.NewReader(newer))
+	assert.Error(t, err)
+}
+
+func TestStateHashChangesWithState(t *testing.T) {
+	a, b := New(WithSeed(1)), New(WithSeed(1))
+	assert.Equal(t, a.StateHash(), b.StateHash())
+	b.V[3] = 1
+	assert.NotEqual(t, a.StateHash(), b.StateHash())
+}
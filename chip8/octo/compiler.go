@@ -0,0 +1,738 @@
+package octo
+
+import (
+	"fmt"
+	"strings"
+)
+
+// maxExpansions stops macros that expand into themselves from expanding
+// forever.
+const maxExpansions = 100000
+
+// refKind is how an address gets patched into the program.
+type refKind int
+
+const (
+	ref12      refKind = iota // the low 12 bits of an instruction
+	ref16                     // two bytes
+	refUnpack                 // v0 := nybble and high bits, v1 := low byte
+	refUnpackL                // v0 := high byte, v1 := low byte
+)
+
+// reference is a use of a label that hasn't been defined yet.
+type reference struct {
+	address int
+	kind    refKind
+	nybble  int
+	line    int
+}
+
+type macro struct {
+	args []string
+	body []token
+}
+
+// loop is a loop waiting for its again.
+type loop struct {
+	start  int
+	whiles []int // jumps out of the loop to patch
+	line   int
+}
+
+// branch is an if ... begin waiting for its else or end.
+type branch struct {
+	jump int // the jump to patch
+	line int
+}
+
+type compiler struct {
+	tokens      []token
+	pos         int
+	line        int
+	memory      [0x10000]byte
+	here        int
+	end         int // one past the highest address written
+	labels      map[string]int
+	constants   map[string]float64
+	aliases     map[string]int
+	macros      map[string]macro
+	protos      map[string][]reference
+	loops       []loop
+	branches    []branch
+	breakpoints map[string]int
+	expansions  int
+}
+
+func newCompiler(tokens []token) *compiler {
+	return &compiler{
+		tokens:      tokens,
+		here:        0x200,
+		end:         0x200,
+		labels:      map[string]int{},
+		constants:   map[string]float64{},
+		aliases:     map[string]int{},
+		macros:      map[string]macro{},
+		protos:      map[string][]reference{},
+		breakpoints: map[string]int{},
+	}
+}
+
+func (c *compiler) fail(format string, args ...interface{}) error {
+	return &Error{Line: c.line, Err: fmt.Sprintf(format, args...)}
+}
+
+// rom is the compiled program.
+func (c *compiler) rom() []byte {
+	rom := make([]byte, c.end-0x200)
+	copy(rom, c.memory[0x200:c.end])
+	return rom
+}
+
+func (c *compiler) done() bool {
+	return c.pos >= len(c.tokens)
+}
+
+func (c *compiler) next() (string, error) {
+	if c.done() {
+		return "", c.fail("the program ends in the middle of a statement")
+	}
+	t := c.tokens[c.pos]
+	c.pos++
+	c.line = t.line
+	return t.text, nil
+}
+
+func (c *compiler) peek() string {
+	if c.done() {
+		return ""
+	}
+	return c.tokens[c.pos].text
+}
+
+func (c *compiler) expect(want string) error {
+	got, err := c.next()
+	if err != nil {
+		return err
+	}
+	if got != want {
+		return c.fail("expected %s, not %s", want, got)
+	}
+	return nil
+}
+
+func (c *compiler) emit(b ...byte) error {
+	if c.here+len(b) > len(c.memory) {
+		return c.fail("the program runs past the end of memory")
+	}
+	copy(c.memory[c.here:], b)
+	c.here += len(b)
+	if c.here > c.end {
+		c.end = c.here
+	}
+	return nil
+}
+
+func (c *compiler) op(opcode int) error {
+	return c.emit(byte(opcode>>8), byte(opcode))
+}
+
+// compile compiles the whole program, starting it with a jump to main.
+func (c *compiler) compile() error {
+	if err := c.op(0x1000); err != nil {
+		return err
+	}
+	for !c.done() {
+		if err := c.statement(); err != nil {
+			return err
+		}
+	}
+	if len(c.loops) > 0 {
+		c.line = c.loops[len(c.loops)-1].line
+		return c.fail("loop without an again")
+	}
+	if len(c.branches) > 0 {
+		c.line = c.branches[len(c.branches)-1].line
+		return c.fail("begin without an end")
+	}
+	for name, refs := range c.protos {
+		c.line = refs[0].line
+		return c.fail("%s is never defined", name)
+	}
+	main, ok := c.labels["main"]
+	if !ok {
+		c.line = 1
+		return c.fail("the program has no main label")
+	}
+	return c.patch(0x200, ref12, main, 0)
+}
+
+// defineLabel gives name the address and fills it in wherever it was used
+// before it was defined.
+func (c *compiler) defineLabel(name string, address int) error {
+	if err := c.checkName(name); err != nil {
+		return err
+	}
+	c.labels[name] = address
+	for _, ref := range c.protos[name] {
+		c.line = ref.line
+		if err := c.patch(ref.address, ref.kind, address, ref.nybble); err != nil {
+			return err
+		}
+	}
+	delete(c.protos, name)
+	return nil
+}
+
+// checkName makes sure name is free to be defined.
+func (c *compiler) checkName(name string) error {
+	if _, ok := register(name); ok {
+		return c.fail("%s is a register", name)
+	}
+	if _, ok := c.labels[name]; ok {
+		return c.fail("%s is already defined", name)
+	}
+	if _, ok := c.constants[name]; ok {
+		return c.fail("%s is already defined", name)
+	}
+	if _, ok := parseNumber(name); ok {
+		return c.fail("%s is a number, not a name", name)
+	}
+	return nil
+}
+
+// patch puts address into the program at at.
+func (c *compiler) patch(at int, kind refKind, address, nybble int) error {
+	switch kind {
+	case ref12:
+		if address < 0 || address > 0xFFF {
+			return c.fail("0x%X doesn't fit in 12 bits, use i := long for XO-CHIP's 64k", address)
+		}
+		c.memory[at] = c.memory[at]&0xF0 | byte(address>>8)
+		c.memory[at+1] = byte(address)
+	case ref16:
+		if address < 0 || address > 0xFFFF {
+			return c.fail("0x%X is outside memory", address)
+		}
+		c.memory[at] = byte(address >> 8)
+		c.memory[at+1] = byte(address)
+	case refUnpack:
+		if address < 0 || address > 0xFFF {
+			return c.fail("0x%X doesn't fit in 12 bits, use :unpack long", address)
+		}
+		c.memory[at+1] = byte(nybble<<4 | address>>8)
+		c.memory[at+3] = byte(address)
+	case refUnpackL:
+		if address < 0 || address > 0xFFFF {
+			return c.fail("0x%X is outside memory", address)
+		}
+		c.memory[at+1] = byte(address >> 8)
+		c.memory[at+3] = byte(address)
+	}
+	return nil
+}
+
+// refer patches the address written as name into the program at at, now if
+// it's known or once it's defined if it isn't.
+func (c *compiler) refer(name string, at int, kind refKind, nybble int) error {
+	if v, ok := c.known(name); ok {
+		return c.patch(at, kind, v, nybble)
+	}
+	if err := c.checkName(name); err != nil {
+		return err
+	}
+	if _, ok := c.aliases[name]; ok || keywords[name] {
+		return c.fail("expected an address, not %s", name)
+	}
+	c.protos[name] = append(c.protos[name], reference{address: at, kind: kind, nybble: nybble, line: c.line})
+	return nil
+}
+
+// known is the value of a number, constant or label that's been defined.
+func (c *compiler) known(name string) (int, bool) {
+	if v, ok := parseNumber(name); ok {
+		return v, true
+	}
+	if v, ok := c.constants[name]; ok {
+		return int(v), true
+	}
+	if v, ok := c.labels[name]; ok {
+		return v, true
+	}
+	return 0, false
+}
+
+// value reads a number or constant and checks it's between min and max.
+func (c *compiler) value(min, max int) (int, error) {
+	t, err := c.next()
+	if err != nil {
+		return 0, err
+	}
+	v, ok := c.known(t)
+	if !ok {
+		return 0, c.fail("expected a number, not %s", t)
+	}
+	if v < min || v > max {
+		return 0, c.fail("%s is outside %d to %d", t, min, max)
+	}
+	return v, nil
+}
+
+// register reads a register, written as v0 to vf or an alias.
+func (c *compiler) register() (int, error) {
+	t, err := c.next()
+	if err != nil {
+		return 0, err
+	}
+	if r, ok := c.isRegister(t); ok {
+		return r, nil
+	}
+	return 0, c.fail("expected a register, not %s", t)
+}
+
+func (c *compiler) isRegister(t string) (int, bool) {
+	if r, ok := register(t); ok {
+		return r, true
+	}
+	r, ok := c.aliases[t]
+	return r, ok
+}
+
+// address reads an address and compiles opcode, an instruction taking a 12
+// bit address.
+func (c *compiler) address(opcode int) error {
+	t, err := c.next()
+	if err != nil {
+		return err
+	}
+	at := c.here
+	if err := c.op(opcode); err != nil {
+		return err
+	}
+	return c.refer(t, at, ref12, 0)
+}
+
+// keywords are the words that start statements or are part of them, and so
+// can't be calls.
+var keywords = map[string]bool{
+	";": true, "return": true, "clear": true, "bcd": true, "save": true,
+	"load": true, "sprite": true, "jump": true, "jump0": true, "native": true,
+	"i": true, "delay": true, "buzzer": true, "pitch": true, "if": true,
+	"then": true, "begin": true, "else": true, "end": true, "loop": true,
+	"again": true, "while": true, "key": true, "-key": true, "random": true,
+	"hex": true, "bighex": true, "long": true, "hires": true, "lores": true,
+	"scroll-down": true, "scroll-up": true, "scroll-left": true,
+	"scroll-right": true, "exit": true, "saveflags": true, "loadflags": true,
+	"plane": true, "audio": true,
+}
+
+// statement compiles one statement.
+func (c *compiler) statement() error {
+	t, err := c.next()
+	if err != nil {
+		return err
+	}
+	if strings.HasPrefix(t, ":") {
+		return c.directive(t)
+	}
+	if r, ok := c.isRegister(t); ok {
+		return c.assignment(r)
+	}
+	switch t {
+	case ";", "return":
+		return c.op(0x00EE)
+	case "clear":
+		return c.op(0x00E0)
+	case "hires":
+		return c.op(0x00FF)
+	case "lores":
+		return c.op(0x00FE)
+	case "scroll-down":
+		n, err := c.value(0, 15)
+		if err != nil {
+			return err
+		}
+		return c.op(0x00C0 | n)
+	case "scroll-up":
+		n, err := c.value(0, 15)
+		if err != nil {
+			return err
+		}
+		return c.op(0x00D0 | n)
+	case "scroll-left":
+		return c.op(0x00FC)
+	case "scroll-right":
+		return c.op(0x00FB)
+	case "exit":
+		return c.op(0x00FD)
+	case "audio":
+		return c.op(0xF002)
+	case "plane":
+		n, err := c.value(0, 3)
+		if err != nil {
+			return err
+		}
+		return c.op(0xF001 | n<<8)
+	case "jump":
+		return c.address(0x1000)
+	case "jump0":
+		return c.address(0xB000)
+	case "native":
+		return c.address(0x0000)
+	case "bcd", "saveflags", "loadflags":
+		r, err := c.register()
+		if err != nil {
+			return err
+		}
+		return c.op(map[string]int{"bcd": 0xF033, "saveflags": 0xF075, "loadflags": 0xF085}[t] | r<<8)
+	case "save", "load":
+		x, err := c.register()
+		if err != nil {
+			return err
+		}
+		if c.peek() != "-" {
+			return c.op(map[string]int{"save": 0xF055, "load": 0xF065}[t] | x<<8)
+		}
+		c.next()
+		y, err := c.register()
+		if err != nil {
+			return err
+		}
+		return c.op(map[string]int{"save": 0x5002, "load": 0x5003}[t] | x<<8 | y<<4)
+	case "sprite":
+		x, err := c.register()
+		if err != nil {
+			return err
+		}
+		y, err := c.register()
+		if err != nil {
+			return err
+		}
+		n, err := c.value(0, 15)
+		if err != nil {
+			return err
+		}
+		return c.op(0xD000 | x<<8 | y<<4 | n)
+	case "i":
+		return c.iStatement()
+	case "delay", "buzzer", "pitch":
+		if err := c.expect(":="); err != nil {
+			return err
+		}
+		r, err := c.register()
+		if err != nil {
+			return err
+		}
+		return c.op(map[string]int{"delay": 0xF015, "buzzer": 0xF018, "pitch": 0xF03A}[t] | r<<8)
+	case "if":
+		return c.ifStatement()
+	case "else":
+		if len(c.branches) == 0 {
+			return c.fail("else without an if ... begin")
+		}
+		top := &c.branches[len(c.branches)-1]
+		jump := c.here
+		if err := c.op(0x1000); err != nil {
+			return err
+		}
+		if err := c.patch(top.jump, ref12, c.here, 0); err != nil {
+			return err
+		}
+		top.jump = jump
+		return nil
+	case "end":
+		if len(c.branches) == 0 {
+			return c.fail("end without an if ... begin")
+		}
+		top := c.branches[len(c.branches)-1]
+		c.branches = c.branches[:len(c.branches)-1]
+		return c.patch(top.jump, ref12, c.here, 0)
+	case "loop":
+		c.loops = append(c.loops, loop{start: c.here, line: c.line})
+		return nil
+	case "while":
+		if len(c.loops) == 0 {
+			return c.fail("while outside a loop")
+		}
+		if err := c.condition(true); err != nil {
+			return err
+		}
+		top := &c.loops[len(c.loops)-1]
+		top.whiles = append(top.whiles, c.here)
+		return c.op(0x1000)
+	case "again":
+		if len(c.loops) == 0 {
+			return c.fail("again without a loop")
+		}
+		top := c.loops[len(c.loops)-1]
+		c.loops = c.loops[:len(c.loops)-1]
+		if err := c.op(0x1000); err != nil {
+			return err
+		}
+		if err := c.patch(c.here-2, ref12, top.start, 0); err != nil {
+			return err
+		}
+		for _, while := range top.whiles {
+			if err := c.patch(while, ref12, c.here, 0); err != nil {
+				return err
+			}
+		}
+		return nil
+	case "then", "begin", "key", "-key", "random", "hex", "bighex", "long":
+		return c.fail("%s out of place", t)
+	}
+	if m, ok := c.macros[t]; ok {
+		return c.expand(t, m)
+	}
+	// Numbers and constants on their own are bytes of data, sprites most
+	// likely, and anything else is a subroutine to call.
+	if v, ok := c.known(t); ok {
+		if _, label := c.labels[t]; !label {
+			if v < -128 || v > 255 {
+				return c.fail("%s doesn't fit in a byte", t)
+			}
+			return c.emit(byte(v))
+		}
+	}
+	at := c.here
+	if err := c.op(0x2000); err != nil {
+		return err
+	}
+	return c.refer(t, at, ref12, 0)
+}
+
+// assignment compiles the statements that start with register x.
+func (c *compiler) assignment(x int) error {
+	operator, err := c.next()
+	if err != nil {
+		return err
+	}
+	arithmetic := map[string]int{
+		"|=": 0x8001, "&=": 0x8002, "^=": 0x8003, "+=": 0x8004,
+		"-=": 0x8005, ">>=": 0x8006, "=-": 0x8007, "<<=": 0x800E,
+	}
+	switch operator {
+	case ":=":
+		switch c.peek() {
+		case "key":
+			c.next()
+			return c.op(0xF00A | x<<8)
+		case "delay":
+			c.next()
+			return c.op(0xF007 | x<<8)
+		case "random":
+			c.next()
+			n, err := c.value(-128, 255)
+			if err != nil {
+				return err
+			}
+			return c.op(0xC000 | x<<8 | n&0xFF)
+		}
+		if y, ok := c.isRegister(c.peek()); ok {
+			c.next()
+			return c.op(0x8000 | x<<8 | y<<4)
+		}
+		n, err := c.value(-128, 255)
+		if err != nil {
+			return err
+		}
+		return c.op(0x6000 | x<<8 | n&0xFF)
+	case "+=", "-=":
+		if y, ok := c.isRegister(c.peek()); ok {
+			c.next()
+			return c.op(arithmetic[operator] | x<<8 | y<<4)
+		}
+		n, err := c.value(-128, 255)
+		if err != nil {
+			return err
+		}
+		if operator == "-=" {
+			n = -n
+		}
+		return c.op(0x7000 | x<<8 | n&0xFF)
+	}
+	opcode, ok := arithmetic[operator]
+	if !ok {
+		return c.fail("unknown operator %s", operator)
+	}
+	y, err := c.register()
+	if err != nil {
+		return err
+	}
+	return c.op(opcode | x<<8 | y<<4)
+}
+
+// iStatement compiles the statements that start with i.
+func (c *compiler) iStatement() error {
+	operator, err := c.next()
+	if err != nil {
+		return err
+	}
+	switch operator {
+	case "+=":
+		r, err := c.register()
+		if err != nil {
+			return err
+		}
+		return c.op(0xF01E | r<<8)
+	case ":=":
+	default:
+		return c.fail("unknown operator %s for i", operator)
+	}
+	switch c.peek() {
+	case "hex", "bighex":
+		t, _ := c.next()
+		r, err := c.register()
+		if err != nil {
+			return err
+		}
+		return c.op(map[string]int{"hex": 0xF029, "bighex": 0xF030}[t] | r<<8)
+	case "long":
+		c.next()
+		t, err := c.next()
+		if err != nil {
+			return err
+		}
+		at := c.here
+		if err := c.emit(0xF0, 0x00, 0x00, 0x00); err != nil {
+			return err
+		}
+		return c.refer(t, at+2, ref16, 0)
+	}
+	return c.address(0xA000)
+}
+
+// ifStatement compiles if ... then and if ... begin.
+func (c *compiler) ifStatement() error {
+	// The condition is read ahead of time to see which form this is.
+	start := c.pos
+	depth := 0
+	for i := start; i < len(c.tokens) && depth == 0; i++ {
+		switch c.tokens[i].text {
+		case "then":
+			depth = 1
+		case "begin":
+			depth = 2
+		}
+	}
+	switch depth {
+	case 1:
+		if err := c.condition(false); err != nil {
+			return err
+		}
+		return c.expect("then")
+	case 2:
+		if err := c.condition(true); err != nil {
+			return err
+		}
+		if err := c.expect("begin"); err != nil {
+			return err
+		}
+		c.branches = append(c.branches, branch{jump: c.here, line: c.line})
+		return c.op(0x1000)
+	}
+	return c.fail("if without a then or begin")
+}
+
+// inverse is the comparison that's true when the other one isn't.
+var inverse = map[string]string{
+	"==": "!=", "!=": "==", "key": "-key", "-key": "key",
+	"<": ">=", ">=": "<", ">": "<=", "<=": ">",
+}
+
+// condition compiles a condition, such as v1 == 5 or v2 -key, as a skip
+// over the next instruction when the condition is false, or when it's true
+// if negated.
+func (c *compiler) condition(negated bool) error {
+	x, err := c.register()
+	if err != nil {
+		return err
+	}
+	comparison, err := c.next()
+	if err != nil {
+		return err
+	}
+	if _, ok := inverse[comparison]; !ok {
+		return c.fail("unknown comparison %s", comparison)
+	}
+	if negated {
+		comparison = inverse[comparison]
+	}
+	switch comparison {
+	case "key":
+		return c.op(0xE0A1 | x<<8)
+	case "-key":
+		return c.op(0xE09E | x<<8)
+	}
+
+	y, isRegister := c.isRegister(c.peek())
+	n := 0
+	if isRegister {
+		c.next()
+	} else if n, err = c.value(-128, 255); err != nil {
+		return err
+	}
+	switch comparison {
+	case "==":
+		if isRegister {
+			return c.op(0x9000 | x<<8 | y<<4)
+		}
+		return c.op(0x4000 | x<<8 | n&0xFF)
+	case "!=":
+		if isRegister {
+			return c.op(0x5000 | x<<8 | y<<4)
+		}
+		return c.op(0x3000 | x<<8 | n&0xFF)
+	}
+
+	// The others put the right hand side in vf, subtract so that vf ends up
+	// with the no borrow flag and skip on that.
+	if isRegister {
+		err = c.op(0x8F00 | y<<4)
+	} else {
+		err = c.op(0x6F00 | n&0xFF)
+	}
+	if err != nil {
+		return err
+	}
+	switch comparison {
+	case ">": // vf = rhs - x, no borrow when x <= rhs
+		return c.emit(byte(0x8F), byte(x<<4|0x5), 0x3F, 0x01)
+	case "<=":
+		return c.emit(byte(0x8F), byte(x<<4|0x5), 0x3F, 0x00)
+	case "<": // vf = x - rhs, no borrow when x >= rhs
+		return c.emit(byte(0x8F), byte(x<<4|0x7), 0x3F, 0x01)
+	default: // >=
+		return c.emit(byte(0x8F), byte(x<<4|0x7), 0x3F, 0x00)
+	}
+}
+
+// expand replaces a use of a macro with its body.
+func (c *compiler) expand(name string, m macro) error {
+	c.expansions++
+	if c.expansions > maxExpansions {
+		return c.fail("macro %s expands forever", name)
+	}
+	args := map[string]string{}
+	for _, arg := range m.args {
+		t, err := c.next()
+		if err != nil {
+			return err
+		}
+		args[arg] = t
+	}
+	// The body is put on the line the macro is used on, which is where
+	// its mistakes are reported.
+	body := make([]token, len(m.body))
+	for i, t := range m.body {
+		if arg, ok := args[t.text]; ok {
+			t.text = arg
+		}
+		t.line = c.line
+		body[i] = t
+	}
+	// What's been compiled already is dropped so that the tokens don't
+	// grow with every expansion.
+	c.tokens = append(body, c.tokens[c.pos:]...)
+	c.pos = 0
+	return nil
+}
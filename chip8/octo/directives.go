@@ -0,0 +1,385 @@
+package octo
+
+import (
+	"math"
+	"strconv"
+)
+
+// directive compiles the statements starting with a colon.
+func (c *compiler) directive(t string) error {
+	switch t {
+	case ":":
+		name, err := c.next()
+		if err != nil {
+			return err
+		}
+		return c.defineLabel(name, c.here)
+	case ":next":
+		name, err := c.next()
+		if err != nil {
+			return err
+		}
+		// The label is on the second byte of the next instruction, so
+		// that code can change its operand.
+		return c.defineLabel(name, c.here+1)
+	case ":const":
+		name, err := c.next()
+		if err != nil {
+			return err
+		}
+		v, err := c.value(math.MinInt32, math.MaxInt32)
+		if err != nil {
+			return err
+		}
+		if err := c.checkName(name); err != nil {
+			return err
+		}
+		c.constants[name] = float64(v)
+		return nil
+	case ":calc":
+		name, err := c.next()
+		if err != nil {
+			return err
+		}
+		v, err := c.calc()
+		if err != nil {
+			return err
+		}
+		if err := c.checkName(name); err != nil {
+			return err
+		}
+		c.constants[name] = v
+		return nil
+	case ":alias":
+		name, err := c.next()
+		if err != nil {
+			return err
+		}
+		r, err := c.register()
+		if err != nil {
+			return err
+		}
+		if _, ok := register(name); ok {
+			return c.fail("%s is a register", name)
+		}
+		c.aliases[name] = r
+		return nil
+	case ":unpack":
+		nybble := 0
+		kind := refUnpack
+		if c.peek() == "long" {
+			c.next()
+			kind = refUnpackL
+		} else {
+			v, err := c.value(0, 15)
+			if err != nil {
+				return err
+			}
+			nybble = v
+		}
+		name, err := c.next()
+		if err != nil {
+			return err
+		}
+		at := c.here
+		if err := c.emit(0x60, 0x00, 0x61, 0x00); err != nil {
+			return err
+		}
+		return c.refer(name, at, kind, nybble)
+	case ":org":
+		v, err := c.number()
+		if err != nil {
+			return err
+		}
+		if v < 0x200 || v > 0xFFFF {
+			return c.fail(":org 0x%X is outside the program's memory", int(v))
+		}
+		c.here = int(v)
+		return nil
+	case ":byte":
+		v, err := c.number()
+		if err != nil {
+			return err
+		}
+		if v < -128 || v > 255 {
+			return c.fail("%v doesn't fit in a byte", v)
+		}
+		return c.emit(byte(int(v)))
+	case ":pointer":
+		name, err := c.next()
+		if err != nil {
+			return err
+		}
+		at := c.here
+		if err := c.emit(0x00, 0x00); err != nil {
+			return err
+		}
+		return c.refer(name, at, ref16, 0)
+	case ":call":
+		return c.address(0x2000)
+	case ":macro":
+		return c.defineMacro()
+	case ":breakpoint":
+		name, err := c.next()
+		if err != nil {
+			return err
+		}
+		c.breakpoints[name] = c.here
+		return nil
+	case ":monitor":
+		// Monitors are for Octo's debugger and don't change the program.
+		for i := 0; i < 2; i++ {
+			if _, err := c.next(); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return c.fail("unknown directive %s", t)
+}
+
+// defineMacro reads a macro's name, arguments and body.
+func (c *compiler) defineMacro() error {
+	name, err := c.next()
+	if err != nil {
+		return err
+	}
+	var m macro
+	for {
+		t, err := c.next()
+		if err != nil {
+			return err
+		}
+		if t == "{" {
+			break
+		}
+		m.args = append(m.args, t)
+	}
+	for depth := 1; ; {
+		if c.done() {
+			return c.fail("macro %s has no closing }", name)
+		}
+		t := c.tokens[c.pos]
+		c.pos++
+		switch t.text {
+		case "{":
+			depth++
+		case "}":
+			depth--
+		}
+		if depth == 0 {
+			break
+		}
+		m.body = append(m.body, t)
+	}
+	c.macros[name] = m
+	return nil
+}
+
+// number reads a number, constant, defined label or { expression }.
+func (c *compiler) number() (float64, error) {
+	if c.peek() == "{" {
+		return c.calc()
+	}
+	t, err := c.next()
+	if err != nil {
+		return 0, err
+	}
+	v, ok := c.known(t)
+	if !ok {
+		return 0, c.fail("expected a number, not %s", t)
+	}
+	if f, ok := c.constants[t]; ok {
+		return f, nil
+	}
+	return float64(v), nil
+}
+
+// calc evaluates an expression in braces. As in Octo there is no
+// precedence: operators are applied from right to left, so 2 * 3 + 1 is 8,
+// and parentheses group.
+func (c *compiler) calc() (float64, error) {
+	if err := c.expect("{"); err != nil {
+		return 0, err
+	}
+	var expression []string
+	for {
+		t, err := c.next()
+		if err != nil {
+			return 0, err
+		}
+		if t == "}" {
+			break
+		}
+		expression = append(expression, t)
+	}
+	e := &evaluator{c: c, tokens: expression}
+	v, err := e.expression()
+	if err != nil {
+		return 0, err
+	}
+	if e.pos < len(e.tokens) {
+		return 0, c.fail("unexpected %s in expression", e.tokens[e.pos])
+	}
+	return v, nil
+}
+
+var unary = map[string]func(float64) float64{
+	"-":     func(x float64) float64 { return -x },
+	"~":     func(x float64) float64 { return float64(^int(x)) },
+	"!":     func(x float64) float64 { return boolean(x == 0) },
+	"sin":   math.Sin,
+	"cos":   math.Cos,
+	"tan":   math.Tan,
+	"exp":   math.Exp,
+	"log":   math.Log,
+	"abs":   math.Abs,
+	"sqrt":  math.Sqrt,
+	"sign":  sign,
+	"ceil":  math.Ceil,
+	"floor": math.Floor,
+}
+
+var binary = map[string]func(x, y float64) float64{
+	"-":   func(x, y float64) float64 { return x - y },
+	"+":   func(x, y float64) float64 { return x + y },
+	"*":   func(x, y float64) float64 { return x * y },
+	"/":   func(x, y float64) float64 { return x / y },
+	"%":   func(x, y float64) float64 { return float64(int(x) % int(y)) },
+	"&":   func(x, y float64) float64 { return float64(int(x) & int(y)) },
+	"|":   func(x, y float64) float64 { return float64(int(x) | int(y)) },
+	"^":   func(x, y float64) float64 { return float64(int(x) ^ int(y)) },
+	"<<":  func(x, y float64) float64 { return float64(int(x) << uint(y)) },
+	">>":  func(x, y float64) float64 { return float64(int(x) >> uint(y)) },
+	"pow": math.Pow,
+	"min": math.Min,
+	"max": math.Max,
+	"<":   func(x, y float64) float64 { return boolean(x < y) },
+	"<=":  func(x, y float64) float64 { return boolean(x <= y) },
+	"==":  func(x, y float64) float64 { return boolean(x == y) },
+	"!=":  func(x, y float64) float64 { return boolean(x != y) },
+	">=":  func(x, y float64) float64 { return boolean(x >= y) },
+	">":   func(x, y float64) float64 { return boolean(x > y) },
+}
+
+func boolean(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func sign(x float64) float64 {
+	switch {
+	case x < 0:
+		return -1
+	case x > 0:
+		return 1
+	}
+	return 0
+}
+
+// evaluator works through the tokens of a :calc expression.
+type evaluator struct {
+	c      *compiler
+	tokens []string
+	pos    int
+}
+
+func (e *evaluator) next() (string, error) {
+	if e.pos >= len(e.tokens) {
+		return "", e.c.fail("expression ends too soon")
+	}
+	t := e.tokens[e.pos]
+	e.pos++
+	return t, nil
+}
+
+// expression is a term followed, optionally, by an operator and another
+// expression, which makes operators apply from the right.
+func (e *evaluator) expression() (float64, error) {
+	x, err := e.term()
+	if err != nil {
+		return 0, err
+	}
+	if e.pos >= len(e.tokens) || e.tokens[e.pos] == ")" {
+		return x, nil
+	}
+	operator, _ := e.next()
+	f, ok := binary[operator]
+	if !ok {
+		return 0, e.c.fail("unknown operator %s", operator)
+	}
+	y, err := e.expression()
+	if err != nil {
+		return 0, err
+	}
+	if operator == "%" && int(y) == 0 || operator == "/" && y == 0 {
+		return 0, e.c.fail("%s by zero", operator)
+	}
+	return e.number(operator, f(x, y))
+}
+
+// number fails when operator has given something that isn't a number, like
+// the log of a negative, rather than let it become a byte or a constant.
+func (e *evaluator) number(operator string, v float64) (float64, error) {
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return 0, e.c.fail("%s doesn't give a number here", operator)
+	}
+	return v, nil
+}
+
+func (e *evaluator) term() (float64, error) {
+	t, err := e.next()
+	if err != nil {
+		return 0, err
+	}
+	if t == "(" {
+		v, err := e.expression()
+		if err != nil {
+			return 0, err
+		}
+		if closing, err := e.next(); err != nil || closing != ")" {
+			return 0, e.c.fail("( without a )")
+		}
+		return v, nil
+	}
+	if t == "@" {
+		address, err := e.term()
+		if err != nil {
+			return 0, err
+		}
+		if address < 0 || int(address) >= len(e.c.memory) {
+			return 0, e.c.fail("@ 0x%X is outside memory", int(address))
+		}
+		return float64(e.c.memory[int(address)]), nil
+	}
+	if f, ok := unary[t]; ok {
+		x, err := e.term()
+		if err != nil {
+			return 0, err
+		}
+		return e.number(t, f(x))
+	}
+	switch t {
+	case "HERE":
+		return float64(e.c.here), nil
+	case "PI":
+		return math.Pi, nil
+	case "E":
+		return math.E, nil
+	}
+	if v, ok := e.c.constants[t]; ok {
+		return v, nil
+	}
+	if v, ok := e.c.labels[t]; ok {
+		return float64(v), nil
+	}
+	if v, err := strconv.ParseFloat(t, 64); err == nil {
+		return v, nil
+	}
+	if v, ok := parseNumber(t); ok {
+		return float64(v), nil
+	}
+	return 0, e.c.fail("%s isn't defined", t)
+}
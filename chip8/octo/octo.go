@@ -0,0 +1,140 @@
+// Package octo compiles programs written in Octo, the high level assembly
+// language most CHIP-8, SUPER-CHIP and XO-CHIP homebrew is written in, into
+// ROMs the chip8 package can load.
+//
+// It follows the language as described in Octo's manual: labels, :const,
+// :alias, :calc, :macro, :unpack, :next, :org, :byte and :pointer,
+// loop/while/again, if/then and if/begin/else/end, sprite data written as
+// bare numbers and the SUPER-CHIP and XO-CHIP instructions. Like Octo, the
+// program starts with a jump to the label main.
+package octo
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Error is a problem with a line of an Octo program.
+type Error struct {
+	File string
+	Line int
+	Err  string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s:%d: %s", e.File, e.Line, e.Err)
+}
+
+// Program is a compiled program.
+type Program struct {
+	Code        []byte         // the ROM, loaded at 0x200
+	Symbols     map[string]int // the address of every label
+	Breakpoints map[string]int // the address of every :breakpoint
+}
+
+// WriteSymbols writes the program's labels, one to a line with its address
+// in front, in order of address.
+func (p *Program) WriteSymbols(w io.Writer) error {
+	names := make([]string, 0, len(p.Symbols))
+	for name := range p.Symbols {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		a, b := p.Symbols[names[i]], p.Symbols[names[j]]
+		if a != b {
+			return a < b
+		}
+		return names[i] < names[j]
+	})
+	b := bufio.NewWriter(w)
+	for _, name := range names {
+		fmt.Fprintf(b, "0x%03X %s\n", p.Symbols[name], name)
+	}
+	return b.Flush()
+}
+
+// token is a word of the program and the line it's on.
+type token struct {
+	text string
+	line int
+}
+
+// tokenize splits source into words, leaving out comments, which run from
+// a # to the end of the line.
+func tokenize(r io.Reader) ([]token, error) {
+	var tokens []token
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := scanner.Text()
+		if hash := strings.Index(text, "#"); hash >= 0 {
+			text = text[:hash]
+		}
+		for _, field := range strings.Fields(text) {
+			tokens = append(tokens, token{text: field, line: line})
+		}
+	}
+	return tokens, scanner.Err()
+}
+
+// Compile compiles the Octo program read from r. The name is only used in
+// errors.
+func Compile(name string, r io.Reader) (*Program, error) {
+	tokens, err := tokenize(r)
+	if err != nil {
+		return nil, err
+	}
+	c := newCompiler(tokens)
+	if err := c.compile(); err != nil {
+		if e, ok := err.(*Error); ok {
+			e.File = name
+		}
+		return nil, err
+	}
+	return &Program{Code: c.rom(), Symbols: c.labels, Breakpoints: c.breakpoints}, nil
+}
+
+// CompileFile compiles the Octo program in the file at path.
+func CompileFile(path string) (*Program, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return Compile(path, f)
+}
+
+// parseNumber reads a decimal, 0x hex or 0b binary number, which may be
+// negative.
+func parseNumber(s string) (int, bool) {
+	negative := strings.HasPrefix(s, "-")
+	digits := strings.TrimPrefix(s, "-")
+	base := 10
+	switch {
+	case strings.HasPrefix(digits, "0x"), strings.HasPrefix(digits, "0X"):
+		digits, base = digits[2:], 16
+	case strings.HasPrefix(digits, "0b"), strings.HasPrefix(digits, "0B"):
+		digits, base = digits[2:], 2
+	}
+	v, err := strconv.ParseUint(digits, base, 32)
+	if err != nil {
+		return 0, false
+	}
+	if negative {
+		return -int(v), true
+	}
+	return int(v), true
+}
+
+// register returns the number of a register written v0 to vf.
+func register(s string) (int, bool) {
+	if len(s) != 2 || (s[0] != 'v' && s[0] != 'V') {
+		return 0, false
+	}
+	n, err := strconv.ParseUint(s[1:], 16, 4)
+	return int(n), err == nil
+}
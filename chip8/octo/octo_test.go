@@ -0,0 +1,254 @@
+package octo
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/h4ck3rk3y/go-8/chip8"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func compile(t *testing.T, source string) *Program {
+	p, err := Compile("test.8o", strings.NewReader(source))
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	return p
+}
+
+func TestCompileStatements(t *testing.T) {
+	p := compile(t, `
+: main
+	clear
+	v1 := 0x20
+	v1 += 3
+	v1 -= 1
+	v2 := v1
+	v2 <<= v1
+	v3 := random 0x0F
+	v4 := key
+	v5 := delay
+	delay := v5
+	buzzer := v5
+	i := hex v1
+	i += v2
+	bcd v3
+	save v3
+	load v3
+	sprite v1 v2 5
+	return
+`)
+	assert.Equal(t, []byte{
+		0x12, 0x02,
+		0x00, 0xE0,
+		0x61, 0x20,
+		0x71, 0x03,
+		0x71, 0xFF,
+		0x82, 0x10,
+		0x82, 0x1E,
+		0xC3, 0x0F,
+		0xF4, 0x0A,
+		0xF5, 0x07,
+		0xF5, 0x15,
+		0xF5, 0x18,
+		0xF1, 0x29,
+		0xF2, 0x1E,
+		0xF3, 0x33,
+		0xF3, 0x55,
+		0xF3, 0x65,
+		0xD1, 0x25,
+		0x00, 0xEE,
+	}, p.Code)
+	assert.Equal(t, 0x202, p.Symbols["main"])
+}
+
+func TestCompileLabelsCallsAndSprites(t *testing.T) {
+	p := compile(t, `
+: draw-ball
+	i := ball
+	sprite v0 v1 2
+;
+: main
+	draw-ball
+	jump main
+: ball
+	0b10000000 0x80
+`)
+	assert.Equal(t, []byte{
+		0x12, 0x08, // jump main
+		0xA2, 0x0C, // i := ball
+		0xD0, 0x12,
+		0x00, 0xEE,
+		0x22, 0x02, // draw-ball
+		0x12, 0x08,
+		0x80, 0x80,
+	}, p.Code[:14])
+	var b bytes.Buffer
+	assert.NoError(t, p.WriteSymbols(&b))
+	assert.Equal(t, "0x202 draw-ball\n0x208 main\n0x20C ball\n", b.String())
+}
+
+func TestCompileConstAliasCalcAndMacros(t *testing.T) {
+	p := compile(t, `
+:const SPEED 3
+:alias x v4
+:calc DOUBLE { SPEED * 2 + 1 }
+:macro move reg amount { reg += amount }
+: main
+	x := SPEED
+	move x DOUBLE
+	:byte { 10 - 3 - 2 }
+	:byte { ( 10 - 3 ) - 2 }
+`)
+	// Octo works right to left: 3 * (2 + 1) and 10 - (3 - 2).
+	assert.Equal(t, []byte{0x12, 0x02, 0x64, 0x03, 0x74, 0x09, 9, 5}, p.Code)
+}
+
+func TestCompileXOChip(t *testing.T) {
+	p := compile(t, `
+: main
+	hires
+	plane 3
+	i := long data
+	save v1 - v4
+	load v4 - v1
+	audio
+	pitch := v2
+	scroll-up 2
+	scroll-down 3
+	scroll-left
+	exit
+	:org 0x1200
+: data
+	:pointer main
+	:unpack long data
+`)
+	assert.Equal(t, []byte{
+		0x12, 0x02,
+		0x00, 0xFF,
+		0xF3, 0x01,
+		0xF0, 0x00, 0x12, 0x00,
+		0x51, 0x42,
+		0x54, 0x13,
+		0xF0, 0x02,
+		0xF2, 0x3A,
+		0x00, 0xD2,
+		0x00, 0xC3,
+		0x00, 0xFC,
+		0x00, 0xFD,
+	}, p.Code[:26])
+	assert.Equal(t, []byte{0x02, 0x02, 0x60, 0x12, 0x61, 0x00}, p.Code[0x1000:])
+}
+
+func TestCompileNextAndUnpack(t *testing.T) {
+	p := compile(t, `
+: main
+	:next target
+	v0 := 0
+	:unpack 0xA main
+`)
+	assert.Equal(t, 0x203, p.Symbols["target"])
+	assert.Equal(t, []byte{0x12, 0x02, 0x60, 0x00, 0x60, 0xA2, 0x61, 0x02}, p.Code)
+}
+
+// run compiles a program and runs it until it exits.
+func run(t *testing.T, source string) *chip8.Machine {
+	p := compile(t, source)
+	m := chip8.New(chip8.WithVariant(chip8.VariantXOChip), chip8.WithQuirks(chip8.QuirksXOChip))
+	_, err := m.Load(bytes.NewReader(p.Code))
+	assert.NoError(t, err)
+	for i := 0; i < 10000; i++ {
+		if err := m.Step(); err != nil {
+			assert.Equal(t, chip8.ErrExit, err)
+			return m
+		}
+	}
+	t.Fatal("program didn't exit")
+	return nil
+}
+
+func TestComparisons(t *testing.T) {
+	tests := []struct {
+		a, b       int
+		comparison string
+		want       bool
+	}{
+		{1, 2, "<", true}, {2, 2, "<", false}, {3, 2, "<", false},
+		{1, 2, ">", false}, {2, 2, ">", false}, {3, 2, ">", true},
+		{1, 2, "<=", true}, {2, 2, "<=", true}, {3, 2, "<=", false},
+		{1, 2, ">=", false}, {2, 2, ">=", true}, {3, 2, ">=", true},
+		{2, 2, "==", true}, {2, 3, "==", false},
+		{2, 2, "!=", false}, {2, 3, "!=", true},
+	}
+	for _, test := range tests {
+		for _, rhs := range []string{"v1", fmt.Sprint(test.b)} {
+			source := fmt.Sprintf(`
+: main
+	v0 := %d
+	v1 := %d
+	v2 := 0
+	if v0 %s %s then v2 := 1
+	v3 := 0
+	if v0 %s %s begin v3 := 1 else v3 := 2 end
+	exit
+`, test.a, test.b, test.comparison, rhs, test.comparison, rhs)
+			m := run(t, source)
+			want := map[bool]byte{true: 1, false: 0}[test.want]
+			assert.Equal(t, want, m.V[2], "%d %s %s then", test.a, test.comparison, rhs)
+			assert.Equal(t, 2-want, m.V[3], "%d %s %s begin", test.a, test.comparison, rhs)
+		}
+	}
+}
+
+func TestLoops(t *testing.T) {
+	m := run(t, `
+: main
+	v0 := 0
+	v1 := 0
+	loop
+		v0 += 1
+		while v0 != 10
+		v1 += 2
+	again
+	exit
+`)
+	assert.Equal(t, byte(10), m.V[0])
+	assert.Equal(t, byte(18), m.V[1])
+}
+
+func TestCompileErrors(t *testing.T) {
+	tests := []struct {
+		source string
+		line   int
+		want   string
+	}{
+		{"clear", 1, "no main"},
+		{": main\n\n  v1 := 256", 3, "outside"},
+		{": main\n  nowhere", 2, "never defined"},
+		{": main\n: main", 2, "already defined"},
+		{": main\n  loop\n  clear", 2, "without an again"},
+		{": main\n  again", 2, "without a loop"},
+		{": main\n  if v0 == 1 begin\n  clear", 2, "without an end"},
+		{": main\n  if v0 == 1 clear", 2, "without a then"},
+		{": main\n  v0 ?= 1", 2, "unknown operator"},
+		{": main\n  :frobnicate", 2, "unknown directive"},
+		{": main\n  :macro m { m }\n  m", 3, "forever"},
+		{": main\n  jump far\n:org 0x1100 : far", 2, "12 bits"},
+		{": main :calc x { 5 % 0 } ;", 1, "% by zero"},
+		{": main\n  :byte { 5 % 0 }", 2, "% by zero"},
+		{": main\n  :byte { 5 / 0 }", 2, "/ by zero"},
+		{": main\n  :calc x { 0 - 1 }\n  :byte { sqrt x }", 3, "sqrt doesn't give a number"},
+		{": main\n  :byte { log 0 }", 2, "log doesn't give a number"},
+		{": main\n  :byte { 10 pow 400 }", 2, "pow doesn't give a number"},
+	}
+	for _, test := range tests {
+		_, err := Compile("test.8o", strings.NewReader(test.source))
+		if assert.IsType(t, &Error{}, err, test.source) {
+			e := err.(*Error)
+			assert.Equal(t, test.line, e.Line, test.source)
+			assert.Contains(t, e.Err, test.want, test.source)
+			assert.Equal(t, "test.8o", e.File)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package chip8
+
+import "log"
+
+// Option configures a Machine made by New.
+type Option func(*Machine)
+
+// WithVariant picks the kind of machine to emulate.
+func WithVariant(variant Variant) Option {
+	return func(m *Machine) {
+		m.variant = variant
+	}
+}
+
+// WithQuirks picks the behaviour for the instructions interpreters disagree
+// on.
+func WithQuirks(quirks Quirks) Option {
+	return func(m *Machine) {
+		m.quirks = quirks
+	}
+}
+
+// WithSeed seeds the random numbers CXNN makes, so that a program run twice
+// with the same seed and input does the same thing. Without it the seed
+// comes from the clock.
+func WithSeed(seed int64) Option {
+	return func(m *Machine) {
+		m.seed = seed
+	}
+}
+
+// WithRandomMode picks how CXNN makes its random numbers.
+func WithRandomMode(mode RandomMode) Option {
+	return func(m *Machine) {
+		m.randomMode = mode
+	}
+}
+
+// WithOpcodePolicy decides what happens to instructions the machine can't
+// decode.
+func WithOpcodePolicy(policy OpcodePolicy) Option {
+	return func(m *Machine) {
+		m.opcodePolicy = policy
+	}
+}
+
+// WithLogger sends the machine's logging, such as unknown opcodes under
+// LogUnknown, to logger instead of the standard logger.
+func WithLogger(logger *log.Logger) Option {
+	return func(m *Machine) {
+		m.logger = logger
+	}
+}
+
+// WithDisplay has the machine draw to display.
+func WithDisplay(display Display) Option {
+	return func(m *Machine) {
+		m.screen = display
+	}
+}
+
+// WithKeypad has the machine read keys from keypad rather than have them
+// set with SetKey.
+func WithKeypad(keypad Keypad) Option {
+	return func(m *Machine) {
+		m.keypad = keypad
+	}
+}
+
+// WithSound has the machine sound its buzzer through sound.
+func WithSound(sound Sound) Option {
+	return func(m *Machine) {
+		m.sound = sound
+	}
+}
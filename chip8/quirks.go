@@ -0,0 +1,92 @@
+package chip8
+
+import "fmt"
+
+// MemoryIncrement is how far FX55 and FX65 move I once they're done.
+type MemoryIncrement int
+
+const (
+	// IncrementNone leaves I where it was.
+	IncrementNone MemoryIncrement = iota
+	// IncrementX adds X to I, as CHIP-48 did.
+	IncrementX
+	// IncrementXPlus1 leaves I just past the last register stored or
+	// loaded, as the COSMAC VIP did.
+	IncrementXPlus1
+)
+
+// Quirks picks between the behaviours CHIP-8 interpreters have disagreed
+// on over the years. ROMs are usually written against one interpreter and
+// only run correctly with its quirks.
+type Quirks struct {
+	// VFReset clears VF after 8XY1, 8XY2 and 8XY3.
+	VFReset bool
+	// ShiftUsesVY makes 8XY6 and 8XYE shift VY into VX rather than
+	// shifting VX in place.
+	ShiftUsesVY bool
+	// MemoryIncrement is what FX55 and FX65 do to I.
+	MemoryIncrement MemoryIncrement
+	// JumpUsesVX makes BXNN jump to XNN plus VX instead of NNN plus V0.
+	JumpUsesVX bool
+	// ClipSprites cuts sprites off at the edges of the screen instead of
+	// wrapping them around to the other side.
+	ClipSprites bool
+}
+
+var (
+	// QuirksCosmacVIP is the original interpreter on the RCA COSMAC VIP.
+	QuirksCosmacVIP = Quirks{
+		VFReset:         true,
+		ShiftUsesVY:     true,
+		MemoryIncrement: IncrementXPlus1,
+		ClipSprites:     true,
+	}
+	// QuirksChip48 is CHIP-48 on the HP-48 calculators.
+	QuirksChip48 = Quirks{
+		MemoryIncrement: IncrementX,
+		JumpUsesVX:      true,
+		ClipSprites:     true,
+	}
+	// QuirksSChip11 is SUPER-CHIP 1.1.
+	QuirksSChip11 = Quirks{
+		JumpUsesVX:  true,
+		ClipSprites: true,
+	}
+	// QuirksXOChip is Octo's XO-CHIP.
+	QuirksXOChip = Quirks{
+		ShiftUsesVY:     true,
+		MemoryIncrement: IncrementXPlus1,
+	}
+	// QuirksModern is what most present day interpreters, this one
+	// included until quirks became configurable, do.
+	QuirksModern = Quirks{}
+)
+
+// ParseQuirks returns the preset called name, which is one of vip, chip48,
+// schip, xochip or modern. Case, dashes and underscores don't matter.
+func ParseQuirks(name string) (Quirks, error) {
+	switch normalizeName(name) {
+	case "vip", "cosmacvip":
+		return QuirksCosmacVIP, nil
+	case "chip48":
+		return QuirksChip48, nil
+	case "schip", "schip11", "superchip":
+		return QuirksSChip11, nil
+	case "xochip":
+		return QuirksXOChip, nil
+	case "modern":
+		return QuirksModern, nil
+	}
+	return Quirks{}, fmt.Errorf("unknown quirks %q, want vip, chip48, schip, xochip or modern", name)
+}
+
+// DefaultQuirks are the quirks programs written for variant usually expect.
+func DefaultQuirks(variant Variant) Quirks {
+	switch variant {
+	case VariantSChip:
+		return QuirksSChip11
+	case VariantXOChip:
+		return QuirksXOChip
+	}
+	return QuirksModern
+}
@@ -0,0 +1,80 @@
+package chip8
+
+import (
+	"fmt"
+	"time"
+)
+
+// RandomMode picks how CXNN comes up with its random numbers.
+type RandomMode int
+
+const (
+	// RandomXorshift uses a 32 bit xorshift generator, the default.
+	RandomXorshift RandomMode = iota
+	// RandomVIP works the way the COSMAC VIP interpreter did: a 16 bit
+	// seed is counted up on every CXNN and the byte its low half points to
+	// in the first page of memory is added to its high half, which is the
+	// random number. The VIP's first page held the interpreter itself while
+	// here it holds the fonts, so the numbers aren't the VIP's, but they
+	// are just as predictable and short lived.
+	RandomVIP
+)
+
+// String is the name of the mode.
+func (r RandomMode) String() string {
+	switch r {
+	case RandomXorshift:
+		return "xorshift"
+	case RandomVIP:
+		return "vip"
+	}
+	return "unknown"
+}
+
+// ParseRandomMode returns the random mode called name, xorshift or vip.
+func ParseRandomMode(name string) (RandomMode, error) {
+	switch normalizeName(name) {
+	case "xorshift":
+		return RandomXorshift, nil
+	case "vip", "cosmacvip":
+		return RandomVIP, nil
+	}
+	return 0, fmt.Errorf("unknown random mode %q, want xorshift or vip", name)
+}
+
+// timeSeed is the seed for machines that weren't given one.
+func timeSeed() int64 {
+	return time.Now().UnixNano()
+}
+
+// seedRandom puts the random number generator back to its state for
+// m.seed.
+func (m *Machine) seedRandom() {
+	switch m.randomMode {
+	case RandomVIP:
+		m.rng = uint32(m.seed) & 0xFFFF
+	default:
+		// Fold the whole seed into 32 bits, none of which can be zero
+		// for xorshift to work.
+		m.rng = uint32(m.seed) ^ uint32(m.seed>>32)
+		if m.rng == 0 {
+			m.rng = 0x2545F491
+		}
+	}
+}
+
+// random returns the next random byte.
+func (m *Machine) random() byte {
+	switch m.randomMode {
+	case RandomVIP:
+		m.rng = (m.rng + 1) & 0xFFFF
+		high := byte(m.rng>>8) + m.memory[m.rng&0xFF]
+		m.rng = uint32(high)<<8 | m.rng&0xFF
+		return high
+	default:
+		m.rng ^= m.rng << 13
+		m.rng ^= m.rng >> 17
+		m.rng ^= m.rng << 5
+		return byte(m.rng >> 24)
+	}
+}
@@ -0,0 +1,66 @@
+package chip8
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func randomBytes(m *Machine, n int) []byte {
+	b := make([]byte, n)
+	for i := range b {
+		b[i] = m.random()
+	}
+	return b
+}
+
+func TestSeedRepeatsRandomNumbers(t *testing.T) {
+	for _, mode := range []RandomMode{RandomXorshift, RandomVIP} {
+		a := New(WithSeed(1234), WithRandomMode(mode))
+		b := New(WithSeed(1234), WithRandomMode(mode))
+		assert.Equal(t, randomBytes(a, 64), randomBytes(b, 64), mode.String())
+	}
+}
+
+func TestRandomNumbersVary(t *testing.T) {
+	m := New(WithSeed(0))
+	seen := map[byte]bool{}
+	for _, b := range randomBytes(m, 1000) {
+		seen[b] = true
+	}
+	assert.True(t, len(seen) > 200)
+}
+
+func TestConsecutiveRandomNumbersDiffer(t *testing.T) {
+	// Reseeding from the clock on every CXNN used to make every number
+	// within the same second the same.
+	m := New()
+	copy(m.memory[0x200:], []byte{0xC0, 0xFF, 0xC1, 0xFF, 0xC2, 0xFF, 0xC3, 0xFF})
+	for i := 0; i < 4; i++ {
+		m.Step()
+	}
+	assert.False(t, m.V[0] == m.V[1] && m.V[1] == m.V[2] && m.V[2] == m.V[3])
+}
+
+func TestResetReseeds(t *testing.T) {
+	m := New(WithSeed(99))
+	first := randomBytes(m, 16)
+	m.Reset()
+	assert.Equal(t, first, randomBytes(m, 16))
+	assert.Equal(t, int64(99), m.Seed())
+}
+
+func TestVIPRandom(t *testing.T) {
+	m := New(WithSeed(0x0100), WithRandomMode(RandomVIP))
+	// The seed goes to 0x0101 and the byte at 0x01 is added to the high
+	// half: 0x01 + 0x90.
+	assert.Equal(t, byte(0x91), m.random())
+	assert.Equal(t, uint32(0x9101), m.rng)
+}
+
+func TestParseRandomMode(t *testing.T) {
+	mode, err := ParseRandomMode("COSMAC-VIP")
+	assert.NoError(t, err)
+	assert.Equal(t, RandomVIP, mode)
+	_, err = ParseRandomMode("dice")
+	assert.Error(t, err)
+}
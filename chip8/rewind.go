@@ -0,0 +1,64 @@
+package chip8
+
+import "bytes"
+
+// Rewind remembers the last few states of a machine so it can be taken
+// back in time, one recorded state at a time. States are kept as save
+// states, which for a CHIP-8 come to around 12k each.
+type Rewind struct {
+	states []bytes.Buffer // ring of save states
+	next   int            // where the next state is recorded
+	count  int            // states recorded and not yet rewound
+}
+
+// NewRewind returns a Rewind that holds up to size states, which is size /
+// 60 seconds when a state is recorded every frame.
+func NewRewind(size int) *Rewind {
+	return &Rewind{states: make([]bytes.Buffer, size)}
+}
+
+// Record remembers the state m is in now, forgetting the oldest state if
+// the Rewind is full.
+func (r *Rewind) Record(m *Machine) error {
+	if len(r.states) == 0 {
+		return nil
+	}
+	state := &r.states[r.next]
+	state.Reset()
+	if err := m.SaveState(state); err != nil {
+		return err
+	}
+	r.next = (r.next + 1) % len(r.states)
+	if r.count < len(r.states) {
+		r.count++
+	}
+	return nil
+}
+
+// Back puts m in the last state recorded and forgets it, so calling Back
+// again goes further back. It returns false, leaving m alone, once there is
+// nothing left to go back to.
+func (r *Rewind) Back(m *Machine) (bool, error) {
+	if r.count == 0 {
+		return false, nil
+	}
+	last := (r.next + len(r.states) - 1) % len(r.states)
+	if err := m.LoadState(bytes.NewReader(r.states[last].Bytes())); err != nil {
+		return false, err
+	}
+	r.next = last
+	r.count--
+	return true, nil
+}
+
+// Len is the number of states that can be gone back through.
+func (r *Rewind) Len() int {
+	return r.count
+}
+
+// Clear forgets every state, for when the machine has been reset or loaded
+// with something else.
+func (r *Rewind) Clear() {
+	r.next = 0
+	r.count = 0
+}
@@ -0,0 +1,83 @@
+package chip8
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+// counter is a machine that adds one to V0 every instruction.
+func counter() *Machine {
+	c := New()
+	copy(c.memory[0x200:], []byte{0x70, 0x01, 0x12, 0x00})
+	return c
+}
+
+func TestRewindGoesBackAFrameAtATime(t *testing.T) {
+	c := counter()
+	r := NewRewind(10)
+	for i := 0; i < 5; i++ {
+		assert.NoError(t, r.Record(c))
+		c.RunFrame(2)
+	}
+	assert.Equal(t, byte(5), c.V[0x0])
+	assert.Equal(t, 5, r.Len())
+
+	for want := 4; want >= 0; want-- {
+		ok, err := r.Back(c)
+		assert.NoError(t, err)
+		assert.True(t, ok)
+		assert.Equal(t, byte(want), c.V[0x0])
+	}
+	ok, err := r.Back(c)
+	assert.NoError(t, err)
+	assert.False(t, ok)
+	assert.Equal(t, byte(0), c.V[0x0])
+}
+
+func TestRewindForgetsOldestStates(t *testing.T) {
+	c := counter()
+	r := NewRewind(3)
+	for i := 0; i < 6; i++ {
+		r.Record(c)
+		c.RunFrame(2)
+	}
+	assert.Equal(t, 3, r.Len())
+	for i := 0; i < 3; i++ {
+		r.Back(c)
+	}
+	assert.Equal(t, byte(3), c.V[0x0])
+	ok, _ := r.Back(c)
+	assert.False(t, ok)
+}
+
+func TestRewindThenCarryOn(t *testing.T) {
+	c := counter()
+	r := NewRewind(10)
+	for i := 0; i < 4; i++ {
+		r.Record(c)
+		c.RunFrame(2)
+	}
+	r.Back(c)
+	r.Back(c)
+	assert.Equal(t, byte(2), c.V[0x0])
+
+	// Recording again after going back replaces the states that were
+	// rewound over.
+	r.Record(c)
+	c.RunFrame(2)
+	assert.Equal(t, 3, r.Len())
+	r.Back(c)
+	assert.Equal(t, byte(2), c.V[0x0])
+	r.Back(c)
+	assert.Equal(t, byte(1), c.V[0x0])
+}
+
+func TestRewindClear(t *testing.T) {
+	c := counter()
+	r := NewRewind(10)
+	r.Record(c)
+	r.Clear()
+	assert.Equal(t, 0, r.Len())
+	ok, _ := r.Back(c)
+	assert.False(t, ok)
+}
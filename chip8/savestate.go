@@ -0,0 +1,255 @@
+package chip8
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+)
+
+// A save state starts with a header of the magic bytes, the format version
+// and the length of the body that follows, and ends with a CRC-32 of the
+// body. The body is a savedState followed by the machine's memory.
+// Version 1 states, from before FX0A waited in the machine, can still be
+// loaded.
+const (
+	stateMagic   = "GO8S"
+	stateVersion = 2
+)
+
+// ErrNotSaveState is returned by LoadState for data that isn't a save
+// state at all.
+var ErrNotSaveState = errors.New("not a go-8 save state")
+
+// stateHeader starts every save state.
+type stateHeader struct {
+	Magic   [4]byte
+	Version uint16
+	Length  uint32 // bytes in the body
+}
+
+// savedState is everything in a save state but memory, laid out for
+// encoding/binary.
+type savedState struct {
+	Variant    uint8
+	PC         uint16
+	SP         uint16
+	Stack      [16]uint16
+	V          [16]byte
+	I          uint16
+	DelayTimer byte
+	SoundTimer byte
+	Display    [height][width]byte
+	Plane      byte
+	Pattern    [patternSize]byte
+	Pitch      byte
+	Hires      bool
+	RPL        [16]byte
+	Keys       [16]byte
+	Waiting    bool
+	WaitKey    byte
+	WaitIgnore uint16
+	RandomMode uint8
+	Seed       int64
+	RNG        uint32
+}
+
+// savedStateV1 is savedState as it was in version 1. InputFlag was set
+// when the last instruction was an FX0A, for the frontend to send pc back
+// to it until a key was down, and InputRegister was its X.
+type savedStateV1 struct {
+	Variant       uint8
+	PC            uint16
+	SP            uint16
+	Stack         [16]uint16
+	V             [16]byte
+	I             uint16
+	DelayTimer    byte
+	SoundTimer    byte
+	Display       [height][width]byte
+	Plane         byte
+	Pattern       [patternSize]byte
+	Pitch         byte
+	Hires         bool
+	RPL           [16]byte
+	Keys          [16]byte
+	InputFlag     bool
+	InputRegister byte
+	RandomMode    uint8
+	Seed          int64
+	RNG           uint32
+}
+
+// upgrade turns a version 1 state, with memory after it, into the current
+// one. An FX0A that pc had been sent back to becomes a wait that has just
+// started, so keys held down then don't count until they're let go.
+func (old *savedStateV1) upgrade(memory []byte) savedState {
+	state := savedState{
+		Variant:    old.Variant,
+		PC:         old.PC,
+		SP:         old.SP,
+		Stack:      old.Stack,
+		V:          old.V,
+		I:          old.I,
+		DelayTimer: old.DelayTimer,
+		SoundTimer: old.SoundTimer,
+		Display:    old.Display,
+		Plane:      old.Plane,
+		Pattern:    old.Pattern,
+		Pitch:      old.Pitch,
+		Hires:      old.Hires,
+		RPL:        old.RPL,
+		Keys:       old.Keys,
+		RandomMode: old.RandomMode,
+		Seed:       old.Seed,
+		RNG:        old.RNG,
+	}
+	pc := int(old.PC)
+	if old.InputFlag && pc+1 < len(memory) &&
+		memory[pc] == 0xF0|old.InputRegister&0x0F && memory[pc+1] == 0x0A {
+		state.Waiting = true
+		state.WaitKey = noKey
+		for key, down := range old.Keys {
+			if down == 0x01 {
+				state.WaitIgnore |= 1 << uint(key)
+			}
+		}
+	}
+	return state
+}
+
+// SaveState writes everything needed to carry on from where the machine is
+// now: registers, timers, memory, display, keys, a wait for a key and the
+// random number generator. Options such as the quirks and devices aren't
+// part of it.
+func (m *Machine) SaveState(w io.Writer) error {
+	state := savedState{
+		Variant:    uint8(m.variant),
+		PC:         m.pc,
+		SP:         m.sp,
+		Stack:      m.stack,
+		V:          m.V,
+		I:          m.I,
+		DelayTimer: m.delayTimer,
+		SoundTimer: m.soundTimer,
+		Display:    m.display,
+		Plane:      m.plane,
+		Pattern:    m.pattern,
+		Pitch:      m.pitch,
+		Hires:      m.hires,
+		RPL:        m.rpl,
+		Keys:       m.keys,
+		Waiting:    m.waiting,
+		WaitKey:    m.waitKey,
+		WaitIgnore: m.waitIgnore,
+		RandomMode: uint8(m.randomMode),
+		Seed:       m.seed,
+		RNG:        m.rng,
+	}
+	return writeState(w, stateVersion, &state, m.memory[:m.memorySize()])
+}
+
+// writeState writes a save state of the given version, with state and then
+// memory in its body.
+func writeState(w io.Writer, version uint16, state interface{}, memory []byte) error {
+	var body bytes.Buffer
+	binary.Write(&body, binary.BigEndian, state)
+	body.Write(memory)
+
+	header := stateHeader{Version: version, Length: uint32(body.Len())}
+	copy(header.Magic[:], stateMagic)
+	if err := binary.Write(w, binary.BigEndian, &header); err != nil {
+		return err
+	}
+	if _, err := w.Write(body.Bytes()); err != nil {
+		return err
+	}
+	return binary.Write(w, binary.BigEndian, crc32.ChecksumIEEE(body.Bytes()))
+}
+
+// LoadState puts the machine back in a state written by SaveState. The
+// machine has to be the same variant as the one that was saved. Nothing
+// changes if the state can't be loaded.
+func (m *Machine) LoadState(r io.Reader) error {
+	var header stateHeader
+	if err := binary.Read(r, binary.BigEndian, &header); err != nil {
+		return ErrNotSaveState
+	}
+	if string(header.Magic[:]) != stateMagic {
+		return ErrNotSaveState
+	}
+	var state savedState
+	var old savedStateV1
+	var size int
+	switch header.Version {
+	case 1:
+		size = binary.Size(&old)
+	case stateVersion:
+		size = binary.Size(&state)
+	default:
+		return fmt.Errorf("save state is version %d, only versions 1 to %d can be loaded", header.Version, stateVersion)
+	}
+	if want := size + m.memorySize(); int(header.Length) != want {
+		return fmt.Errorf("save state has %d bytes of state, a %v has %d", header.Length, m.variant, want)
+	}
+	body := make([]byte, header.Length)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return fmt.Errorf("save state is cut short: %v", err)
+	}
+	var checksum uint32
+	if err := binary.Read(r, binary.BigEndian, &checksum); err != nil {
+		return fmt.Errorf("save state is cut short: %v", err)
+	}
+	if checksum != crc32.ChecksumIEEE(body) {
+		return errors.New("save state is corrupt, its checksum doesn't match")
+	}
+	buf := bytes.NewReader(body)
+	if header.Version == 1 {
+		binary.Read(buf, binary.BigEndian, &old)
+		state = old.upgrade(body[size:])
+	} else {
+		binary.Read(buf, binary.BigEndian, &state)
+	}
+	if Variant(state.Variant) != m.variant {
+		return fmt.Errorf("save state is for a %v, not a %v", Variant(state.Variant), m.variant)
+	}
+	if int(state.SP) > len(m.stack) {
+		return fmt.Errorf("save state has a stack pointer of %d", state.SP)
+	}
+
+	m.pc = state.PC
+	m.sp = state.SP
+	m.stack = state.Stack
+	m.V = state.V
+	m.I = state.I
+	m.delayTimer = state.DelayTimer
+	m.soundTimer = state.SoundTimer
+	m.display = state.Display
+	m.plane = state.Plane
+	m.pattern = state.Pattern
+	m.pitch = state.Pitch
+	m.hires = state.Hires
+	m.rpl = state.RPL
+	m.keys = state.Keys
+	m.waiting = state.Waiting
+	m.waitKey = state.WaitKey
+	m.waitIgnore = state.WaitIgnore
+	m.randomMode = RandomMode(state.RandomMode)
+	m.seed = state.Seed
+	m.rng = state.RNG
+	buf.Read(m.memory[:m.memorySize()])
+
+	if m.screen != nil {
+		m.screen.Draw(m.Frame())
+	}
+	m.updateBuzzer()
+	// Only XO-CHIP programs have patterns. Giving one to any other
+	// machine's sound would have it play the pattern from then on rather
+	// than its beep.
+	if m.variant == VariantXOChip {
+		m.updatePattern()
+	}
+	return nil
+}
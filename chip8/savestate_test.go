@@ -0,0 +1,163 @@
+package chip8
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestSaveAndLoadState(t *testing.T) {
+	c := New(WithSeed(5))
+	_, err := c.LoadFile("../roms/PONG")
+	assert.NoError(t, err)
+	for i := 0; i < 20; i++ {
+		_, err := c.RunFrame(10)
+		assert.NoError(t, err)
+	}
+	var saved bytes.Buffer
+	assert.NoError(t, c.SaveState(&saved))
+
+	// Carry on from the save state in a second machine and the two should
+	// stay in step.
+	d := New()
+	assert.NoError(t, d.LoadState(bytes.NewReader(saved.Bytes())))
+	assert.Equal(t, c, d)
+	for i := 0; i < 20; i++ {
+		c.RunFrame(10)
+		d.RunFrame(10)
+	}
+	assert.Equal(t, c, d)
+}
+
+func TestSaveStateKeepsWaitForKey(t *testing.T) {
+	c := New()
+	copy(c.memory[0x200:], []byte{0xF2, 0x0A})
+	c.Step()
+	c.SetKey(0xB, true)
+	c.Step()
+	var saved bytes.Buffer
+	assert.NoError(t, c.SaveState(&saved))
+
+	d := New()
+	assert.NoError(t, d.LoadState(bytes.NewReader(saved.Bytes())))
+	assert.True(t, d.WaitingForKey())
+	d.SetKey(0xB, false)
+	d.Step()
+	assert.False(t, d.WaitingForKey())
+	assert.Equal(t, byte(0xB), d.V[0x2])
+}
+
+func TestLoadVersion1State(t *testing.T) {
+	c := New()
+	copy(c.memory[0x200:], []byte{0x60, 0x07, 0xF3, 0x0A})
+	old := savedStateV1{PC: 0x202, InputFlag: true, InputRegister: 0x3, DelayTimer: 9, Seed: 4}
+	old.V[0x0] = 0x07
+	old.Keys[0x5] = 0x01
+	var saved bytes.Buffer
+	assert.NoError(t, writeState(&saved, 1, &old, c.memory[:c.memorySize()]))
+
+	d := New()
+	assert.NoError(t, d.LoadState(bytes.NewReader(saved.Bytes())))
+	assert.Equal(t, uint16(0x202), d.PC())
+	assert.Equal(t, byte(0x07), d.V[0x0])
+	assert.Equal(t, byte(9), d.delayTimer)
+	assert.Equal(t, int64(4), d.seed)
+	assert.True(t, d.WaitingForKey(), "the FX0A pc was sent back to still waits")
+
+	// 5 was held when the state was saved, so letting it go doesn't end
+	// the wait, but pressing and letting go of another key does.
+	d.Step()
+	d.SetKey(0x5, false)
+	d.Step()
+	assert.True(t, d.WaitingForKey())
+	d.SetKey(0xA, true)
+	d.Step()
+	d.SetKey(0xA, false)
+	d.Step()
+	assert.False(t, d.WaitingForKey())
+	assert.Equal(t, byte(0xA), d.V[0x3])
+	assert.Equal(t, uint16(0x204), d.PC())
+
+	// Past the FX0A, the old frontends had found a key down and carried on.
+	old.PC = 0x204
+	saved.Reset()
+	assert.NoError(t, writeState(&saved, 1, &old, c.memory[:c.memorySize()]))
+	assert.NoError(t, d.LoadState(bytes.NewReader(saved.Bytes())))
+	assert.False(t, d.WaitingForKey())
+}
+
+func TestLoadStateRedraws(t *testing.T) {
+	c := New()
+	c.display[3][4] = 0x01
+	var saved bytes.Buffer
+	assert.NoError(t, c.SaveState(&saved))
+
+	display := &testDisplay{}
+	d := New(WithDisplay(display))
+	assert.NoError(t, d.LoadState(&saved))
+	if assert.Len(t, display.frames, 1) {
+		assert.Equal(t, byte(0x01), display.frames[0].At(4, 3))
+	}
+}
+
+func TestLoadStateOnlyGivesXOChipPatterns(t *testing.T) {
+	for _, variant := range []Variant{VariantChip8, VariantSChip, VariantXOChip} {
+		var saved bytes.Buffer
+		assert.NoError(t, New(WithVariant(variant)).SaveState(&saved))
+		sound := &testSound{}
+		m := New(WithVariant(variant), WithSound(sound))
+		assert.NoError(t, m.LoadState(&saved))
+		if variant == VariantXOChip {
+			assert.Equal(t, 1, sound.patterns, "%v", variant)
+		} else {
+			assert.Equal(t, 0, sound.patterns, "%v", variant)
+		}
+	}
+}
+
+func TestLoadStateRejectsBadStates(t *testing.T) {
+	c := New()
+	var saved bytes.Buffer
+	assert.NoError(t, c.SaveState(&saved))
+	good := saved.Bytes()
+
+	assert.Equal(t, ErrNotSaveState, c.LoadState(bytes.NewReader([]byte("PONG"))))
+
+	corrupt := append([]byte{}, good...)
+	corrupt[100] ^= 0xFF
+	assert.Error(t, c.LoadState(bytes.NewReader(corrupt)))
+
+	assert.Error(t, c.LoadState(bytes.NewReader(good[:len(good)-1])))
+
+	newer := append([]byte{}, good...)
+	newer[5] = stateVersion + 1
+	assert.Error(t, c.LoadState(bytes.NewReader(newer)))
+
+	xo := New(WithVariant(VariantXOChip))
+	assert.Error(t, xo.LoadState(bytes.NewReader(good)))
+}
+
+func TestLoadStateLeavesMachineAloneOnError(t *testing.T) {
+	c := New()
+	var saved bytes.Buffer
+	assert.NoError(t, c.SaveState(&saved))
+	corrupt := saved.Bytes()
+	corrupt[len(corrupt)-1] ^= 0xFF
+
+	d := New(WithSeed(3))
+	d.V[0x3] = 0x33
+	d.pc = 0x246
+	before := *d
+	assert.Error(t, d.LoadState(bytes.NewReader(corrupt)))
+	assert.Equal(t, before, *d)
+}
+
+func TestSaveStateKeepsXOChipMemory(t *testing.T) {
+	c := New(WithVariant(VariantXOChip))
+	c.memory[0xFFFF] = 0xAB
+	var saved bytes.Buffer
+	assert.NoError(t, c.SaveState(&saved))
+	d := New(WithVariant(VariantXOChip))
+	assert.NoError(t, d.LoadState(&saved))
+	assert.Equal(t, byte(0xAB), d.memory[0xFFFF])
+}
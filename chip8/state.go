@@ -0,0 +1,125 @@
+package chip8
+
+import (
+	"fmt"
+	"math"
+)
+
+// Variant is the kind of machine being emulated.
+func (m *Machine) Variant() Variant {
+	return m.variant
+}
+
+// Quirks are the quirks the machine was made with.
+func (m *Machine) Quirks() Quirks {
+	return m.quirks
+}
+
+// Seed is what the random number generator was seeded with, which can be
+// given to WithSeed to make the same random numbers again.
+func (m *Machine) Seed() int64 {
+	return m.seed
+}
+
+// PC is the address of the next instruction.
+func (m *Machine) PC() uint16 {
+	return m.pc
+}
+
+// SP is the number of return addresses on the stack.
+func (m *Machine) SP() int {
+	return int(m.sp)
+}
+
+// Stack returns the return addresses on the stack, oldest first.
+func (m *Machine) Stack() []uint16 {
+	stack := make([]uint16, m.sp)
+	copy(stack, m.stack[:m.sp])
+	return stack
+}
+
+// SetPC moves the program counter to pc, for debuggers. An FX0A waiting
+// for a key gives up, and starts again if it's executed again.
+func (m *Machine) SetPC(pc uint16) {
+	m.pc = pc
+	m.waiting = false
+}
+
+// SetSP changes the number of return addresses on the stack, for
+// debuggers. The stack has room for 16.
+func (m *Machine) SetSP(sp int) error {
+	if sp < 0 || sp > len(m.stack) {
+		return fmt.Errorf("stack pointer %d is outside 0 to %d", sp, len(m.stack))
+	}
+	m.sp = uint16(sp)
+	return nil
+}
+
+// SetTimers sets the delay and sound timers, for debuggers.
+func (m *Machine) SetTimers(delay, sound byte) {
+	m.delayTimer = delay
+	m.soundTimer = sound
+	m.updateBuzzer()
+}
+
+// WaitingForKey reports whether the machine is on an FX0A, waiting for a
+// key to be pressed and let go. Stepping it carries on waiting, and the
+// timers carry on counting down, until one has been.
+func (m *Machine) WaitingForKey() bool {
+	return m.waiting
+}
+
+// DelayTimer is the current value of the delay timer.
+func (m *Machine) DelayTimer() byte {
+	return m.delayTimer
+}
+
+// SoundTimer is the current value of the sound timer. The buzzer sounds for
+// as long as it's above zero.
+func (m *Machine) SoundTimer() byte {
+	return m.soundTimer
+}
+
+// Memory returns the memory the program can address. The slice is the
+// machine's own memory rather than a copy, so writes to it change what the
+// program sees.
+func (m *Machine) Memory() []byte {
+	return m.memory[:m.memorySize()]
+}
+
+// Width is the number of columns on the display in the current resolution.
+func (m *Machine) Width() int {
+	return m.screenWidth()
+}
+
+// Height is the number of rows on the display in the current resolution.
+func (m *Machine) Height() int {
+	return m.screenHeight()
+}
+
+// Pixel returns the pixel at column x and row y, with bit 0 set if it's lit
+// on the first plane and bit 1 set if it's lit on the second.
+func (m *Machine) Pixel(x, y int) byte {
+	return m.display[y][x]
+}
+
+// SetKey presses or releases key 0 to F on the keypad.
+func (m *Machine) SetKey(key byte, pressed bool) {
+	if pressed {
+		m.keys[key&0x0F] = 0x01
+	} else {
+		m.keys[key&0x0F] = 0x00
+	}
+}
+
+// Pattern is the XO-CHIP audio pattern buffer, 128 one bit samples played
+// in a loop while the buzzer sounds.
+func (m *Machine) Pattern() [16]byte {
+	return m.pattern
+}
+
+// PatternRate is how many bits of the audio pattern are played every
+// second at the current pitch.
+func (m *Machine) PatternRate() float64 {
+	return 4000 * math.Pow(2, (float64(m.pitch)-basePitch)/48)
+}
@@ -0,0 +1,297 @@
+package chip8
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Register names one of the registers a traced instruction can change. V0
+// to VF are 0 to 15.
+type Register byte
+
+// The registers besides V0 to VF.
+const (
+	RegisterI Register = 16 + iota
+	RegisterSP
+	RegisterDT
+	RegisterST
+)
+
+func (r Register) String() string {
+	switch {
+	case r < RegisterI:
+		return fmt.Sprintf("v%x", byte(r))
+	case r == RegisterI:
+		return "i"
+	case r == RegisterSP:
+		return "sp"
+	case r == RegisterDT:
+		return "dt"
+	case r == RegisterST:
+		return "st"
+	}
+	return fmt.Sprintf("Register(%d)", byte(r))
+}
+
+// Change is a register an instruction changed, with the value it had before
+// and after.
+type Change struct {
+	Register Register
+	Old, New uint16
+}
+
+func (c Change) String() string {
+	switch c.Register {
+	case RegisterI:
+		return fmt.Sprintf("%v %03X->%03X", c.Register, c.Old, c.New)
+	case RegisterSP:
+		return fmt.Sprintf("%v %d->%d", c.Register, c.Old, c.New)
+	}
+	return fmt.Sprintf("%v %02X->%02X", c.Register, c.Old, c.New)
+}
+
+// TraceEntry is an instruction the machine executed. The program counter
+// isn't among the changes, since every instruction moves it.
+type TraceEntry struct {
+	Instruction Instruction // decoded from pc before it was executed
+	Changes     []Change
+	Err         error // what Step returned, if the instruction failed
+}
+
+// Tracer is told about every instruction the machine executes.
+type Tracer interface {
+	// Trace is called by Step after each instruction, including ones that
+	// fail.
+	Trace(entry TraceEntry)
+}
+
+// WithTracer has the machine tell tracer about every instruction it
+// executes. Tracing slows the machine down, so it's off without it.
+func WithTracer(tracer Tracer) Option {
+	return func(m *Machine) {
+		m.tracer = tracer
+	}
+}
+
+// registers is the state of everything a TraceEntry can list as changed.
+type registers struct {
+	V          [16]byte
+	I          uint16
+	SP         uint16
+	DelayTimer byte
+	SoundTimer byte
+}
+
+func (m *Machine) registers() registers {
+	return registers{V: m.V, I: m.I, SP: m.sp, DelayTimer: m.delayTimer, SoundTimer: m.soundTimer}
+}
+
+// changes lists the registers that are different in after.
+func (before registers) changes(after registers) []Change {
+	var changes []Change
+	for i := range before.V {
+		if before.V[i] != after.V[i] {
+			changes = append(changes, Change{Register(i), uint16(before.V[i]), uint16(after.V[i])})
+		}
+	}
+	others := []Change{
+		{RegisterI, before.I, after.I},
+		{RegisterSP, before.SP, after.SP},
+		{RegisterDT, uint16(before.DelayTimer), uint16(after.DelayTimer)},
+		{RegisterST, uint16(before.SoundTimer), uint16(after.SoundTimer)},
+	}
+	for _, c := range others {
+		if c.Old != c.New {
+			changes = append(changes, c)
+		}
+	}
+	return changes
+}
+
+// TraceFormat is how a Trace writes instructions.
+type TraceFormat int
+
+const (
+	// TraceText writes a line for each instruction: its address, opcode
+	// and mnemonic and the registers it changed, such as
+	// "200: 6005       LD V0, 0x05          v0 00->05".
+	TraceText TraceFormat = iota
+	// TraceBinary writes a record for each instruction: its address as a
+	// big endian uint16, a byte whose low five bits are the number of
+	// changes, bit 6 set for a four byte instruction and bit 7 set if it
+	// failed, the instruction's two or four bytes and then each change as
+	// the register followed by its old and new values as big endian
+	// uint16s.
+	TraceBinary
+)
+
+// ParseTraceFormat turns "text" or "binary" into a TraceFormat.
+func ParseTraceFormat(s string) (TraceFormat, error) {
+	switch strings.ToLower(s) {
+	case "text":
+		return TraceText, nil
+	case "binary":
+		return TraceBinary, nil
+	}
+	return 0, fmt.Errorf("unknown trace format %q, want text or binary", s)
+}
+
+// TraceFilter picks the instructions a Trace writes. Instructions that fail
+// are written whatever the filter says.
+type TraceFilter struct {
+	// From and To are the first and last addresses of instructions to
+	// write. Both zero writes instructions at any address.
+	From, To uint16
+	// Classes has bit n set to write instructions whose opcode starts with
+	// the hex digit n, so 1<<0xD is every DXYN. Zero writes all of them.
+	Classes uint16
+}
+
+// Match reports whether the filter lets entry through.
+func (f TraceFilter) Match(entry TraceEntry) bool {
+	if entry.Err != nil {
+		return true
+	}
+	i := entry.Instruction
+	if (f.From != 0 || f.To != 0) && (i.Address < f.From || i.Address > f.To) {
+		return false
+	}
+	return f.Classes == 0 || f.Classes&(1<<(i.Opcode>>12)) != 0
+}
+
+// ParseTraceClasses reads a TraceFilter's Classes from a comma separated
+// list of the hex digits opcodes start with, such as "1,2,B" for jumps and
+// calls.
+func ParseTraceClasses(s string) (uint16, error) {
+	var classes uint16
+	for _, digit := range strings.Split(s, ",") {
+		digit = strings.TrimSpace(digit)
+		var n uint
+		if _, err := fmt.Sscanf(digit, "%X", &n); err != nil || len(digit) != 1 {
+			return 0, fmt.Errorf("bad opcode class %q, want a hex digit", digit)
+		}
+		classes |= 1 << n
+	}
+	return classes, nil
+}
+
+// Trace is a Tracer that writes the instructions its filter lets through,
+// either as they're executed or, when made with NewTraceRing, only the
+// last few once one fails.
+type Trace struct {
+	w      io.Writer
+	format TraceFormat
+	filter TraceFilter
+	ring   []TraceEntry // the last instructions, if only they're written
+	next   int          // where the next instruction goes in ring
+	count  int          // instructions in ring
+	err    error        // the first error writing to w
+}
+
+// NewTrace returns a Trace that writes every instruction filter lets
+// through to w in the given format.
+func NewTrace(w io.Writer, format TraceFormat, filter TraceFilter) *Trace {
+	return &Trace{w: w, format: format, filter: filter}
+}
+
+// NewTraceRing returns a Trace that holds on to the last size instructions
+// filter lets through and writes them to w only when one fails, or Dump is
+// called, to show what led up to a fault without tracing everything.
+func NewTraceRing(w io.Writer, format TraceFormat, filter TraceFilter, size int) *Trace {
+	return &Trace{w: w, format: format, filter: filter, ring: make([]TraceEntry, size)}
+}
+
+// Trace writes entry, or adds it to the ring, if the filter lets it
+// through.
+func (t *Trace) Trace(entry TraceEntry) {
+	if !t.filter.Match(entry) {
+		return
+	}
+	if t.ring == nil {
+		t.write(entry)
+		return
+	}
+	if len(t.ring) == 0 {
+		return
+	}
+	t.ring[t.next] = entry
+	t.next = (t.next + 1) % len(t.ring)
+	if t.count < len(t.ring) {
+		t.count++
+	}
+	if entry.Err != nil {
+		t.Dump()
+	}
+}
+
+// Dump writes the instructions in the ring, oldest first, and empties it.
+// It does nothing for a Trace that isn't keeping a ring.
+func (t *Trace) Dump() error {
+	for ; t.count > 0; t.count-- {
+		t.write(t.ring[(t.next+len(t.ring)-t.count)%len(t.ring)])
+	}
+	return t.err
+}
+
+// Err is the first error there's been writing the trace. Nothing more is
+// written after one.
+func (t *Trace) Err() error {
+	return t.err
+}
+
+func (t *Trace) write(entry TraceEntry) {
+	if t.err != nil {
+		return
+	}
+	if t.format == TraceBinary {
+		_, t.err = t.w.Write(entry.appendBinary(nil))
+	} else {
+		_, t.err = io.WriteString(t.w, entry.String()+"\n")
+	}
+}
+
+// String is the entry as TraceText writes it, without the newline.
+func (e TraceEntry) String() string {
+	i := e.Instruction
+	opcode := fmt.Sprintf("%04X", i.Opcode)
+	if i.Size == 4 {
+		opcode += fmt.Sprintf(" %04X", i.Target)
+	}
+	b := &strings.Builder{}
+	fmt.Fprintf(b, "%03X: %-10s %-20v", i.Address, opcode, i)
+	for _, c := range e.Changes {
+		fmt.Fprintf(b, " %v", c)
+	}
+	if e.Err != nil {
+		fmt.Fprintf(b, " failed: %v", e.Err)
+	}
+	return strings.TrimRight(b.String(), " ")
+}
+
+// appendBinary appends the entry as TraceBinary writes it to b.
+func (e TraceEntry) appendBinary(b []byte) []byte {
+	buf := bytes.NewBuffer(b)
+	i := e.Instruction
+	flags := byte(len(e.Changes))
+	if i.Size == 4 {
+		flags |= 0x40
+	}
+	if e.Err != nil {
+		flags |= 0x80
+	}
+	binary.Write(buf, binary.BigEndian, i.Address)
+	buf.WriteByte(flags)
+	binary.Write(buf, binary.BigEndian, i.Opcode)
+	if i.Size == 4 {
+		binary.Write(buf, binary.BigEndian, uint16(i.Target))
+	}
+	for _, c := range e.Changes {
+		buf.WriteByte(byte(c.Register))
+		binary.Write(buf, binary.BigEndian, c.Old)
+		binary.Write(buf, binary.BigEndian, c.New)
+	}
+	return buf.Bytes()
+}
@@ -0,0 +1,109 @@
+package chip8
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+type testTracer []TraceEntry
+
+func (t *testTracer) Trace(entry TraceEntry) {
+	*t = append(*t, entry)
+}
+
+func TestTracerSeesChangedRegisters(t *testing.T) {
+	tracer := &testTracer{}
+	c := New(WithTracer(tracer))
+	c.Load(bytes.NewReader([]byte{0x60, 0x05, 0xA3, 0x00, 0x22, 0x08, 0x00, 0x00, 0xF0, 0x15}))
+	for i := 0; i < 4; i++ {
+		assert.NoError(t, c.Step())
+	}
+	if assert.Len(t, *tracer, 4) {
+		assert.Equal(t, []Change{{Register(0), 0x00, 0x05}}, (*tracer)[0].Changes)
+		assert.Equal(t, []Change{{RegisterI, 0x000, 0x300}}, (*tracer)[1].Changes)
+		assert.Equal(t, []Change{{RegisterSP, 0, 1}}, (*tracer)[2].Changes)
+		assert.Equal(t, uint16(0x208), (*tracer)[3].Instruction.Address)
+		assert.Equal(t, "LD DT, V0", (*tracer)[3].Instruction.String())
+		assert.Equal(t, []Change{{RegisterDT, 0, 5}}, (*tracer)[3].Changes)
+	}
+}
+
+func TestTracerSeesFailures(t *testing.T) {
+	tracer := &testTracer{}
+	c := New(WithTracer(tracer))
+	c.Load(bytes.NewReader([]byte{0x00, 0xEE}))
+	err := c.Step()
+	assert.Error(t, err)
+	if assert.Len(t, *tracer, 1) {
+		assert.Equal(t, err, (*tracer)[0].Err)
+		assert.Empty(t, (*tracer)[0].Changes)
+	}
+}
+
+func TestTraceText(t *testing.T) {
+	var out bytes.Buffer
+	c := New(WithVariant(VariantXOChip), WithTracer(NewTrace(&out, TraceText, TraceFilter{})))
+	c.Load(bytes.NewReader([]byte{0x60, 0x05, 0xF0, 0x00, 0x03, 0x00}))
+	c.Step()
+	c.Step()
+	assert.Equal(t, "200: 6005       LD V0, 0x05          v0 00->05\n"+
+		"202: F000 0300  LD I, LONG 0x300     i 000->300\n", out.String())
+}
+
+func TestTraceBinary(t *testing.T) {
+	var out bytes.Buffer
+	c := New(WithVariant(VariantXOChip), WithTracer(NewTrace(&out, TraceBinary, TraceFilter{})))
+	c.Load(bytes.NewReader([]byte{0x60, 0x05, 0xF0, 0x00, 0x03, 0x00, 0x00, 0xEE}))
+	c.Step()
+	c.Step()
+	c.Step()
+	assert.Equal(t, []byte{
+		0x02, 0x00, 0x01, 0x60, 0x05, 0x00, 0x00, 0x00, 0x00, 0x05,
+		0x02, 0x02, 0x41, 0xF0, 0x00, 0x03, 0x00, 0x10, 0x00, 0x00, 0x03, 0x00,
+		0x02, 0x06, 0x80, 0x00, 0xEE,
+	}, out.Bytes())
+}
+
+func TestTraceFilter(t *testing.T) {
+	var out bytes.Buffer
+	filter := TraceFilter{From: 0x202, To: 0x206, Classes: 1<<0x6 | 1<<0x1}
+	c := New(WithTracer(NewTrace(&out, TraceText, filter)))
+	c.Load(bytes.NewReader([]byte{0x60, 0x01, 0x61, 0x02, 0x70, 0x01, 0x12, 0x08, 0x62, 0x03}))
+	for i := 0; i < 5; i++ {
+		c.Step()
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if assert.Len(t, lines, 2) {
+		assert.True(t, strings.HasPrefix(lines[0], "202: 6102"))
+		assert.True(t, strings.HasPrefix(lines[1], "206: 1208"))
+	}
+}
+
+func TestParseTraceClasses(t *testing.T) {
+	classes, err := ParseTraceClasses("1, 2,b")
+	assert.NoError(t, err)
+	assert.Equal(t, uint16(1<<0x1|1<<0x2|1<<0xB), classes)
+	_, err = ParseTraceClasses("10")
+	assert.Error(t, err)
+	_, err = ParseTraceClasses("G")
+	assert.Error(t, err)
+}
+
+func TestTraceRingDumpsOnFailure(t *testing.T) {
+	var out bytes.Buffer
+	c := New(WithTracer(NewTraceRing(&out, TraceText, TraceFilter{}, 2)))
+	c.Load(bytes.NewReader([]byte{0x60, 0x01, 0x61, 0x02, 0x62, 0x03, 0x00, 0xEE}))
+	for i := 0; i < 3; i++ {
+		c.Step()
+	}
+	assert.Empty(t, out.String())
+	assert.Error(t, c.Step())
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if assert.Len(t, lines, 2) {
+		assert.True(t, strings.HasPrefix(lines[0], "204: 6203"))
+		assert.True(t, strings.HasPrefix(lines[1], "206: 00EE"))
+		assert.Contains(t, lines[1], "failed: stack underflow")
+	}
+}
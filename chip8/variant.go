@@ -0,0 +1,63 @@
+package chip8
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Variant is the kind of machine being emulated. Each variant runs
+// everything the ones before it in the list do.
+type Variant int
+
+const (
+	// VariantChip8 is the original CHIP-8.
+	VariantChip8 Variant = iota
+	// VariantSChip is SUPER-CHIP 1.1, adding high resolution mode,
+	// scrolling, big sprites and a big font.
+	VariantSChip
+	// VariantXOChip is Octo's XO-CHIP, adding 64k of memory, a second
+	// display plane and programmable audio.
+	VariantXOChip
+)
+
+var variantNames = [...]string{
+	VariantChip8:  "CHIP-8",
+	VariantSChip:  "SUPER-CHIP",
+	VariantXOChip: "XO-CHIP",
+}
+
+func (v Variant) String() string {
+	if v >= 0 && int(v) < len(variantNames) {
+		return variantNames[v]
+	}
+	return "unknown variant"
+}
+
+// memorySize is how much memory programs can address on the variant.
+func (v Variant) memorySize() int {
+	if v == VariantXOChip {
+		return 0x10000
+	}
+	return 0x1000
+}
+
+// ParseVariant returns the variant called name, which is one of chip8,
+// schip or xochip. Case, dashes and underscores don't matter.
+func ParseVariant(name string) (Variant, error) {
+	switch normalizeName(name) {
+	case "chip8":
+		return VariantChip8, nil
+	case "schip", "superchip":
+		return VariantSChip, nil
+	case "xochip":
+		return VariantXOChip, nil
+	}
+	return 0, fmt.Errorf("unknown variant %q, want chip8, schip or xochip", name)
+}
+
+// normalizeName lower cases name and drops any dashes and underscores.
+func normalizeName(name string) string {
+	name = strings.ToLower(name)
+	name = strings.Replace(name, "-", "", -1)
+	return strings.Replace(name, "_", "", -1)
+}
@@ -0,0 +1,43 @@
+package chip8
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestParseVariant(t *testing.T) {
+	for name, want := range map[string]Variant{
+		"chip8":      VariantChip8,
+		"CHIP-8":     VariantChip8,
+		"schip":      VariantSChip,
+		"SUPER-CHIP": VariantSChip,
+		"xo_chip":    VariantXOChip,
+	} {
+		variant, err := ParseVariant(name)
+		assert.NoError(t, err, name)
+		assert.Equal(t, want, variant, name)
+	}
+	_, err := ParseVariant("chip9")
+	assert.Error(t, err)
+}
+
+func TestParseQuirks(t *testing.T) {
+	for name, want := range map[string]Quirks{
+		"vip":     QuirksCosmacVIP,
+		"CHIP-48": QuirksChip48,
+		"schip":   QuirksSChip11,
+		"xochip":  QuirksXOChip,
+		"modern":  QuirksModern,
+	} {
+		quirks, err := ParseQuirks(name)
+		assert.NoError(t, err, name)
+		assert.Equal(t, want, quirks, name)
+	}
+	_, err := ParseQuirks("weird")
+	assert.Error(t, err)
+}
+
+func TestVariantString(t *testing.T) {
+	assert.Equal(t, "XO-CHIP", VariantXOChip.String())
+	assert.Equal(t, "unknown variant", Variant(7).String())
+}
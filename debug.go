@@ -0,0 +1,416 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/h4ck3rk3y/go-8/chip8"
+	"github.com/h4ck3rk3y/go-8/chip8/debug"
+	"github.com/h4ck3rk3y/go-8/chip8/octo"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const debugHelp = `Addresses can be numbers or symbols. Commands:
+
+	step, s [n]            execute an instruction, or n, going into calls
+	next, n [n]            execute an instruction, or n, running calls through
+	finish                 run until the current subroutine has returned
+	return                 run until the current subroutine is about to return
+	continue, c            run until a breakpoint, watchpoint or ctrl-c
+	break, b addr [if c]   stop at addr, only when condition c holds if given
+	delete, d addr         remove the breakpoint at addr
+	watch addr [n]         stop after n bytes at addr, default 1, are written
+	rwatch addr [n]        stop after they're read
+	awatch addr [n]        stop after they're read or written
+	unwatch addr           remove the watchpoints at addr
+	info                   list breakpoints and watchpoints
+	regs, r                show the registers and timers
+	stack, bt              show the return addresses on the stack
+	x addr [n]             show n bytes of memory, default 16
+	list, l [addr] [n]     list n instructions, default 8, from addr or pc
+	screen                 show the display
+	key k [up]             press key k, 0 to F, or let go of it
+	set vX|i n             change a register
+	quit, q                stop debugging
+
+Conditions compare registers (v0 to vf, i, pc, sp, dt, st), memory ([i],
+[0x300]) and numbers with == != < <= > >=, joined by &&: v0 == 5 && i > 0x300.
+An empty line repeats the last command.
+`
+
+func debugCommand(args []string) error {
+	flags := flag.NewFlagSet("debug", flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: go-8 debug [flags] rom\n\n")
+		flags.PrintDefaults()
+	}
+	variant := flags.String("variant", "chip8", "the machine to emulate: chip8, schip or xochip")
+	quirks := flags.String("quirks", "", "quirks preset: vip, chip48, schip, xochip or modern (default is the variant's usual)")
+	ipf := flags.Int("ipf", 10, "instructions between ticks of the timers")
+	random := flags.String("random", "xorshift", "how random numbers are made: xorshift or vip")
+	seed := flags.Int64("seed", 0, "seed for the random numbers (default is one from the clock)")
+	sym := flags.String("sym", "", "symbol map naming addresses (default is the ROM with a .sym extension, if there is one)")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return errors.New("debug needs a ROM")
+	}
+	c := config{rom: flags.Arg(0), ipf: *ipf, seed: *seed}
+	flags.Visit(func(f *flag.Flag) {
+		c.seeded = c.seeded || f.Name == "seed"
+	})
+	var err error
+	if c.variant, err = chip8.ParseVariant(*variant); err != nil {
+		return err
+	}
+	if c.random, err = chip8.ParseRandomMode(*random); err != nil {
+		return err
+	}
+	c.quirks = chip8.DefaultQuirks(c.variant)
+	if *quirks != "" {
+		if c.quirks, err = chip8.ParseQuirks(*quirks); err != nil {
+			return err
+		}
+	}
+	if c.ipf < 1 {
+		return errors.New("--ipf has to be at least 1")
+	}
+
+	m := chip8.New(c.machineOptions()...)
+	if err := loadROM(m, c.rom); err != nil {
+		return err
+	}
+	s := &session{out: os.Stdout, symbols: debug.Symbols{}}
+	s.d = debug.New(m, c.ipf)
+	if filepath.Ext(c.rom) == ".8o" {
+		// Octo programs come with their labels and breakpoints.
+		program, err := octo.CompileFile(c.rom)
+		if err != nil {
+			return err
+		}
+		s.symbols = debug.Symbols(program.Symbols)
+		for _, address := range program.Breakpoints {
+			s.d.Break(uint16(address), "")
+		}
+	}
+	if *sym == "" {
+		*sym = strings.TrimSuffix(c.rom, filepath.Ext(c.rom)) + ".sym"
+		if _, err := os.Stat(*sym); err != nil {
+			*sym = ""
+		}
+	}
+	if *sym != "" {
+		if s.symbols, err = debug.ReadSymbolsFile(*sym); err != nil {
+			return err
+		}
+	}
+
+	// Ctrl-C stops the program rather than the debugger.
+	interrupts := make(chan os.Signal, 1)
+	signal.Notify(interrupts, os.Interrupt)
+	defer signal.Stop(interrupts)
+	go func() {
+		for range interrupts {
+			s.d.Interrupt()
+		}
+	}()
+	fmt.Fprintf(s.out, "debugging %s, type help for the commands\n", c.rom)
+	return s.repl(os.Stdin)
+}
+
+// session is the state of the debug command's prompt.
+type session struct {
+	d       *debug.Debugger
+	symbols debug.Symbols
+	out     io.Writer
+	last    string // the command an empty line repeats
+}
+
+// repl reads commands from in until it runs out or one of them is quit.
+func (s *session) repl(in io.Reader) error {
+	s.where()
+	scanner := bufio.NewScanner(in)
+	for {
+		fmt.Fprint(s.out, "(go-8) ")
+		if !scanner.Scan() {
+			fmt.Fprintln(s.out)
+			return scanner.Err()
+		}
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			line = s.last
+		}
+		s.last = line
+		quit, err := s.command(line)
+		if err != nil {
+			fmt.Fprintln(s.out, err)
+		}
+		if quit {
+			return nil
+		}
+	}
+}
+
+// command carries out one line typed at the prompt.
+func (s *session) command(line string) (bool, error) {
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return false, nil
+	}
+	name, args := fields[0], fields[1:]
+	m := s.d.Machine()
+	switch name {
+	case "help", "h", "?":
+		fmt.Fprint(s.out, debugHelp)
+	case "quit", "q":
+		return true, nil
+	case "step", "s", "next", "n":
+		n, err := s.count(args, 1)
+		if err != nil {
+			return false, err
+		}
+		for i := 0; i < n; i++ {
+			var stop debug.Stop
+			if name == "step" || name == "s" {
+				stop = s.d.Step()
+			} else {
+				stop = s.d.StepOver()
+			}
+			if stop.Reason != debug.Stepped || i == n-1 {
+				s.stopped(stop)
+				break
+			}
+		}
+	case "finish":
+		s.stopped(s.d.StepOut())
+	case "return":
+		s.stopped(s.d.RunToReturn())
+	case "continue", "c":
+		s.stopped(s.d.Continue())
+	case "break", "b":
+		if len(args) == 0 {
+			return false, errors.New("break needs an address")
+		}
+		address, err := s.symbols.Address(args[0])
+		if err != nil {
+			return false, err
+		}
+		condition := ""
+		if len(args) > 1 {
+			if args[1] != "if" || len(args) == 2 {
+				return false, errors.New("a breakpoint's condition goes after if")
+			}
+			condition = strings.Join(args[2:], " ")
+		}
+		b, err := s.d.Break(address, condition)
+		if err != nil {
+			return false, err
+		}
+		fmt.Fprintf(s.out, "breakpoint at %s\n", s.describe(b.Address, b.String()))
+	case "delete", "d":
+		address, err := s.address(args)
+		if err != nil {
+			return false, err
+		}
+		if !s.d.ClearBreak(address) {
+			return false, fmt.Errorf("there's no breakpoint at 0x%03X", address)
+		}
+	case "watch", "rwatch", "awatch":
+		address, err := s.address(args)
+		if err != nil {
+			return false, err
+		}
+		n, err := s.count(args[1:], 1)
+		if err != nil {
+			return false, err
+		}
+		kind := map[string]debug.WatchKind{"watch": debug.WatchWrite, "rwatch": debug.WatchRead, "awatch": debug.WatchAccess}[name]
+		fmt.Fprintf(s.out, "watchpoint on %v\n", s.d.Watch(address, n, kind))
+	case "unwatch":
+		address, err := s.address(args)
+		if err != nil {
+			return false, err
+		}
+		if !s.d.ClearWatch(address) {
+			return false, fmt.Errorf("there's no watchpoint at 0x%03X", address)
+		}
+	case "info":
+		for _, b := range s.d.Breakpoints() {
+			fmt.Fprintf(s.out, "breakpoint %s, hit %d times\n", s.describe(b.Address, b.String()), b.Hits)
+		}
+		for _, w := range s.d.Watchpoints() {
+			fmt.Fprintf(s.out, "watchpoint %v, hit %d times\n", w, w.Hits)
+		}
+	case "regs", "r":
+		for i, v := range m.V {
+			fmt.Fprintf(s.out, "v%X %02X", i, v)
+			if i%8 == 7 {
+				fmt.Fprintln(s.out)
+			} else {
+				fmt.Fprint(s.out, "  ")
+			}
+		}
+		fmt.Fprintf(s.out, "pc %03X  i %03X  sp %d  delay %d  sound %d\n", m.PC(), m.I, m.SP(), m.DelayTimer(), m.SoundTimer())
+	case "stack", "bt":
+		stack := m.Stack()
+		for i := len(stack) - 1; i >= 0; i-- {
+			fmt.Fprintf(s.out, "%2d  %s\n", i, s.describe(stack[i], fmt.Sprintf("0x%03X", stack[i])))
+		}
+	case "x":
+		address, err := s.address(args)
+		if err != nil {
+			return false, err
+		}
+		n, err := s.count(args[1:], 16)
+		if err != nil {
+			return false, err
+		}
+		memory := m.Memory()
+		end := int(address) + n
+		if end > len(memory) {
+			end = len(memory)
+		}
+		for at := int(address); at < end; at += 16 {
+			row := memory[at:end]
+			if len(row) > 16 {
+				row = row[:16]
+			}
+			fmt.Fprintf(s.out, "%04X  % X\n", at, row)
+		}
+	case "list", "l":
+		address := m.PC()
+		if len(args) > 0 {
+			var err error
+			if address, err = s.symbols.Address(args[0]); err != nil {
+				return false, err
+			}
+			args = args[1:]
+		}
+		n, err := s.count(args, 8)
+		if err != nil {
+			return false, err
+		}
+		memory := m.Memory()
+		for i := 0; i < n && int(address) < len(memory); i++ {
+			instruction := chip8.Decode(memory, address, m.Variant())
+			marker := "  "
+			if address == m.PC() {
+				marker = "=>"
+			}
+			fmt.Fprintf(s.out, "%s %s\n", marker, s.instruction(instruction))
+			if instruction.Size == 0 {
+				break
+			}
+			address += uint16(instruction.Size)
+		}
+	case "screen":
+		frame := m.Frame()
+		for y := 0; y < frame.Height; y++ {
+			row := make([]byte, frame.Width)
+			for x := range row {
+				row[x] = pixelChars[frame.At(x, y)]
+			}
+			fmt.Fprintln(s.out, string(row))
+		}
+	case "key":
+		if len(args) == 0 || len(args) > 2 || len(args) == 2 && args[1] != "up" {
+			return false, errors.New("usage: key k [up]")
+		}
+		key, err := strconv.ParseUint(args[0], 16, 4)
+		if err != nil {
+			return false, fmt.Errorf("bad key %q, want 0 to F", args[0])
+		}
+		m.SetKey(byte(key), len(args) == 1)
+	case "set":
+		if len(args) != 2 {
+			return false, errors.New("usage: set vX|i n")
+		}
+		n, err := strconv.ParseUint(args[1], 0, 16)
+		if err != nil {
+			return false, fmt.Errorf("bad number %q", args[1])
+		}
+		target := strings.ToLower(args[0])
+		if target == "i" {
+			m.I = uint16(n)
+			break
+		}
+		r, err := strconv.ParseUint(strings.TrimPrefix(target, "v"), 16, 4)
+		if err != nil || !strings.HasPrefix(target, "v") {
+			return false, fmt.Errorf("can't set %s, only v0 to vf and i", args[0])
+		}
+		if n > 0xFF {
+			return false, fmt.Errorf("%s doesn't fit in a register", args[1])
+		}
+		m.V[r] = byte(n)
+	default:
+		return false, fmt.Errorf("unknown command %q, type help for the commands", name)
+	}
+	return false, nil
+}
+
+// address reads the address at the start of args.
+func (s *session) address(args []string) (uint16, error) {
+	if len(args) == 0 {
+		return 0, errors.New("missing an address")
+	}
+	return s.symbols.Address(args[0])
+}
+
+// count reads the count at the start of args, or returns def if there
+// isn't one.
+func (s *session) count(args []string, def int) (int, error) {
+	if len(args) == 0 {
+		return def, nil
+	}
+	n, err := strconv.Atoi(args[0])
+	if err != nil || n < 1 {
+		return 0, fmt.Errorf("bad count %q", args[0])
+	}
+	return n, nil
+}
+
+// describe is text about address with the symbol it's at, if there is
+// one, after it.
+func (s *session) describe(address uint16, text string) string {
+	if name := s.symbols.Name(address); name != "" {
+		return fmt.Sprintf("%s <%s>", text, name)
+	}
+	return text
+}
+
+// instruction is a line of listing for i.
+func (s *session) instruction(i chip8.Instruction) string {
+	text := fmt.Sprintf("%03X: %04X  %s", i.Address, i.Opcode, i)
+	if name, ok := s.labelAt(i.Address); ok {
+		text = fmt.Sprintf("%s  <%s>", text, name)
+	}
+	return text
+}
+
+// labelAt is the symbol exactly at address.
+func (s *session) labelAt(address uint16) (string, bool) {
+	name := s.symbols.Name(address)
+	at, ok := s.symbols[name]
+	return name, ok && at == int(address)
+}
+
+// stopped reports why the machine stopped and where it is now.
+func (s *session) stopped(stop debug.Stop) {
+	if stop.Reason != debug.Stepped {
+		fmt.Fprintln(s.out, stop)
+	}
+	s.where()
+}
+
+// where shows the instruction at pc.
+func (s *session) where() {
+	m := s.d.Machine()
+	fmt.Fprintf(s.out, "=> %s\n", s.instruction(chip8.Decode(m.Memory(), m.PC(), m.Variant())))
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"bytes"
+	"github.com/h4ck3rk3y/go-8/chip8"
+	"github.com/h4ck3rk3y/go-8/chip8/debug"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func TestDebugSession(t *testing.T) {
+	m := chip8.New()
+	copy(m.Memory()[0x200:], []byte{
+		0x70, 0x01, // ADD V0, 1
+		0x22, 0x06, // CALL 206
+		0x12, 0x00, // JP 200
+		0xA3, 0x00, // LD I, 0x300
+		0xF0, 0x55, // LD [I], V0
+		0x00, 0xEE, // RET
+	})
+	out := &bytes.Buffer{}
+	s := &session{d: debug.New(m, 10), symbols: debug.Symbols{"store": 0x206}, out: out}
+	err := s.repl(strings.NewReader("break store if v0 == 2\nc\nbt\nd store\nwatch 0x300\nc\n\nx 0x300 1\nbogus\nq\n"))
+	assert.NoError(t, err)
+	assert.Equal(t, byte(3), m.V[0])
+	text := out.String()
+	assert.Contains(t, text, "breakpoint at 0x206 if v0 == 2 <store>")
+	assert.Contains(t, text, "=> 206: A300  LD I, 0x300  <store>")
+	assert.Contains(t, text, " 0  0x204\n")
+	assert.Contains(t, text, "write of 0x300 hit watchpoint on 0x300 (write)")
+	assert.Contains(t, text, "0300  03\n")
+	assert.Contains(t, text, `unknown command "bogus"`)
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/h4ck3rk3y/go-8/chip8"
+	"io"
+	"io/ioutil"
+	"os"
+	"strconv"
+)
+
+func disasmCommand(args []string) error {
+	flags := flag.NewFlagSet("disasm", flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: go-8 disasm [flags] rom\n\n")
+		flags.PrintDefaults()
+	}
+	variant := flags.String("variant", "xochip", "the machine the ROM is for: chip8, schip or xochip")
+	origin := flags.String("origin", "0x200", "the address the ROM is loaded at")
+	out := flags.String("out", "", "file to write the listing to instead of stdout")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return errors.New("disasm needs a ROM")
+	}
+	v, err := chip8.ParseVariant(*variant)
+	if err != nil {
+		return err
+	}
+	at, err := strconv.ParseUint(*origin, 0, 16)
+	if err != nil {
+		return fmt.Errorf("bad --origin %q", *origin)
+	}
+	program, err := ioutil.ReadFile(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	var w io.Writer = os.Stdout
+	if *out != "" {
+		f, err := os.Create(*out)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+	return chip8.Disassemble(w, program, uint16(at), v)
+}
@@ -0,0 +1,82 @@
+//go:build !headless
+// +build !headless
+
+package main
+
+import (
+	"github.com/hajimehoshi/ebiten"
+	"log"
+)
+
+var (
+	// gamepads are the IDs of the gamepads plugged in, kept to notice them
+	// being plugged in and pulled out.
+	gamepads []int
+	// padMap is keyMap for gamepads, the hex keypad key each gamepad input
+	// presses.
+	padMap map[padInput]byte
+)
+
+func hasID(ids []int, id int) bool {
+	for _, i := range ids {
+		if i == id {
+			return true
+		}
+	}
+	return false
+}
+
+// updateGamepads notices gamepads being plugged in and pulled out, which
+// can happen at any time while playing.
+func updateGamepads() {
+	ids := ebiten.GamepadIDs()
+	for _, id := range ids {
+		if !hasID(gamepads, id) {
+			log.Printf("gamepad %d plugged in, with %d buttons and %d axes",
+				id, ebiten.GamepadButtonNum(id), ebiten.GamepadAxisNum(id))
+		}
+	}
+	for _, id := range gamepads {
+		if !hasID(ids, id) {
+			log.Printf("gamepad %d pulled out", id)
+		}
+	}
+	gamepads = ids
+}
+
+// padPressed reports whether input is pressed on any of the gamepads.
+func padPressed(input padInput) bool {
+	for _, id := range gamepads {
+		if input.button >= 0 {
+			if input.button < ebiten.GamepadButtonNum(id) && ebiten.IsGamepadButtonPressed(id, ebiten.GamepadButton(input.button)) {
+				return true
+			}
+		} else if input.axis < ebiten.GamepadAxisNum(id) && input.pressed(ebiten.GamepadAxis(id, input.axis)) {
+			return true
+		}
+	}
+	return false
+}
+
+// padInputsPressed names every input pressed on any of the gamepads, for
+// the remap screen to pick up.
+func padInputsPressed() map[string]bool {
+	pressed := map[string]bool{}
+	for _, id := range gamepads {
+		for button := 0; button < ebiten.GamepadButtonNum(id) && button < 32; button++ {
+			input := padInput{button: button}
+			if padPressed(input) {
+				pressed[input.String()] = true
+			}
+		}
+		for axis := 0; axis < ebiten.GamepadAxisNum(id) && axis < 16; axis++ {
+			for _, sign := range []float64{-1, 1} {
+				input := padInput{button: -1, axis: axis, sign: sign}
+				if padPressed(input) {
+					pressed[input.String()] = true
+				}
+			}
+		}
+	}
+	return pressed
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"github.com/h4ck3rk3y/go-8/chip8"
+	"github.com/h4ck3rk3y/go-8/chip8/debug"
+	"github.com/h4ck3rk3y/go-8/chip8/gdbstub"
+	"log"
+	"net"
+	"time"
+)
+
+// serveGDB listens for gdb on address and returns the stub that m has to
+// be run through for gdb to debug it, along with the debugger under it.
+func serveGDB(m *chip8.Machine, address string, ipf int) (*gdbstub.Server, *debug.Debugger, error) {
+	l, err := net.Listen("tcp", address)
+	if err != nil {
+		return nil, nil, err
+	}
+	d := debug.New(m, ipf)
+	server := gdbstub.New(d)
+	go func() {
+		if err := server.Serve(l); err != nil {
+			log.Printf("gdb stub stopped: %v", err)
+		}
+	}()
+	log.Printf("gdb can attach on %s", l.Addr())
+	return server, d, nil
+}
+
+// runHeadlessGDB runs frames through server in real time until a gdb
+// client has attached and gone again, or the program stops when no client
+// is attached. It returns how many instructions were executed and the
+// error that stopped the program, if one did.
+func runHeadlessGDB(server *gdbstub.Server, d *debug.Debugger, ipf int) (int, error) {
+	ticker := time.NewTicker(time.Second / 60)
+	defer ticker.Stop()
+	attached := false
+	for range ticker.C {
+		if err := server.RunFrame(ipf); err != nil {
+			return d.Cycles(), err
+		}
+		if server.Attached() {
+			attached = true
+		} else if attached {
+			break
+		}
+	}
+	return d.Cycles(), nil
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"bufio"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/h4ck3rk3y/go-8/chip8"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// keyEvent says which keys are held down from a cycle onwards.
+type keyEvent struct {
+	cycle int
+	keys  uint16 // bit n set if key n is down
+}
+
+// parseScript reads scripted key presses for a headless run. Each line is
+// a cycle number followed by the keys, as hex digits, held down from that
+// cycle until the next line. A cycle on its own lets go of every key.
+// Blank lines and lines starting with # are skipped.
+//
+//	# press 5 for a second's worth of cycles
+//	120 5
+//	720
+func parseScript(r io.Reader) ([]keyEvent, error) {
+	var script []keyEvent
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		if len(fields) > 2 {
+			return nil, fmt.Errorf("line %d: want a cycle and the keys held down", line)
+		}
+		cycle, err := strconv.Atoi(fields[0])
+		if err != nil || cycle < 0 {
+			return nil, fmt.Errorf("line %d: bad cycle %q", line, fields[0])
+		}
+		if len(script) > 0 && cycle < script[len(script)-1].cycle {
+			return nil, fmt.Errorf("line %d: cycle %d comes before the line above", line, cycle)
+		}
+		event := keyEvent{cycle: cycle}
+		if len(fields) == 2 {
+			for _, digit := range fields[1] {
+				key, err := strconv.ParseUint(string(digit), 16, 8)
+				if err != nil {
+					return nil, fmt.Errorf("line %d: bad key %q", line, digit)
+				}
+				event.keys |= 1 << key
+			}
+		}
+		script = append(script, event)
+	}
+	return script, scanner.Err()
+}
+
+// runHeadless executes up to cycles instructions on m, pressing keys as the
+// script says and ticking the timers after every ipf instructions, as a
+// window would every frame. It returns how many instructions were executed
+// and the error that stopped the machine early, if one did.
+func runHeadless(m *chip8.Machine, cycles, ipf int, script []keyEvent) (int, error) {
+	for cycle := 0; cycle < cycles; cycle++ {
+		for len(script) > 0 && script[0].cycle <= cycle {
+			for key := byte(0); key < 16; key++ {
+				m.SetKey(key, script[0].keys&(1<<key) != 0)
+			}
+			script = script[1:]
+		}
+		if err := m.Step(); err != nil {
+			return cycle, err
+		}
+		if (cycle+1)%ipf == 0 {
+			m.TickTimers()
+		}
+	}
+	return cycles, nil
+}
+
+// isExit reports whether err is the program stopping itself rather than
+// something going wrong.
+func isExit(err error) bool {
+	return err == chip8.ErrExit
+}
+
+// pixelChars are how pixels on neither plane, the first, the second and
+// both show up in a dump.
+const pixelChars = ".#+@"
+
+// dump is the state of a machine at the end of a headless run.
+type dump struct {
+	Cycles     int      `json:"cycles"`
+	Stopped    string   `json:"stopped,omitempty"`
+	Seed       int64    `json:"seed"`
+	PC         uint16   `json:"pc"`
+	I          uint16   `json:"i"`
+	V          [16]byte `json:"v"`
+	Stack      []uint16 `json:"stack"`
+	DelayTimer byte     `json:"delay_timer"`
+	SoundTimer byte     `json:"sound_timer"`
+	Display    []string `json:"display"`
+	Memory     string   `json:"memory"`
+	memory     []byte
+}
+
+func newDump(m *chip8.Machine, cycles int, stopped error) dump {
+	d := dump{
+		Cycles:     cycles,
+		Seed:       m.Seed(),
+		PC:         m.PC(),
+		I:          m.I,
+		V:          m.V,
+		Stack:      m.Stack(),
+		DelayTimer: m.DelayTimer(),
+		SoundTimer: m.SoundTimer(),
+		memory:     m.Memory(),
+	}
+	if stopped != nil {
+		d.Stopped = stopped.Error()
+	}
+	d.Memory = hex.EncodeToString(d.memory)
+	frame := m.Frame()
+	for y := 0; y < frame.Height; y++ {
+		row := make([]byte, frame.Width)
+		for x := range row {
+			row[x] = pixelChars[frame.At(x, y)]
+		}
+		d.Display = append(d.Display, string(row))
+	}
+	return d
+}
+
+func (d dump) writeJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(d)
+}
+
+func (d dump) writeText(w io.Writer) error {
+	b := &strings.Builder{}
+	fmt.Fprintf(b, "cycles %d  seed %d\n", d.Cycles, d.Seed)
+	if d.Stopped != "" {
+		fmt.Fprintf(b, "stopped %s\n", d.Stopped)
+	}
+	fmt.Fprintf(b, "pc %03X  i %03X  delay %d  sound %d\n", d.PC, d.I, d.DelayTimer, d.SoundTimer)
+	for i, v := range d.V {
+		fmt.Fprintf(b, "v%X %02X", i, v)
+		if i%8 == 7 {
+			b.WriteString("\n")
+		} else {
+			b.WriteString("  ")
+		}
+	}
+	fmt.Fprintf(b, "stack %03X\n\n", d.Stack)
+	for _, row := range d.Display {
+		b.WriteString(row)
+		b.WriteString("\n")
+	}
+	b.WriteString("\n")
+	for addr := 0; addr < len(d.memory); addr += 16 {
+		fmt.Fprintf(b, "%04X  % X\n", addr, d.memory[addr:addr+16])
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
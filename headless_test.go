@@ -0,0 +1,85 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/h4ck3rk3y/go-8/chip8"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func TestParseScript(t *testing.T) {
+	script, err := parseScript(strings.NewReader("# comment\n\n10 5\n20 1f\n30\n"))
+	assert.NoError(t, err)
+	assert.Equal(t, []keyEvent{{10, 1 << 5}, {20, 1<<1 | 1<<0xF}, {30, 0}}, script)
+}
+
+func TestParseScriptErrors(t *testing.T) {
+	for _, script := range []string{"x 1", "10 G", "10 1 2", "20\n10"} {
+		_, err := parseScript(strings.NewReader(script))
+		assert.Error(t, err, script)
+	}
+}
+
+func TestRunHeadlessPressesKeys(t *testing.T) {
+	m := chip8.New()
+	// Loop forever skipping over a jump back to 0x200 while key 5 is down.
+	copy(m.Memory()[0x200:], []byte{0x60, 0x05, 0xE0, 0x9E, 0x12, 0x00, 0x12, 0x06})
+	ran, err := runHeadless(m, 10, 10, []keyEvent{{4, 1 << 5}})
+	assert.NoError(t, err)
+	assert.Equal(t, 10, ran)
+	assert.Equal(t, uint16(0x206), m.PC())
+}
+
+func TestRunHeadlessWaitsForKey(t *testing.T) {
+	m := chip8.New()
+	copy(m.Memory()[0x200:], []byte{0xF0, 0x0A, 0x12, 0x02})
+	_, err := runHeadless(m, 6, 10, []keyEvent{{2, 1 << 3}})
+	assert.NoError(t, err)
+	assert.Equal(t, uint16(0x200), m.PC(), "still waiting while the key is held")
+	m = chip8.New()
+	copy(m.Memory()[0x200:], []byte{0xF0, 0x0A, 0x12, 0x02})
+	_, err = runHeadless(m, 10, 10, []keyEvent{{2, 1 << 3}, {4, 0}})
+	assert.NoError(t, err)
+	assert.Equal(t, uint16(0x202), m.PC())
+	assert.Equal(t, byte(3), m.V[0])
+}
+
+func TestRunHeadlessStopsOnError(t *testing.T) {
+	m := chip8.New()
+	copy(m.Memory()[0x200:], []byte{0x00, 0xEE})
+	ran, err := runHeadless(m, 10, 10, nil)
+	assert.IsType(t, &chip8.Fault{}, err)
+	assert.Equal(t, 0, ran)
+}
+
+func TestDumpPong(t *testing.T) {
+	m := chip8.New()
+	_, err := m.LoadFile("roms/PONG")
+	assert.NoError(t, err)
+	ran, err := runHeadless(m, 3000, 10, nil)
+	assert.NoError(t, err)
+
+	var b bytes.Buffer
+	assert.NoError(t, newDump(m, ran, err).writeJSON(&b))
+	var d dump
+	assert.NoError(t, json.Unmarshal(b.Bytes(), &d))
+	assert.Equal(t, 3000, d.Cycles)
+	assert.Len(t, d.Display, 32)
+	assert.Equal(t, "..#", d.Display[12][:3])
+	assert.Len(t, d.Memory, 2*0x1000)
+
+	b.Reset()
+	assert.NoError(t, newDump(m, ran, err).writeText(&b))
+	assert.Contains(t, b.String(), "cycles 3000  seed ")
+}
+
+func TestRunHeadlessTicksTimersEveryFrame(t *testing.T) {
+	m := chip8.New()
+	// Set the delay timer to 100 and then spin in place.
+	copy(m.Memory()[0x200:], []byte{0x60, 0x64, 0xF0, 0x15, 0x12, 0x04})
+	_, err := runHeadless(m, 50, 10, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, byte(95), m.DelayTimer())
+}
@@ -0,0 +1,321 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// keypadLayout puts the hex keypad on the left of a QWERTY keyboard, laid
+// out the same way:
+//
+//	1 2 3 C      1 2 3 4
+//	4 5 6 D      Q W E R
+//	7 8 9 E      A S D F
+//	A 0 B F      Z X C V
+var keypadLayout = map[rune]byte{
+	'1': 0x1, '2': 0x2, '3': 0x3, '4': 0xC,
+	'q': 0x4, 'w': 0x5, 'e': 0x6, 'r': 0xD,
+	'a': 0x7, 's': 0x8, 'd': 0x9, 'f': 0xE,
+	'z': 0xA, 'x': 0x0, 'c': 0xB, 'v': 0xF,
+}
+
+// padLayout is the gamepad inputs in the default keymap: the left stick
+// for 2, 4, 6 and 8, which most games move with, and the first button for
+// 5, which is often fire.
+var padLayout = []struct {
+	name string
+	key  byte
+}{{"axis1-", 0x2}, {"axis0-", 0x4}, {"axis0+", 0x6}, {"axis1+", 0x8}, {"button0", 0x5}}
+
+// keypadOrder is the hex keypad's keys row by row, the order they're
+// asked for when remapping.
+var keypadOrder = [16]byte{0x1, 0x2, 0x3, 0xC, 0x4, 0x5, 0x6, 0xD, 0x7, 0x8, 0x9, 0xE, 0xA, 0x0, 0xB, 0xF}
+
+// keymap is the keys on the keyboard and gamepad inputs, by name, that
+// press each key on the hex keypad. A key can have any number of them, or
+// none. Keyboard keys are named in lower case: letters and digits by
+// themselves and others with words such as "up", "space" or "kp5". Gamepad
+// inputs are named as padInput says.
+type keymap [16][]string
+
+// defaultKeymap is keypadLayout and padLayout as a keymap.
+func defaultKeymap() keymap {
+	var km keymap
+	for char, key := range keypadLayout {
+		km[key] = []string{string(char)}
+	}
+	for _, pad := range padLayout {
+		km[pad.key] = append(km[pad.key], pad.name)
+	}
+	return km
+}
+
+// padInput is a gamepad button, named "button0" to "button31", or one
+// direction of an analog stick's axis, named "axis0-" to "axis15+" where
+// - is up or left and + is down or right.
+type padInput struct {
+	button int // -1 for an axis
+	axis   int
+	sign   float64 // -1 or 1, which way the axis has to be pushed
+}
+
+// parsePadInput reads the name of a gamepad input, reporting whether it
+// is one.
+func parsePadInput(name string) (padInput, bool) {
+	if strings.HasPrefix(name, "button") {
+		n, err := strconv.Atoi(strings.TrimPrefix(name, "button"))
+		return padInput{button: n}, err == nil && n >= 0 && n < 32 && name == fmt.Sprintf("button%d", n)
+	}
+	if !strings.HasPrefix(name, "axis") || len(name) < 6 {
+		return padInput{}, false
+	}
+	sign := 1.0
+	switch name[len(name)-1] {
+	case '-':
+		sign = -1
+	case '+':
+	default:
+		return padInput{}, false
+	}
+	digits := name[len("axis") : len(name)-1]
+	n, err := strconv.Atoi(digits)
+	return padInput{button: -1, axis: n, sign: sign}, err == nil && n >= 0 && n < 16 && digits == strconv.Itoa(n)
+}
+
+// pressed reports whether the input is pressed, given how far the axis is
+// pushed, from -1 to 1, if it's an axis. An axis has to be pushed over half
+// way, so that a stick resting a little off centre doesn't press anything.
+func (p padInput) pressed(value float64) bool {
+	return value*p.sign > 0.5
+}
+
+func (p padInput) String() string {
+	if p.button >= 0 {
+		return fmt.Sprintf("button%d", p.button)
+	}
+	if p.sign < 0 {
+		return fmt.Sprintf("axis%d-", p.axis)
+	}
+	return fmt.Sprintf("axis%d+", p.axis)
+}
+
+// keymapFile is a keymap config file. Keys changes the keymap for every
+// ROM and ROMs changes it further for the ROMs named, by file name. Both
+// map a hex digit to the keyboard keys for it, and only need the keypad
+// keys that are different:
+//
+//	{
+//	  "keys": {"5": ["w", "up"]},
+//	  "roms": {"PONG": {"1": ["w"], "4": ["s"]}}
+//	}
+type keymapFile struct {
+	Keys map[string][]string            `json:"keys,omitempty"`
+	ROMs map[string]map[string][]string `json:"roms,omitempty"`
+}
+
+// defaultKeymapPath is where the keymap config is kept unless --keymap
+// says otherwise.
+func defaultKeymapPath() string {
+	return filepath.Join(os.Getenv("HOME"), ".go-8", "keys.json")
+}
+
+// loadKeymapFile reads the keymap config at path. A file that isn't there
+// is the same as an empty one.
+func loadKeymapFile(path string) (*keymapFile, error) {
+	f := &keymapFile{}
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return f, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, f); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	if _, err := f.keymap(""); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	for rom := range f.ROMs {
+		if _, err := f.keymap(rom); err != nil {
+			return nil, fmt.Errorf("%s: %s: %v", path, rom, err)
+		}
+	}
+	return f, nil
+}
+
+// save writes the config to path, making the directory it goes in if need
+// be.
+func (f *keymapFile) save(path string) error {
+	data, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, append(data, '\n'), 0644)
+}
+
+// keymap is the keymap for rom: the default with the config's changes for
+// every ROM and then rom's own on top.
+func (f *keymapFile) keymap(rom string) (keymap, error) {
+	km := defaultKeymap()
+	if err := km.apply(f.Keys); err != nil {
+		return km, err
+	}
+	err := km.apply(f.ROMs[filepath.Base(rom)])
+	return km, err
+}
+
+// setKeymap makes km the keymap for rom, keeping only the keys that are
+// different from the keymap for every ROM.
+func (f *keymapFile) setKeymap(rom string, km keymap) {
+	base, _ := f.keymap("")
+	changes := map[string][]string{}
+	for key := range km {
+		if !sameKeys(km[key], base[key]) {
+			changes[fmt.Sprintf("%X", key)] = append([]string{}, km[key]...)
+		}
+	}
+	rom = filepath.Base(rom)
+	if len(changes) == 0 {
+		delete(f.ROMs, rom)
+		return
+	}
+	if f.ROMs == nil {
+		f.ROMs = map[string]map[string][]string{}
+	}
+	f.ROMs[rom] = changes
+}
+
+// sameKeys reports whether a and b name the same keyboard keys in the same
+// order.
+func sameKeys(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// apply replaces the keyboard keys for the hex digits in changes, taking
+// them away from any other keys they were pressing, the way assign does.
+func (km *keymap) apply(changes map[string][]string) error {
+	given := map[byte][]string{}
+	var all []string
+	for digit, names := range changes {
+		key, err := strconv.ParseUint(digit, 16, 8)
+		if err != nil || key > 0xF {
+			return fmt.Errorf("%q isn't a key on the hex keypad, want 0 to F", digit)
+		}
+		given[byte(key)] = []string{}
+		for _, name := range names {
+			given[byte(key)] = append(given[byte(key)], strings.ToLower(name))
+			all = append(all, strings.ToLower(name))
+		}
+	}
+	// Every change is made at once, so a key given to two hex digits in
+	// the same changes stays on both for check to complain about.
+	for other := range km {
+		kept := []string{}
+		for _, name := range km[other] {
+			if !contains(all, name) {
+				kept = append(kept, name)
+			}
+		}
+		km[other] = kept
+	}
+	for key, names := range given {
+		km[key] = names
+	}
+	return nil
+}
+
+// assign makes names the keyboard keys for key, taking them away from
+// any other keys they were pressing.
+func (km *keymap) assign(key byte, names []string) {
+	for other := range km {
+		kept := []string{}
+		for _, name := range km[other] {
+			if !contains(names, name) {
+				kept = append(kept, name)
+			}
+		}
+		km[other] = kept
+	}
+	km[key] = append([]string{}, names...)
+}
+
+func contains(names []string, name string) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+// keys returns the hex keypad key each keyboard key named in the keymap
+// presses.
+func (km keymap) keys() map[string]byte {
+	keys := map[string]byte{}
+	for key, names := range km {
+		for _, name := range names {
+			keys[name] = byte(key)
+		}
+	}
+	return keys
+}
+
+// check returns an error if the keymap has a keyboard key that known
+// doesn't, or one that presses two keys on the hex keypad.
+func (km keymap) check(known func(name string) bool) error {
+	used := map[string]byte{}
+	for _, key := range keypadOrder {
+		for _, name := range km[key] {
+			if !known(name) {
+				return fmt.Errorf("unknown key %q in the keymap", name)
+			}
+			if other, ok := used[name]; ok && other != key {
+				return fmt.Errorf("%q is in the keymap for both %X and %X", name, other, key)
+			}
+			used[name] = key
+		}
+	}
+	return nil
+}
+
+// remapText is what the remap screen shows while it asks for the keyboard
+// keys for keypadOrder[next]: the keymap so far laid out like the keypad,
+// and the keys pressed for the one being asked for.
+func remapText(rom string, km keymap, next int, pressed []string) string {
+	b := &strings.Builder{}
+	fmt.Fprintf(b, "Remapping the keys for %s\n\n", filepath.Base(rom))
+	row := ""
+	for i, key := range keypadOrder {
+		names := strings.Join(km[key], ",")
+		if i == next {
+			names = strings.Join(pressed, ",") + "_"
+		}
+		row += fmt.Sprintf("%X %-12s", key, names)
+		if i%4 == 3 {
+			b.WriteString(strings.TrimRight(row, " ") + "\n")
+			row = ""
+		}
+	}
+	fmt.Fprintf(b, "\nPress the keys for %X, then Enter.\n", keypadOrder[next])
+	b.WriteString("Enter on its own keeps the keys it has.\n")
+	b.WriteString("Backspace takes back the last key pressed.\n")
+	b.WriteString("Escape leaves without saving.\n")
+	return b.String()
+}
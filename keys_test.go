@@ -0,0 +1,180 @@
+package main
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDefaultKeymap(t *testing.T) {
+	km := defaultKeymap()
+	assert.Equal(t, []string{"x"}, km[0x0])
+	assert.Equal(t, []string{"2", "axis1-"}, km[0x2])
+	assert.Equal(t, []string{"w", "button0"}, km[0x5])
+	assert.Equal(t, []string{"4"}, km[0xC])
+	assert.Equal(t, []string{"v"}, km[0xF])
+	assert.Len(t, km.keys(), 21)
+}
+
+func TestParsePadInput(t *testing.T) {
+	for name, want := range map[string]padInput{
+		"button0":  {button: 0},
+		"button31": {button: 31},
+		"axis0-":   {button: -1, axis: 0, sign: -1},
+		"axis15+":  {button: -1, axis: 15, sign: 1},
+	} {
+		input, ok := parsePadInput(name)
+		if assert.True(t, ok, name) {
+			assert.Equal(t, want, input)
+			assert.Equal(t, name, input.String())
+		}
+	}
+	for _, name := range []string{"button", "button32", "button-1", "button01", "axis0", "axis16+", "axis+", "axis01-", "a"} {
+		_, ok := parsePadInput(name)
+		assert.False(t, ok, name)
+	}
+	up, _ := parsePadInput("axis1-")
+	assert.True(t, up.pressed(-0.9))
+	assert.False(t, up.pressed(-0.2))
+	assert.False(t, up.pressed(0.9))
+}
+
+func TestKeymapFileOverridesForROMs(t *testing.T) {
+	dir, err := ioutil.TempDir("", "keymap")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "keys.json")
+	config := `{
+		"keys": {"5": ["W", "up"]},
+		"roms": {"PONG": {"1": ["w"], "4": ["s", "down"]}}
+	}`
+	assert.NoError(t, ioutil.WriteFile(path, []byte(config), 0644))
+	f, err := loadKeymapFile(path)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	km, err := f.keymap("roms/TETRIS")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"w", "up"}, km[0x5])
+	assert.Equal(t, []string{"1"}, km[0x1])
+
+	km, err = f.keymap("roms/PONG")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"w"}, km[0x1])
+	assert.Equal(t, []string{"s", "down"}, km[0x4])
+	assert.Equal(t, []string{"up"}, km[0x5], "w is taken away from 5")
+	assert.Equal(t, []string{"axis1+"}, km[0x8], "and s from 8")
+	assert.NoError(t, km.check(knownKeys))
+}
+
+// knownKeys stands in for the names the window knows in tests, which are
+// built without it.
+func knownKeys(name string) bool {
+	_, pad := parsePadInput(name)
+	return len(name) == 1 || pad || name == "up" || name == "down"
+}
+
+func TestDocumentedKeymapsPassCheck(t *testing.T) {
+	for _, config := range []string{
+		`{"keys": {"5": ["w", "up"]}, "roms": {"PONG": {"1": ["w"], "4": ["s", "down"]}}}`,
+		`{"roms": {"BRIX": {"4": ["a", "button13"], "6": ["d", "button14"]}}}`,
+	} {
+		f := &keymapFile{}
+		if !assert.NoError(t, json.Unmarshal([]byte(config), f)) {
+			continue
+		}
+		for _, rom := range []string{"roms/PONG", "roms/BRIX", "roms/TETRIS"} {
+			km, err := f.keymap(rom)
+			assert.NoError(t, err, rom)
+			assert.NoError(t, km.check(knownKeys), "%s: %s", config, rom)
+		}
+	}
+	f := &keymapFile{ROMs: map[string]map[string][]string{"BRIX": {"4": {"a", "button13"}, "6": {"d", "button14"}}}}
+	km, _ := f.keymap("roms/BRIX")
+	assert.Equal(t, []string{"a", "button13"}, km[0x4])
+	assert.Equal(t, []string{}, km[0x7])
+	assert.Equal(t, []string{"d", "button14"}, km[0x6])
+	assert.Equal(t, []string{}, km[0x9])
+}
+
+func TestKeymapFileSameKeyTwiceFailsCheck(t *testing.T) {
+	f := &keymapFile{Keys: map[string][]string{"1": {"w"}, "2": {"w"}}}
+	km, err := f.keymap("")
+	assert.NoError(t, err)
+	assert.Error(t, km.check(knownKeys))
+}
+
+func TestKeymapFileErrors(t *testing.T) {
+	dir, err := ioutil.TempDir("", "keymap")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "keys.json")
+
+	f, err := loadKeymapFile(path)
+	assert.NoError(t, err, "a missing file is an empty one")
+	assert.Empty(t, f.Keys)
+
+	for _, config := range []string{`{"keys": {"G": ["g"]}}`, `{"roms": {"PONG": {"10": ["g"]}}}`, `{"keys": `} {
+		assert.NoError(t, ioutil.WriteFile(path, []byte(config), 0644))
+		_, err := loadKeymapFile(path)
+		assert.Error(t, err, config)
+	}
+}
+
+func TestSetKeymapSavesOnlyChanges(t *testing.T) {
+	dir, err := ioutil.TempDir("", "keymap")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "go-8", "keys.json")
+
+	f := &keymapFile{Keys: map[string][]string{"5": {"w", "up"}}}
+	km, _ := f.keymap("roms/PONG")
+	km.assign(0x1, []string{"w"})
+	f.setKeymap("roms/PONG", km)
+	assert.Equal(t, map[string][]string{"1": {"w"}, "5": {"up"}}, f.ROMs["PONG"])
+	assert.NoError(t, f.save(path))
+
+	saved, err := loadKeymapFile(path)
+	if assert.NoError(t, err) {
+		assert.Equal(t, f, saved)
+	}
+
+	f.setKeymap("roms/PONG", defaultKeymap())
+	assert.Equal(t, map[string][]string{"5": {"w", "button0"}}, f.ROMs["PONG"])
+	km, _ = f.keymap("")
+	f.setKeymap("roms/PONG", km)
+	assert.NotContains(t, f.ROMs, "PONG")
+}
+
+func TestKeymapCheck(t *testing.T) {
+	known := func(name string) bool {
+		_, pad := parsePadInput(name)
+		return len(name) == 1 || pad
+	}
+	km := defaultKeymap()
+	assert.NoError(t, km.check(known))
+	km[0x1] = []string{"1", "up"}
+	assert.EqualError(t, km.check(known), `unknown key "up" in the keymap`)
+	km[0x1] = []string{"1", "q"}
+	assert.EqualError(t, km.check(known), `"q" is in the keymap for both 1 and 4`)
+}
+
+func TestRemapText(t *testing.T) {
+	km := defaultKeymap()
+	km[0x1] = []string{"1", "kp1"}
+	lines := strings.Split(remapText("roms/PONG", km, 1, []string{"up"}), "\n")
+	assert.Equal(t, "Remapping the keys for PONG", lines[0])
+	assert.Equal(t, "1 1,kp1       2 up_         3 3           C 4", lines[2])
+	assert.Equal(t, "Press the keys for 2, then Enter.", lines[7])
+}
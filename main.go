@@ -1,120 +1,60 @@
 package main
 
 import (
-	"github.com/hajimehoshi/ebiten"
-	"github.com/hajimehoshi/ebiten/audio"
-	"github.com/hajimehoshi/ebiten/audio/mp3"
-	"github.com/hajimehoshi/ebiten/ebitenutil"
-	"image/color"
+	"flag"
+	"fmt"
+	"os"
 )
 
-var (
-	chip8 cpu
-)
-
-var keyMap map[ebiten.Key]byte
-
-var audioPlayer *audio.Player
+const usage = `go-8 is a CHIP-8 interpreter.
 
-func setupKeys() {
-	keyMap = make(map[ebiten.Key]byte)
-	keyMap[ebiten.Key1] = 0x01
-	keyMap[ebiten.Key2] = 0x02
-	keyMap[ebiten.Key3] = 0x03
-	keyMap[ebiten.Key4] = 0x0C
-	keyMap[ebiten.KeyQ] = 0x04
-	keyMap[ebiten.KeyW] = 0x05
-	keyMap[ebiten.KeyE] = 0x06
-	keyMap[ebiten.KeyR] = 0x0D
-	keyMap[ebiten.KeyA] = 0x07
-	keyMap[ebiten.KeyS] = 0x08
-	keyMap[ebiten.KeyD] = 0x09
-	keyMap[ebiten.KeyF] = 0x0E
-	keyMap[ebiten.KeyZ] = 0x0A
-	keyMap[ebiten.KeyX] = 0x00
-	keyMap[ebiten.KeyC] = 0x0B
-	keyMap[ebiten.KeyV] = 0x0F
-}
+Usage:
 
-var (
-	square *ebiten.Image
-)
+	go-8                     play roms/PONG in a window
+	go-8 run [flags] [rom]   run a ROM in a window or terminal, or headless for scripts and CI
+	go-8 disasm [flags] rom  list a ROM's instructions
+	go-8 asm [flags] source  assemble a ROM
+	go-8 octo [flags] source compile an Octo program into a ROM
+	go-8 debug [flags] rom   step through a ROM with breakpoints and watchpoints
+	go-8 replay [flags] movie rom
+	                         play back a movie recorded with run --record
 
-func init() {
-	square, _ = ebiten.NewImage(10, 10, ebiten.FilterNearest)
-	square.Fill(color.White)
-}
+Run "go-8 <command> -h" to see a command's flags.
+`
 
-func getInput() bool {
-	for key, value := range keyMap {
-		if ebiten.IsKeyPressed(key) {
-			chip8.keys[value] = 0x01
-			return true
-		}
+func main() {
+	if len(os.Args) < 2 {
+		exit(runCommand([]string{"roms/PONG"}))
 	}
-	return false
-}
-
-func update(screen *ebiten.Image) error {
-
-	// fill screen
-	screen.Fill(color.NRGBA{0x00, 0x00, 0x00, 0xff})
-
-	for i := 0; i < 10; i++ {
-
-		chip8.draw = false
-		chip8.inputflag = false
-		gotInput := true
-		chip8.Run()
-
-		if chip8.inputflag {
-			gotInput = getInput()
-			if !gotInput {
-				chip8.pc = chip8.pc - 2
-			}
-		}
-
-		if chip8.draw || !gotInput {
-			for i := 0; i < 32; i++ {
-				for j := 0; j < 64; j++ {
-					if chip8.display[i][j] == 0x01 {
-
-						opts := &ebiten.DrawImageOptions{}
-
-						opts.GeoM.Translate(float64(j*10), float64(i*10))
-
-						screen.DrawImage(square, opts)
-					}
-				}
-			}
-		}
-		for key, value := range keyMap {
-			if ebiten.IsKeyPressed(key) {
-				chip8.keys[value] = 0x01
-			} else {
-				chip8.keys[value] = 0x00
-			}
-		}
-
-		if chip8.soundTimer > 0 {
-			audioPlayer.Play()
-			audioPlayer.Rewind()
-		}
-
+	switch os.Args[1] {
+	case "run":
+		exit(runCommand(os.Args[2:]))
+	case "disasm":
+		exit(disasmCommand(os.Args[2:]))
+	case "asm":
+		exit(asmCommand(os.Args[2:]))
+	case "octo":
+		exit(octoCommand(os.Args[2:]))
+	case "debug":
+		exit(debugCommand(os.Args[2:]))
+	case "replay":
+		exit(replayCommand(os.Args[2:]))
+	case "help", "-h", "--help":
+		fmt.Print(usage)
+	default:
+		fmt.Fprintf(os.Stderr, "go-8: unknown command %q\n\n%s", os.Args[1], usage)
+		os.Exit(2)
 	}
-
-	return nil
 }
 
-func main() {
-	audioContext, _ := audio.NewContext(48000)
-	f, _ := ebitenutil.OpenFile("assets/beep.mp3")
-	d, _ := mp3.Decode(audioContext, f)
-	audioPlayer, _ = audio.NewPlayer(audioContext, d)
-	setupKeys()
-	chip8 = NewCpu()
-	chip8.LoadProgram("roms/PONG")
-	if err := ebiten.Run(update, 640, 320, 1, "PONG"); err != nil {
-		panic(err)
+// exit ends the program, reporting err if there is one.
+func exit(err error) {
+	if err == flag.ErrHelp {
+		os.Exit(0)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "go-8:", err)
+		os.Exit(1)
 	}
+	os.Exit(0)
 }
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"github.com/h4ck3rk3y/go-8/chip8"
+	"os"
+)
+
+// heldKeys is the keys k holds down, bit n set for key n, the way a movie
+// records them.
+func heldKeys(k chip8.Keypad) uint16 {
+	var keys uint16
+	for key := uint(0); key < 16; key++ {
+		if k.IsPressed(byte(key)) {
+			keys |= 1 << key
+		}
+	}
+	return keys
+}
+
+// readMovieFile reads the movie at path.
+func readMovieFile(path string) (*chip8.Movie, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	mv, err := chip8.ReadMovie(bufio.NewReader(f))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return mv, nil
+}
+
+// writeMovieFile writes mv to path.
+func writeMovieFile(path string, mv *chip8.Movie) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	if err := mv.Write(w); err != nil {
+		f.Close()
+		return err
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// movieRun records a movie of the machine as it's played, or replays one
+// on it in place of the keypad.
+type movieRun struct {
+	movie     *chip8.Movie
+	path      string // where a recording is written at the end
+	replaying bool
+	frame     int // the next frame to replay
+}
+
+// startMovie sets up the movie c asks for, if any, for m, which has just
+// had c's ROM loaded. It returns nil when there's nothing to record or
+// replay.
+func startMovie(c config, m *chip8.Machine) (*movieRun, error) {
+	if c.replay != nil {
+		return &movieRun{movie: c.replay, replaying: true}, nil
+	}
+	if c.record == "" {
+		return nil, nil
+	}
+	rom, err := readROM(c.rom)
+	if err != nil {
+		return nil, err
+	}
+	return &movieRun{movie: chip8.NewMovie(m, rom, c.ipf), path: c.record}, nil
+}
+
+// done reports whether a replay has played every frame, which the last one
+// is left on the screen after. It's never true without a movie.
+func (r *movieRun) done() bool {
+	return r != nil && r.replaying && r.frame >= len(r.movie.Frames)
+}
+
+// runFrame runs a frame of m, either the next one of the replay or one
+// with the keys keypad holds down, which are recorded.
+func (r *movieRun) runFrame(m *chip8.Machine, keypad chip8.Keypad) error {
+	if r.replaying {
+		r.frame++
+		_, err := r.movie.PlayFrame(m, r.frame-1)
+		return err
+	}
+	_, err := r.movie.RunFrame(m, heldKeys(keypad))
+	return err
+}
+
+// back goes back a frame along with the machine when it's rewound.
+func (r *movieRun) back() {
+	if r.replaying {
+		if r.frame > 0 {
+			r.frame--
+		}
+		return
+	}
+	r.movie.Back()
+}
+
+// finish writes a recording out, with m's state at the end to check replays
+// against.
+func (r *movieRun) finish(m *chip8.Machine) error {
+	if r.replaying {
+		return nil
+	}
+	r.movie.Finish(m)
+	if err := writeMovieFile(r.path, r.movie); err != nil {
+		return fmt.Errorf("writing the movie: %v", err)
+	}
+	return nil
+}
+
+// checkReplay compares m's state with the state mv says it ended in. It
+// returns a message saying how that went, and false if they're different.
+func checkReplay(mv *chip8.Movie, m *chip8.Machine) (string, bool) {
+	var zero [len(mv.StateHash)]byte
+	switch {
+	case mv.StateHash == zero:
+		return "the movie has no state at the end to check the replay against", true
+	case m.StateHash() != mv.StateHash:
+		return "the state at the end doesn't match the recording", false
+	}
+	return "the state at the end matches the recording", true
+}
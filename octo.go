@@ -0,0 +1,85 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/h4ck3rk3y/go-8/chip8"
+	"github.com/h4ck3rk3y/go-8/chip8/octo"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+func octoCommand(args []string) error {
+	flags := flag.NewFlagSet("octo", flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: go-8 octo [flags] source\n\n")
+		flags.PrintDefaults()
+	}
+	out := flags.String("out", "", "the ROM to write (default is the source with a .ch8 extension)")
+	sym := flags.String("sym", "", "the symbol map to write (default is the source with a .sym extension)")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return errors.New("octo needs a source file")
+	}
+	source := flags.Arg(0)
+	base := strings.TrimSuffix(source, filepath.Ext(source))
+	if *out == "" {
+		*out = base + ".ch8"
+	}
+	if *sym == "" {
+		*sym = base + ".sym"
+	}
+
+	program, err := octo.CompileFile(source)
+	if err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(*out, program.Code, 0644); err != nil {
+		return err
+	}
+	f, err := os.Create(*sym)
+	if err != nil {
+		return err
+	}
+	if err := program.WriteSymbols(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// loadROM loads the ROM at path into m, compiling it first if it's an Octo
+// program.
+func loadROM(m *chip8.Machine, path string) error {
+	if filepath.Ext(path) != ".8o" {
+		_, err := m.LoadFile(path)
+		return err
+	}
+	code, err := readROM(path)
+	if err != nil {
+		return err
+	}
+	if _, err := m.Load(bytes.NewReader(code)); err != nil {
+		return fmt.Errorf("%s: %v", path, err)
+	}
+	return nil
+}
+
+// readROM reads the ROM at path, compiling it first if it's an Octo
+// program.
+func readROM(path string) ([]byte, error) {
+	if filepath.Ext(path) != ".8o" {
+		return ioutil.ReadFile(path)
+	}
+	program, err := octo.CompileFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return program.Code, nil
+}
@@ -0,0 +1,84 @@
+//go:build !headless
+// +build !headless
+
+package main
+
+import (
+	"github.com/hajimehoshi/ebiten"
+	"github.com/hajimehoshi/ebiten/ebitenutil"
+	"github.com/hajimehoshi/ebiten/inpututil"
+	"image/color"
+)
+
+// The panel has room for 45 characters by 29 lines of
+// ebitenutil.DebugPrint's font. The sprite at I is drawn in its top right
+// corner, beside the registers, at spriteScale screen pixels to a pixel.
+const (
+	panelWidth  = 280
+	panelHeight = 464
+	spriteScale = 4
+	spriteX     = panelWidth - 8*spriteScale - 8
+)
+
+var (
+	// showPanel is whether the debug panel is next to the game.
+	showPanel bool
+	panel     *ebiten.Image
+	panelFill = color.Gray{Y: 0x20}
+)
+
+// togglePanel shows or hides the debug panel when F12 is pressed, making
+// the window wider, and taller if need be, to fit it.
+func togglePanel() {
+	if !inpututil.IsKeyJustPressed(ebiten.KeyF12) {
+		return
+	}
+	showPanel = !showPanel
+	w, h := 64*settings.scale, 32*settings.scale
+	if showPanel {
+		if panel == nil {
+			panel, _ = ebiten.NewImage(panelWidth, panelHeight, ebiten.FilterNearest)
+		}
+		w += panelWidth
+		if h < panelHeight {
+			h = panelHeight
+		}
+	}
+	ebiten.SetScreenSize(w, h)
+}
+
+// renderPanel draws the debug panel to the right of the game, with the 16
+// bytes I points to drawn as a sprite in its top right corner.
+func renderPanel(screen *ebiten.Image) {
+	if !showPanel {
+		return
+	}
+	panel.Fill(panelFill)
+	var keys [16]bool
+	for key := range keys {
+		keys[key] = ebitenKeypad{}.IsPressed(byte(key))
+	}
+	ebitenutil.DebugPrint(panel, panelText(machine, keys))
+
+	opts := &ebiten.DrawImageOptions{}
+	opts.GeoM.Scale(8*spriteScale, 16*spriteScale)
+	opts.GeoM.Translate(spriteX, 0)
+	panel.DrawImage(squares[0], opts)
+	memory := machine.Memory()
+	for row := 0; row < 16 && int(machine.I)+row < len(memory); row++ {
+		data := memory[int(machine.I)+row]
+		for col := uint(0); col < 8; col++ {
+			if data&(0x80>>col) == 0 {
+				continue
+			}
+			opts := &ebiten.DrawImageOptions{}
+			opts.GeoM.Scale(spriteScale, spriteScale)
+			opts.GeoM.Translate(float64(spriteX+int(col)*spriteScale), float64(row*spriteScale))
+			panel.DrawImage(squares[1], opts)
+		}
+	}
+
+	opts = &ebiten.DrawImageOptions{}
+	opts.GeoM.Translate(float64(64*settings.scale), 0)
+	screen.DrawImage(panel, opts)
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+	"github.com/h4ck3rk3y/go-8/chip8"
+	"strings"
+)
+
+const (
+	panelBefore = 3 // instructions listed before pc on the debug panel
+	panelAfter  = 5 // and after it
+	panelRows   = 8 // rows of memory shown from I, 8 bytes to a row
+)
+
+// panelText is what the debug panel shows of m: the registers, timers,
+// stack and keys held down, whether an FX0A is waiting for a key, the
+// instructions around pc and the memory I points to.
+func panelText(m *chip8.Machine, keys [16]bool) string {
+	b := &strings.Builder{}
+	for i, v := range m.V {
+		fmt.Fprintf(b, "v%X %02X", i, v)
+		if i%4 == 3 {
+			b.WriteString("\n")
+		} else {
+			b.WriteString(" ")
+		}
+	}
+	fmt.Fprintf(b, "i  %03X  pc %03X\n", m.I, m.PC())
+	fmt.Fprintf(b, "dt %3d  st %3d\n", m.DelayTimer(), m.SoundTimer())
+	fmt.Fprintf(b, "stack %03X\n", m.Stack())
+	b.WriteString("keys ")
+	for key, down := range keys {
+		if down {
+			fmt.Fprintf(b, " %X", key)
+		}
+	}
+	if m.WaitingForKey() {
+		b.WriteString("  waiting")
+	}
+	b.WriteString("\n\n")
+
+	memory := m.Memory()
+	pc := int(m.PC())
+	for _, address := range panelAddresses(memory, pc, m.Variant()) {
+		i := chip8.Decode(memory, uint16(address), m.Variant())
+		marker := "  "
+		if address == pc {
+			marker = "=>"
+		}
+		fmt.Fprintf(b, "%s %03X: %04X  %v\n", marker, address, i.Opcode, i)
+	}
+
+	fmt.Fprintf(b, "\nmemory at i\n")
+	for row := 0; row < panelRows; row++ {
+		address := int(m.I) + row*8
+		if address >= len(memory) {
+			break
+		}
+		end := address + 8
+		if end > len(memory) {
+			end = len(memory)
+		}
+		fmt.Fprintf(b, "%04X % X\n", address, memory[address:end])
+	}
+	return b.String()
+}
+
+// panelAddresses are the addresses of the instructions to list around pc.
+// Working backwards from pc can't be done exactly, so the listing starts a
+// few words before it and falls in line with pc if an instruction would
+// run over it.
+func panelAddresses(memory []byte, pc int, variant chip8.Variant) []int {
+	var addresses []int
+	address := pc - 2*panelBefore
+	if address < 0 {
+		address = 0
+	}
+	for after := 0; after <= panelAfter && address < len(memory); {
+		size := chip8.Decode(memory, uint16(address), variant).Size
+		if address < pc && address+size > pc {
+			address = pc
+			size = chip8.Decode(memory, uint16(address), variant).Size
+		}
+		addresses = append(addresses, address)
+		if address >= pc {
+			after++
+		}
+		if size == 0 {
+			break
+		}
+		address += size
+	}
+	return addresses
+}
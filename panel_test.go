@@ -0,0 +1,44 @@
+package main
+
+import (
+	"github.com/h4ck3rk3y/go-8/chip8"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func TestPanelText(t *testing.T) {
+	m := chip8.New(chip8.WithVariant(chip8.VariantXOChip))
+	copy(m.Memory()[0x200:], []byte{
+		0x60, 0x05, // LD V0, 0x05
+		0xF0, 0x00, 0x03, 0x00, // LD I, LONG 0x300
+		0x22, 0x00, // CALL 0x200
+	})
+	for i := 0; i < 3; i++ {
+		assert.NoError(t, m.Step())
+	}
+	copy(m.Memory()[0x300:], []byte{0xF0, 0x90})
+	var keys [16]bool
+	keys[0x5], keys[0xA] = true, true
+
+	text := panelText(m, keys)
+	lines := strings.Split(text, "\n")
+	assert.Equal(t, "v0 05 v1 00 v2 00 v3 00", lines[0])
+	assert.Equal(t, "i  300  pc 200", lines[4])
+	assert.Equal(t, "stack [208]", lines[6])
+	assert.Equal(t, "keys  5 A", lines[7])
+	// The listing starts three words before pc.
+	assert.Equal(t, "   1FA: 0000  DW 0x0000", lines[9])
+	assert.Equal(t, "=> 200: 6005  LD V0, 0x05", lines[12])
+	assert.Equal(t, "   202: F000  LD I, LONG 0x300", lines[13])
+	assert.Equal(t, "   206: 2200  CALL 0x200", lines[14])
+	assert.Contains(t, text, "memory at i\n0300 F0 90 00 00 00 00 00 00\n")
+}
+
+func TestPanelAddressesFallInLineWithPC(t *testing.T) {
+	memory := make([]byte, 0x1000)
+	// A four byte instruction just before pc would run over it.
+	copy(memory[0x202:], []byte{0xF0, 0x00, 0x12, 0x34})
+	addresses := panelAddresses(memory, 0x204, chip8.VariantXOChip)
+	assert.Equal(t, []int{0x1FE, 0x200, 0x204, 0x206, 0x208, 0x20A, 0x20C, 0x20E}, addresses)
+}
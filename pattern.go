@@ -0,0 +1,52 @@
+package main
+
+import (
+	"math"
+	"sync"
+)
+
+const sampleRate = 48000
+
+// patternStream turns the XO-CHIP audio pattern buffer into 16 bit stereo
+// samples, looping over its 128 bits at the rate set by the pitch register.
+// It never ends, so it's paused rather than left to run out.
+type patternStream struct {
+	mu      sync.Mutex
+	pattern [16]byte
+	rate    float64 // pattern bits played per second
+	pos     float64 // bit being played
+}
+
+// set changes what's played. It's called from the game loop while the
+// audio player reads from its own goroutine.
+func (s *patternStream) set(pattern [16]byte, rate float64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pattern = pattern
+	s.rate = rate
+}
+
+func (s *patternStream) Read(b []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := len(b) / 4 * 4
+	for i := 0; i < n; i += 4 {
+		bit := int(s.pos)
+		sample := int16(-0x2000)
+		if (s.pattern[bit/8]>>uint(7-bit%8))&0x01 == 0x01 {
+			sample = 0x2000
+		}
+		b[i], b[i+1] = byte(sample), byte(sample>>8)
+		b[i+2], b[i+3] = byte(sample), byte(sample>>8)
+		s.pos = math.Mod(s.pos+s.rate/sampleRate, float64(len(s.pattern)*8))
+	}
+	return n, nil
+}
+
+func (s *patternStream) Seek(offset int64, whence int) (int64, error) {
+	return 0, nil
+}
+
+func (s *patternStream) Close() error {
+	return nil
+}
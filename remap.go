@@ -0,0 +1,97 @@
+//go:build !headless
+// +build !headless
+
+package main
+
+import (
+	"github.com/hajimehoshi/ebiten"
+	"github.com/hajimehoshi/ebiten/ebitenutil"
+	"github.com/hajimehoshi/ebiten/inpututil"
+	"log"
+)
+
+// remapping is the keymap being made on the remap screen, which F10 opens.
+// It's nil when the screen isn't open, and the game is paused while it is.
+var remapping *remap
+
+type remap struct {
+	km      keymap
+	next    int             // the index in keypadOrder of the key being asked for
+	pressed []string        // keyboard keys and gamepad inputs pressed for it so far
+	pads    map[string]bool // gamepad inputs held down last frame
+}
+
+// updateRemap opens the remap screen when F10 is pressed and, while it's
+// open, takes the keys pressed for each key on the keypad in turn. Once
+// the last one is done the keymap is used from then on, and saved to the
+// keymap config for the ROM being played. It reports whether the screen is
+// open.
+func updateRemap() bool {
+	if remapping == nil {
+		if !inpututil.IsKeyJustPressed(ebiten.KeyF10) {
+			return false
+		}
+		remapping = &remap{km: settings.keymap, pads: padInputsPressed()}
+		return true
+	}
+	r := remapping
+	pads := padInputsPressed()
+	defer func() {
+		r.pads = pads
+	}()
+	switch {
+	case inpututil.IsKeyJustPressed(ebiten.KeyEscape):
+		remapping = nil
+		return false
+	case inpututil.IsKeyJustPressed(ebiten.KeyBackspace):
+		if len(r.pressed) > 0 {
+			r.pressed = r.pressed[:len(r.pressed)-1]
+		}
+	case inpututil.IsKeyJustPressed(ebiten.KeyEnter):
+		if len(r.pressed) > 0 {
+			r.km.assign(keypadOrder[r.next], r.pressed)
+		}
+		r.pressed = nil
+		if r.next++; r.next == len(keypadOrder) {
+			remapping = nil
+			saveKeymap(r.km)
+			return false
+		}
+	default:
+		for name, key := range ebitenKeys {
+			if inpututil.IsKeyJustPressed(key) && !contains(r.pressed, name) {
+				r.pressed = append(r.pressed, name)
+			}
+		}
+		for name := range pads {
+			if !r.pads[name] && !contains(r.pressed, name) {
+				r.pressed = append(r.pressed, name)
+			}
+		}
+	}
+	return true
+}
+
+// saveKeymap plays on with km and saves it as the keymap for the ROM.
+func saveKeymap(km keymap) {
+	if err := setupKeys(km); err != nil {
+		log.Printf("can't use the new keys: %v", err)
+		return
+	}
+	settings.keymap = km
+	keymaps, err := loadKeymapFile(settings.keymaps)
+	if err == nil {
+		keymaps.setKeymap(settings.rom, km)
+		err = keymaps.save(settings.keymaps)
+	}
+	if err != nil {
+		log.Printf("can't save the keys: %v", err)
+		return
+	}
+	log.Printf("saved the keys for %s to %s", settings.rom, settings.keymaps)
+}
+
+func renderRemap(screen *ebiten.Image) {
+	r := remapping
+	ebitenutil.DebugPrint(screen, remapText(settings.rom, r.km, r.next, r.pressed))
+}
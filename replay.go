@@ -0,0 +1,108 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+)
+
+func replayCommand(args []string) error {
+	flags := flag.NewFlagSet("replay", flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: go-8 replay [flags] movie rom\n\n")
+		flags.PrintDefaults()
+	}
+	scale := flags.Int("scale", 10, "window pixels to a CHIP-8 pixel")
+	audio := flags.Bool("audio", true, "play sound, --audio=false to mute")
+	rewind := flags.Int("rewind", 10, "seconds of the replay that holding backspace can rewind, 0 to turn rewinding off")
+	headless := flags.Bool("headless", false, "replay without a window, print the machine's state at the end and check it")
+	terminal := flags.Bool("terminal", false, "replay in the terminal rather than a window")
+	format := flags.String("format", "text", "how to print the state of a headless replay, text or json")
+	out := flags.String("out", "", "file to write the state of a headless replay to instead of stdout")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 2 {
+		return errors.New("replay needs a movie and the ROM it was recorded with")
+	}
+	if *headless && *terminal {
+		return errors.New("--headless and --terminal can't be used together")
+	}
+	if *format != "text" && *format != "json" {
+		return errors.New("--format must be text or json")
+	}
+	mv, err := readMovieFile(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	rom, err := readROM(flags.Arg(1))
+	if err != nil {
+		return err
+	}
+	if err := mv.CheckROM(rom); err != nil {
+		return fmt.Errorf("%s: %v", flags.Arg(1), err)
+	}
+
+	if !*headless {
+		c := config{
+			rom:     flags.Arg(1),
+			variant: mv.Variant,
+			quirks:  mv.Quirks,
+			ipf:     mv.IPF,
+			scale:   *scale,
+			audio:   *audio,
+			rewind:  *rewind,
+			random:  mv.RandomMode,
+			seed:    mv.Seed,
+			seeded:  true,
+			replay:  mv,
+		}
+		if c.scale < 1 {
+			return errors.New("--scale has to be at least 1")
+		}
+		if c.rewind < 0 {
+			return errors.New("--rewind can't be negative")
+		}
+		for i, hex := range []string{"000000", "ffffff", "aaaaaa", "555555"} {
+			c.palette[i], _ = parseColour(hex)
+		}
+		if *terminal {
+			return runTerminal(c)
+		}
+		return runWindow(c)
+	}
+
+	var w io.Writer = os.Stdout
+	if *out != "" {
+		f, err := os.Create(*out)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+	m, frames, ran, stopped := mv.Replay(rom)
+	if m == nil {
+		return stopped
+	}
+	state := newDump(m, ran, stopped)
+	if *format == "json" {
+		err = state.writeJSON(w)
+	} else {
+		err = state.writeText(w)
+	}
+	if err != nil {
+		return err
+	}
+	if stopped != nil && !isExit(stopped) {
+		return stopped
+	}
+	message, ok := checkReplay(mv, m)
+	if !ok {
+		return errors.New(message)
+	}
+	fmt.Fprintf(os.Stderr, "go-8: replayed %d frames, %s\n", frames, message)
+	return nil
+}
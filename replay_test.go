@@ -0,0 +1,66 @@
+package main
+
+import (
+	"bytes"
+	"github.com/h4ck3rk3y/go-8/chip8"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+type testKeypad map[byte]bool
+
+func (k testKeypad) IsPressed(key byte) bool {
+	return k[key]
+}
+
+func TestHeldKeys(t *testing.T) {
+	assert.Equal(t, uint16(0), heldKeys(testKeypad{}))
+	assert.Equal(t, uint16(1<<0x1|1<<0xF), heldKeys(testKeypad{0x1: true, 0xF: true}))
+}
+
+func TestRecordAndReplayHeadless(t *testing.T) {
+	rom, err := readROM("roms/PONG")
+	if !assert.NoError(t, err) {
+		return
+	}
+	keypad := testKeypad{}
+	m := chip8.New(chip8.WithKeypad(keypad))
+	m.Load(bytes.NewReader(rom))
+	run := &movieRun{movie: chip8.NewMovie(m, rom, 10)}
+	for i := 0; i < 200; i++ {
+		keypad[0x1] = i%50 < 25
+		assert.NoError(t, run.runFrame(m, keypad))
+	}
+
+	dir, err := ioutil.TempDir("", "go-8")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(dir)
+	run.path = filepath.Join(dir, "pong.g8m")
+	assert.NoError(t, run.finish(m))
+	mv, err := readMovieFile(run.path)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Len(t, mv.Frames, 200)
+
+	replayed, frames, ran, err := mv.Replay(rom)
+	assert.NoError(t, err)
+	assert.Equal(t, 200, frames)
+	assert.Equal(t, 2000, ran)
+	message, ok := checkReplay(mv, replayed)
+	assert.True(t, ok, message)
+
+	// Moving the paddle down rather than up leaves it somewhere else.
+	for i := range mv.Frames {
+		mv.Frames[i] = 1 << 0x4
+	}
+	replayed, _, _, err = mv.Replay(rom)
+	assert.NoError(t, err)
+	message, ok = checkReplay(mv, replayed)
+	assert.False(t, ok, message)
+}
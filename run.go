@@ -0,0 +1,248 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/h4ck3rk3y/go-8/chip8"
+	"image/color"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// config is how the run command has been asked to run a ROM.
+type config struct {
+	rom     string
+	variant chip8.Variant
+	quirks  chip8.Quirks
+	ipf     int            // instructions executed every frame
+	scale   int            // window pixels to a low resolution pixel
+	palette [4]color.Color // off, first plane, second plane, both planes
+	audio   bool
+	rewind  int // seconds that can be rewound
+	random  chip8.RandomMode
+	seed    int64
+	seeded  bool          // whether seed was given or should come from the clock
+	gdb     string        // address to listen for gdb on, empty for none
+	tracer  chip8.Tracer  // from the --trace flags, nil when not tracing
+	keyHold time.Duration // how long a key typed in a terminal stays down
+	keymap  keymap
+	keymaps string       // the keymap config file, where remapped keys are saved
+	record  string       // file to record a movie to, empty for none
+	replay  *chip8.Movie // movie to play instead of reading the keypad, nil for none
+}
+
+// machineOptions are the options for a machine set up the way c says.
+func (c config) machineOptions() []chip8.Option {
+	options := []chip8.Option{chip8.WithVariant(c.variant), chip8.WithQuirks(c.quirks), chip8.WithRandomMode(c.random)}
+	if c.seeded {
+		options = append(options, chip8.WithSeed(c.seed))
+	}
+	if c.tracer != nil {
+		options = append(options, chip8.WithTracer(c.tracer))
+	}
+	return options
+}
+
+func runCommand(args []string) error {
+	flags := flag.NewFlagSet("run", flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: go-8 run [flags] [rom]\n\n")
+		flags.PrintDefaults()
+	}
+	rom := flags.String("rom", "", "the ROM to run, which can also be given after the flags")
+	variant := flags.String("variant", "chip8", "the machine to emulate: chip8, schip or xochip")
+	quirks := flags.String("quirks", "", "quirks preset: vip, chip48, schip, xochip or modern (default is the variant's usual)")
+	ipf := flags.Int("ipf", 10, "instructions executed every frame")
+	scale := flags.Int("scale", 10, "window pixels to a CHIP-8 pixel")
+	bg := flags.String("bg", "000000", "background colour as RRGGBB")
+	fg := flags.String("fg", "ffffff", "colour of pixels that are on")
+	fg2 := flags.String("fg2", "aaaaaa", "colour of pixels on XO-CHIP's second plane")
+	blend := flags.String("blend", "555555", "colour of pixels on both XO-CHIP planes")
+	rewind := flags.Int("rewind", 10, "seconds of play that holding backspace can rewind, 0 to turn rewinding off")
+	audio := flags.Bool("audio", true, "play sound, --audio=false to mute")
+	random := flags.String("random", "xorshift", "how random numbers are made: xorshift or vip")
+	seed := flags.Int64("seed", 0, "seed for the random numbers (default is one from the clock)")
+	headless := flags.Bool("headless", false, "run without a window and print the machine's state at the end")
+	terminal := flags.Bool("terminal", false, "play in the terminal rather than a window, for when there's no display")
+	keymapPath := flags.String("keymap", defaultKeymapPath(), "the keymap config file")
+	keyHold := flags.Duration("key-hold", 300*time.Millisecond, "how long a key typed in the terminal stays held down")
+	cycles := flags.Int("cycles", 1000, "instructions to execute when headless")
+	frames := flags.Int("frames", 0, "frames to run when headless, each --ipf instructions, instead of --cycles")
+	input := flags.String("input", "", "file of scripted key presses for a headless run")
+	format := flags.String("format", "text", "how to print the state of a headless run, text or json")
+	out := flags.String("out", "", "file to write the state of a headless run to instead of stdout")
+	gdb := flags.String("gdb", "", "listen for gdb on this address, such as localhost:1234")
+	trace := flags.String("trace", "", "write every instruction executed to this file")
+	traceFormat := flags.String("trace-format", "text", "how to write the trace, text or binary")
+	traceRange := flags.String("trace-range", "", "only trace instructions at these addresses, such as 200-2FF")
+	traceOps := flags.String("trace-ops", "", "only trace opcodes starting with these hex digits, such as 1,2,B")
+	traceRing := flags.Int("trace-ring", 0, "keep the last N traced instructions and write them only when one fails")
+	record := flags.String("record", "", "record the keys pressed to this movie file, to play back with go-8 replay")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	c := config{rom: *rom, ipf: *ipf, scale: *scale, audio: *audio, rewind: *rewind, seed: *seed, gdb: *gdb, keyHold: *keyHold, record: *record}
+	flags.Visit(func(f *flag.Flag) {
+		c.seeded = c.seeded || f.Name == "seed"
+	})
+	switch {
+	case c.rom == "" && flags.NArg() == 1:
+		c.rom = flags.Arg(0)
+	case flags.NArg() > 0:
+		return fmt.Errorf("unexpected arguments %q after the flags", flags.Args())
+	case c.rom == "":
+		return errors.New("run needs a ROM, pass one with --rom or after the flags")
+	}
+	var err error
+	if c.variant, err = chip8.ParseVariant(*variant); err != nil {
+		return err
+	}
+	if c.random, err = chip8.ParseRandomMode(*random); err != nil {
+		return err
+	}
+	c.quirks = chip8.DefaultQuirks(c.variant)
+	if *quirks != "" {
+		if c.quirks, err = chip8.ParseQuirks(*quirks); err != nil {
+			return err
+		}
+	}
+	if c.ipf < 1 {
+		return errors.New("--ipf has to be at least 1")
+	}
+	if c.rewind < 0 {
+		return errors.New("--rewind can't be negative")
+	}
+	if c.scale < 1 {
+		return errors.New("--scale has to be at least 1")
+	}
+	for i, hex := range []string{*bg, *fg, *fg2, *blend} {
+		if c.palette[i], err = parseColour(hex); err != nil {
+			return err
+		}
+	}
+	if *trace != "" {
+		format, err := chip8.ParseTraceFormat(*traceFormat)
+		if err != nil {
+			return err
+		}
+		var filter chip8.TraceFilter
+		if *traceRange != "" {
+			if err := parseTraceRange(*traceRange, &filter); err != nil {
+				return err
+			}
+		}
+		if *traceOps != "" {
+			if filter.Classes, err = chip8.ParseTraceClasses(*traceOps); err != nil {
+				return err
+			}
+		}
+		if *traceRing < 0 {
+			return errors.New("--trace-ring can't be negative")
+		}
+		t, err := openTrace(*trace, format, filter, *traceRing)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			if err := t.Close(); err != nil {
+				fmt.Fprintln(os.Stderr, "go-8: writing the trace:", err)
+			}
+		}()
+		c.tracer = t.trace
+	}
+
+	if *headless && *terminal {
+		return errors.New("--headless and --terminal can't be used together")
+	}
+	if c.record != "" && (*headless || c.gdb != "") {
+		return errors.New("--record can't be used with --headless or --gdb")
+	}
+	if !*headless {
+		c.keymaps = *keymapPath
+		keymaps, err := loadKeymapFile(c.keymaps)
+		if err != nil {
+			return err
+		}
+		if c.keymap, err = keymaps.keymap(c.rom); err != nil {
+			return err
+		}
+		if *terminal {
+			return runTerminal(c)
+		}
+		return runWindow(c)
+	}
+	if *frames > 0 {
+		*cycles = *frames * c.ipf
+	}
+	if *format != "text" && *format != "json" {
+		return errors.New("--format must be text or json")
+	}
+
+	var script []keyEvent
+	if *input != "" {
+		f, err := os.Open(*input)
+		if err != nil {
+			return err
+		}
+		script, err = parseScript(f)
+		f.Close()
+		if err != nil {
+			return fmt.Errorf("%s: %v", *input, err)
+		}
+	}
+
+	m := chip8.New(c.machineOptions()...)
+	if err := loadROM(m, c.rom); err != nil {
+		return err
+	}
+
+	var w io.Writer = os.Stdout
+	if *out != "" {
+		f, err := os.Create(*out)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+
+	var ran int
+	var stopped error
+	if c.gdb != "" {
+		server, d, err := serveGDB(m, c.gdb, c.ipf)
+		if err != nil {
+			return err
+		}
+		ran, stopped = runHeadlessGDB(server, d, c.ipf)
+	} else {
+		ran, stopped = runHeadless(m, *cycles, c.ipf, script)
+	}
+	state := newDump(m, ran, stopped)
+	if *format == "json" {
+		err = state.writeJSON(w)
+	} else {
+		err = state.writeText(w)
+	}
+	if err != nil {
+		return err
+	}
+	if stopped != nil && !isExit(stopped) {
+		return stopped
+	}
+	return nil
+}
+
+// parseColour reads a colour written as RRGGBB, with or without a leading #.
+func parseColour(s string) (color.Color, error) {
+	hex := strings.TrimPrefix(s, "#")
+	rgb, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil || len(hex) != 6 {
+		return nil, fmt.Errorf("bad colour %q, want RRGGBB", s)
+	}
+	return color.NRGBA{byte(rgb >> 16), byte(rgb >> 8), byte(rgb), 0xff}, nil
+}
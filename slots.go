@@ -0,0 +1,42 @@
+package main
+
+import (
+	"fmt"
+	"github.com/h4ck3rk3y/go-8/chip8"
+	"os"
+)
+
+// slotPath is the file quick save slot n of rom is kept in, next to the
+// ROM itself.
+func slotPath(rom string, n int) string {
+	return fmt.Sprintf("%s.%d.state", rom, n)
+}
+
+// saveSlot saves the state of m to quick save slot n of rom.
+func saveSlot(m *chip8.Machine, rom string, n int) error {
+	f, err := os.Create(slotPath(rom, n))
+	if err != nil {
+		return err
+	}
+	if err := m.SaveState(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// loadSlot puts m back in the state saved in quick save slot n of rom.
+func loadSlot(m *chip8.Machine, rom string, n int) error {
+	f, err := os.Open(slotPath(rom, n))
+	if os.IsNotExist(err) {
+		return fmt.Errorf("nothing saved in slot %d", n)
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if err := m.LoadState(f); err != nil {
+		return fmt.Errorf("%s: %v", f.Name(), err)
+	}
+	return nil
+}
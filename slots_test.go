@@ -0,0 +1,27 @@
+package main
+
+import (
+	"github.com/h4ck3rk3y/go-8/chip8"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSaveAndLoadSlot(t *testing.T) {
+	dir, err := ioutil.TempDir("", "go-8")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	rom := filepath.Join(dir, "PONG")
+
+	m := chip8.New()
+	assert.Error(t, loadSlot(m, rom, 1))
+
+	m.V[0x4] = 0x44
+	assert.NoError(t, saveSlot(m, rom, 1))
+	assert.FileExists(t, filepath.Join(dir, "PONG.1.state"))
+	m.V[0x4] = 0x00
+	assert.NoError(t, loadSlot(m, rom, 1))
+	assert.Equal(t, byte(0x44), m.V[0x4])
+}
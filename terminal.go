@@ -0,0 +1,281 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"github.com/h4ck3rk3y/go-8/chip8"
+	"github.com/h4ck3rk3y/go-8/chip8/gdbstub"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// terminalDisplay keeps hold of the last frame the machine drew, and
+// whether it's been put on the terminal yet.
+type terminalDisplay struct {
+	frame   chip8.Frame
+	changed bool
+}
+
+func (d *terminalDisplay) Draw(frame chip8.Frame) {
+	d.frame = frame
+	d.changed = true
+}
+
+// halfBlocks are the characters for two pixels one above the other: both
+// off, the top one lit, the bottom one lit and both lit.
+var halfBlocks = [...]rune{' ', '▀', '▄', '█'}
+
+// terminalText is frame drawn with characters, 64 columns by 16 lines in
+// either resolution. In low resolution each character is two pixels, one
+// above the other, drawn with half blocks. In high resolution each is eight,
+// two across and four down, drawn in braille. Pixels on either plane are
+// lit.
+func terminalText(frame chip8.Frame) string {
+	lit := func(x, y int) bool {
+		return x < frame.Width && y < frame.Height && frame.At(x, y) != 0x00
+	}
+	var lines []string
+	if frame.Width <= 64 {
+		for y := 0; y < frame.Height; y += 2 {
+			line := make([]rune, frame.Width)
+			for x := range line {
+				block := 0
+				if lit(x, y) {
+					block |= 1
+				}
+				if lit(x, y+1) {
+					block |= 2
+				}
+				line[x] = halfBlocks[block]
+			}
+			lines = append(lines, string(line))
+		}
+		return strings.Join(lines, "\r\n")
+	}
+	// The dots of a braille character, numbered the way Unicode's bits are.
+	dots := [4][2]rune{{0x01, 0x08}, {0x02, 0x10}, {0x04, 0x20}, {0x40, 0x80}}
+	for y := 0; y < frame.Height; y += 4 {
+		line := make([]rune, (frame.Width+1)/2)
+		for i := range line {
+			line[i] = 0x2800
+			for row := range dots {
+				for col := range dots[row] {
+					if lit(2*i+col, y+row) {
+						line[i] |= dots[row][col]
+					}
+				}
+			}
+		}
+		lines = append(lines, string(line))
+	}
+	return strings.Join(lines, "\r\n")
+}
+
+// terminalKeypad holds keys down for a while after they're typed, since a
+// terminal only says when a key is typed and not when it's let go. A key
+// that's held down keeps being typed by the keyboard's auto-repeat, so it
+// stays down as long as the gap before the repeats starts is under hold.
+type terminalKeypad struct {
+	keys  map[rune]byte // the hex keypad key each character presses
+	hold  time.Duration
+	now   time.Time     // the time of the frame being run
+	until [16]time.Time // when each key is let go
+}
+
+// newTerminalKeypad returns a keypad for the keys in km that can be typed
+// in a terminal: the ones named by a single character, and space. Others,
+// such as the arrows, can't be told apart from the escape sequences they
+// send and are left out.
+func newTerminalKeypad(km keymap, hold time.Duration) *terminalKeypad {
+	k := &terminalKeypad{keys: map[rune]byte{}, hold: hold}
+	for name, key := range km.keys() {
+		if name == "space" {
+			name = " "
+		}
+		if chars := []rune(name); len(chars) == 1 {
+			k.keys[chars[0]] = key
+		}
+	}
+	return k
+}
+
+// typed presses the key on the hex keypad that char stands for, if there
+// is one.
+func (k *terminalKeypad) typed(char rune, at time.Time) {
+	if key, ok := k.keys[unicode.ToLower(char)]; ok {
+		k.until[key] = at.Add(k.hold)
+	}
+}
+
+func (k *terminalKeypad) IsPressed(key byte) bool {
+	return k.now.Before(k.until[key&0x0F])
+}
+
+// terminalSound rings the terminal's bell when the buzzer starts. The bell
+// can't be held for as long as the sound timer runs, so it's only rung
+// once.
+type terminalSound struct {
+	w io.Writer
+}
+
+func (s terminalSound) Beep(on bool) {
+	if on {
+		io.WriteString(s.w, "\a")
+	}
+}
+
+// readKeys sends the characters typed on r to typed until r runs out, then
+// closes it. Escape sequences, such as the ones the arrow keys send, come
+// in a read of their own and are dropped so that their letters aren't
+// taken for keys.
+func readKeys(r io.Reader, typed chan<- rune) {
+	defer close(typed)
+	b := make([]byte, 64)
+	for {
+		n, err := r.Read(b)
+		if n > 0 && b[0] != 0x1b {
+			for _, char := range string(b[:n]) {
+				typed <- char
+			}
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// stty runs stty on the terminal stdin is connected to.
+func stty(args ...string) (string, error) {
+	cmd := exec.Command("stty", args...)
+	cmd.Stdin = os.Stdin
+	out, err := cmd.Output()
+	return strings.TrimSpace(string(out)), err
+}
+
+// rawMode stops the terminal echoing what's typed and waiting for a whole
+// line, and returns a function to put it back the way it was.
+func rawMode() (func(), error) {
+	saved, err := stty("-g")
+	if err != nil {
+		return nil, fmt.Errorf("can't read the terminal's settings, is stdin a terminal? %v", err)
+	}
+	if _, err := stty("raw", "-echo"); err != nil {
+		return nil, fmt.Errorf("can't put the terminal in raw mode: %v", err)
+	}
+	return func() {
+		stty(saved)
+	}, nil
+}
+
+const (
+	ctrlC = 0x03
+	ctrlD = 0x04
+)
+
+// runTerminal plays the ROM c asks for in the terminal until Ctrl-C is
+// typed, for when there's no display to open a window on.
+func runTerminal(c config) error {
+	display := &terminalDisplay{}
+	keypad := newTerminalKeypad(c.keymap, c.keyHold)
+	out := bufio.NewWriter(os.Stdout)
+	options := append(c.machineOptions(), chip8.WithDisplay(display))
+	if c.replay == nil {
+		options = append(options, chip8.WithKeypad(keypad))
+	}
+	if c.audio {
+		options = append(options, chip8.WithSound(terminalSound{out}))
+	}
+	m := chip8.New(options...)
+	if err := loadROM(m, c.rom); err != nil {
+		return err
+	}
+	var stub *gdbstub.Server
+	if c.gdb != "" {
+		var err error
+		if stub, _, err = serveGDB(m, c.gdb, c.ipf); err != nil {
+			return err
+		}
+	}
+	movie, err := startMovie(c, m)
+	if err != nil {
+		return err
+	}
+
+	restore, err := rawMode()
+	if err != nil {
+		return err
+	}
+	defer restore()
+	// Draw on the alternate screen, without a cursor, and leave the
+	// terminal as it was found afterwards.
+	out.WriteString("\x1b[?1049h\x1b[?25l\x1b[2J")
+	defer func() {
+		out.WriteString("\x1b[?25h\x1b[?1049l")
+		out.Flush()
+	}()
+
+	typed := make(chan rune, 64)
+	go readKeys(os.Stdin, typed)
+	ticker := time.NewTicker(time.Second / 60)
+	defer ticker.Stop()
+	var halted error
+	status := ""
+	// quit ends the run, writing out the movie if one's being recorded.
+	quit := func() error {
+		if movie != nil {
+			if err := movie.finish(m); err != nil {
+				return err
+			}
+		}
+		if halted != nil && !isExit(halted) {
+			return halted
+		}
+		return nil
+	}
+	for {
+		now := <-ticker.C
+		keypad.now = now
+	keys:
+		for {
+			select {
+			case char, ok := <-typed:
+				if !ok || char == ctrlC || char == ctrlD {
+					return quit()
+				}
+				keypad.typed(char, now)
+			default:
+				break keys
+			}
+		}
+
+		if halted == nil && !movie.done() {
+			switch {
+			case stub != nil:
+				halted = stub.RunFrame(c.ipf)
+			case movie != nil:
+				halted = movie.runFrame(m, keypad)
+			default:
+				_, halted = m.RunFrame(c.ipf)
+			}
+		}
+		newStatus := "Ctrl-C quits"
+		if halted != nil {
+			newStatus = "machine halted: " + halted.Error() + ", Ctrl-C quits"
+		} else if movie.done() {
+			message, _ := checkReplay(movie.movie, m)
+			newStatus = "replay finished, " + message + ", Ctrl-C quits"
+		}
+		if display.changed || newStatus != status {
+			status = newStatus
+			display.changed = false
+			fmt.Fprintf(out, "\x1b[H%s\r\n%s\x1b[K", terminalText(display.frame), status)
+		}
+		if err := out.Flush(); err != nil {
+			return err
+		}
+	}
+}
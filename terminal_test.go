@@ -0,0 +1,73 @@
+package main
+
+import (
+	"github.com/h4ck3rk3y/go-8/chip8"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTerminalTextHalfBlocks(t *testing.T) {
+	frame := chip8.Frame{Width: 64, Height: 32, Pixels: make([]byte, 64*32)}
+	frame.Pixels[0] = 0x01                           // top of the first character
+	frame.Pixels[64+1] = 0x02                        // bottom of the second
+	frame.Pixels[2], frame.Pixels[64+2] = 0x01, 0x03 // both of the third
+	lines := strings.Split(terminalText(frame), "\r\n")
+	if assert.Len(t, lines, 16) {
+		assert.Equal(t, "▀▄█"+strings.Repeat(" ", 61), lines[0])
+		assert.Equal(t, strings.Repeat(" ", 64), lines[15])
+	}
+}
+
+func TestTerminalTextBraille(t *testing.T) {
+	frame := chip8.Frame{Width: 128, Height: 64, Pixels: make([]byte, 128*64)}
+	frame.Pixels[0] = 0x01       // dot 1
+	frame.Pixels[3*128+1] = 0x01 // dot 8
+	frame.Pixels[4*128+2] = 0x01 // dot 1 of the second character on the second line
+	lines := strings.Split(terminalText(frame), "\r\n")
+	if assert.Len(t, lines, 16) {
+		assert.Equal(t, "⢁"+strings.Repeat("⠀", 63), lines[0])
+		assert.Equal(t, "⠀⠁"+strings.Repeat("⠀", 62), lines[1])
+	}
+}
+
+func TestTerminalKeypadHoldsKeys(t *testing.T) {
+	start := time.Now()
+	km := defaultKeymap()
+	km.assign(0x5, []string{"w", "space", "up"})
+	keypad := newTerminalKeypad(km, 100*time.Millisecond)
+	keypad.now = start
+	keypad.typed('W', start)
+	keypad.typed('!', start)
+	assert.True(t, keypad.IsPressed(0x5))
+	assert.False(t, keypad.IsPressed(0x4))
+	assert.Equal(t, byte(0x5), keypad.keys[' '])
+	assert.Len(t, keypad.keys, 17)
+	keypad.now = start.Add(99 * time.Millisecond)
+	assert.True(t, keypad.IsPressed(0x5))
+	keypad.now = start.Add(100 * time.Millisecond)
+	assert.False(t, keypad.IsPressed(0x5))
+}
+
+type chunkReader []string
+
+func (r *chunkReader) Read(b []byte) (int, error) {
+	if len(*r) == 0 {
+		return 0, io.EOF
+	}
+	n := copy(b, (*r)[0])
+	*r = (*r)[1:]
+	return n, nil
+}
+
+func TestReadKeysDropsEscapeSequences(t *testing.T) {
+	typed := make(chan rune, 16)
+	readKeys(&chunkReader{"q", "\x1b[A", "ws"}, typed)
+	var chars []rune
+	for char := range typed {
+		chars = append(chars, char)
+	}
+	assert.Equal(t, "qws", string(chars))
+}
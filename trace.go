@@ -0,0 +1,68 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"github.com/h4ck3rk3y/go-8/chip8"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// traceFile is a trace being written to a file.
+type traceFile struct {
+	trace *chip8.Trace
+	file  *os.File
+	w     *bufio.Writer
+}
+
+// openTrace creates path for a trace written in format, holding only the
+// last ring instructions until one fails if ring is above zero.
+func openTrace(path string, format chip8.TraceFormat, filter chip8.TraceFilter, ring int) (*traceFile, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	t := &traceFile{file: file, w: bufio.NewWriter(file)}
+	if ring > 0 {
+		t.trace = chip8.NewTraceRing(t.w, format, filter, ring)
+	} else {
+		t.trace = chip8.NewTrace(t.w, format, filter)
+	}
+	return t, nil
+}
+
+// Close finishes writing the trace, returning the first error there was.
+func (t *traceFile) Close() error {
+	err := t.trace.Err()
+	if flushErr := t.w.Flush(); err == nil {
+		err = flushErr
+	}
+	if closeErr := t.file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// parseTraceRange reads the --trace-range flag, two hex addresses such as
+// 200-2FF, into filter.
+func parseTraceRange(s string, filter *chip8.TraceFilter) error {
+	parts := strings.Split(s, "-")
+	if len(parts) != 2 {
+		return fmt.Errorf("bad trace range %q, want two hex addresses such as 200-2FF", s)
+	}
+	var addresses [2]uint16
+	for i, part := range parts {
+		part = strings.TrimPrefix(strings.ToLower(strings.TrimSpace(part)), "0x")
+		address, err := strconv.ParseUint(part, 16, 16)
+		if err != nil {
+			return fmt.Errorf("bad trace range %q, want two hex addresses such as 200-2FF", s)
+		}
+		addresses[i] = uint16(address)
+	}
+	if addresses[0] > addresses[1] {
+		return fmt.Errorf("trace range %q ends before it starts", s)
+	}
+	filter.From, filter.To = addresses[0], addresses[1]
+	return nil
+}
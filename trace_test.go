@@ -0,0 +1,16 @@
+package main
+
+import (
+	"github.com/h4ck3rk3y/go-8/chip8"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestParseTraceRange(t *testing.T) {
+	var filter chip8.TraceFilter
+	assert.NoError(t, parseTraceRange("200-0x2fF", &filter))
+	assert.Equal(t, chip8.TraceFilter{From: 0x200, To: 0x2FF}, filter)
+	for _, bad := range []string{"200", "200-", "2FF-200", "200-300-400", "10000-10001"} {
+		assert.Error(t, parseTraceRange(bad, &filter), bad)
+	}
+}
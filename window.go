@@ -0,0 +1,341 @@
+//go:build !headless
+// +build !headless
+
+package main
+
+import (
+	"github.com/h4ck3rk3y/go-8/chip8"
+	"github.com/h4ck3rk3y/go-8/chip8/gdbstub"
+	"github.com/hajimehoshi/ebiten"
+	"github.com/hajimehoshi/ebiten/audio"
+	"github.com/hajimehoshi/ebiten/audio/mp3"
+	"github.com/hajimehoshi/ebiten/ebitenutil"
+	"github.com/hajimehoshi/ebiten/inpututil"
+	"image/color"
+	"log"
+	"path/filepath"
+)
+
+var (
+	machine  *chip8.Machine
+	settings config
+	// halted is the error that stopped the machine. Once it is set the
+	// window keeps showing the last frame but nothing else is executed.
+	halted error
+	// rewind holds the states of the last few seconds of play.
+	rewind *chip8.Rewind
+	// stub runs the machine instead when gdb can attach to it.
+	stub *gdbstub.Server
+	// movie is the movie being recorded or replayed, if there is one.
+	movie *movieRun
+)
+
+var keyMap map[ebiten.Key]byte
+
+// ebitenKeys names the keys on the keyboard that can be put in a keymap.
+// Escape, Enter, Backspace, Shift and the function keys are left out as
+// go-8 uses them itself.
+var ebitenKeys = map[string]ebiten.Key{
+	"0": ebiten.Key0, "1": ebiten.Key1, "2": ebiten.Key2, "3": ebiten.Key3, "4": ebiten.Key4,
+	"5": ebiten.Key5, "6": ebiten.Key6, "7": ebiten.Key7, "8": ebiten.Key8, "9": ebiten.Key9,
+	"a": ebiten.KeyA, "b": ebiten.KeyB, "c": ebiten.KeyC, "d": ebiten.KeyD, "e": ebiten.KeyE,
+	"f": ebiten.KeyF, "g": ebiten.KeyG, "h": ebiten.KeyH, "i": ebiten.KeyI, "j": ebiten.KeyJ,
+	"k": ebiten.KeyK, "l": ebiten.KeyL, "m": ebiten.KeyM, "n": ebiten.KeyN, "o": ebiten.KeyO,
+	"p": ebiten.KeyP, "q": ebiten.KeyQ, "r": ebiten.KeyR, "s": ebiten.KeyS, "t": ebiten.KeyT,
+	"u": ebiten.KeyU, "v": ebiten.KeyV, "w": ebiten.KeyW, "x": ebiten.KeyX, "y": ebiten.KeyY, "z": ebiten.KeyZ,
+	"up": ebiten.KeyUp, "down": ebiten.KeyDown, "left": ebiten.KeyLeft, "right": ebiten.KeyRight,
+	"space": ebiten.KeySpace, "tab": ebiten.KeyTab, "alt": ebiten.KeyAlt, "control": ebiten.KeyControl,
+	"home": ebiten.KeyHome, "end": ebiten.KeyEnd, "pageup": ebiten.KeyPageUp, "pagedown": ebiten.KeyPageDown,
+	"insert": ebiten.KeyInsert, "delete": ebiten.KeyDelete,
+	"comma": ebiten.KeyComma, "period": ebiten.KeyPeriod, "slash": ebiten.KeySlash,
+	"semicolon": ebiten.KeySemicolon, "apostrophe": ebiten.KeyApostrophe, "minus": ebiten.KeyMinus,
+	"equal": ebiten.KeyEqual, "leftbracket": ebiten.KeyLeftBracket, "rightbracket": ebiten.KeyRightBracket,
+	"backslash": ebiten.KeyBackslash, "graveaccent": ebiten.KeyGraveAccent,
+	"kp0": ebiten.KeyKP0, "kp1": ebiten.KeyKP1, "kp2": ebiten.KeyKP2, "kp3": ebiten.KeyKP3, "kp4": ebiten.KeyKP4,
+	"kp5": ebiten.KeyKP5, "kp6": ebiten.KeyKP6, "kp7": ebiten.KeyKP7, "kp8": ebiten.KeyKP8, "kp9": ebiten.KeyKP9,
+	"kpadd": ebiten.KeyKPAdd, "kpsubtract": ebiten.KeyKPSubtract, "kpmultiply": ebiten.KeyKPMultiply,
+	"kpdivide": ebiten.KeyKPDivide, "kpdecimal": ebiten.KeyKPDecimal, "kpenter": ebiten.KeyKPEnter,
+}
+
+// setupKeys reads the keypad off the keyboard keys and gamepad inputs km
+// names from now on.
+func setupKeys(km keymap) error {
+	err := km.check(func(name string) bool {
+		_, key := ebitenKeys[name]
+		_, pad := parsePadInput(name)
+		return key || pad
+	})
+	if err != nil {
+		return err
+	}
+	keyMap = make(map[ebiten.Key]byte)
+	padMap = make(map[padInput]byte)
+	for name, key := range km.keys() {
+		if input, ok := parsePadInput(name); ok {
+			padMap[input] = key
+		} else {
+			keyMap[ebitenKeys[name]] = key
+		}
+	}
+	return nil
+}
+
+// squares are single pixels in each of the palette's colours, for pixels
+// on neither plane, the first, the second and both.
+var (
+	squares [len(config{}.palette)]*ebiten.Image
+)
+
+func setupSquares(palette [4]color.Color) {
+	for i, c := range palette {
+		squares[i], _ = ebiten.NewImage(1, 1, ebiten.FilterNearest)
+		squares[i].Fill(c)
+	}
+}
+
+// ebitenDisplay keeps hold of the last frame the machine drew so update can
+// put it on the screen.
+type ebitenDisplay struct {
+	frame chip8.Frame
+}
+
+func (d *ebitenDisplay) Draw(frame chip8.Frame) {
+	d.frame = frame
+}
+
+func (d *ebitenDisplay) render(screen *ebiten.Image) {
+	if d.frame.Width == 0 {
+		return
+	}
+	// The window is the same size whatever the resolution, so pixels are
+	// half the size in high resolution mode.
+	size := float64(settings.scale*64) / float64(d.frame.Width)
+	for i := 0; i < d.frame.Height; i++ {
+		for j := 0; j < d.frame.Width; j++ {
+			if pixel := d.frame.At(j, i); pixel != 0x00 {
+
+				opts := &ebiten.DrawImageOptions{}
+
+				opts.GeoM.Scale(size, size)
+				opts.GeoM.Translate(float64(j)*size, float64(i)*size)
+
+				screen.DrawImage(squares[pixel], opts)
+			}
+		}
+	}
+}
+
+// ebitenKeypad reads the keypad off the keyboard using keyMap, and off any
+// gamepads using padMap.
+type ebitenKeypad struct{}
+
+func (ebitenKeypad) IsPressed(key byte) bool {
+	for k, value := range keyMap {
+		if value == key && ebiten.IsKeyPressed(k) {
+			return true
+		}
+	}
+	for input, value := range padMap {
+		if value == key && padPressed(input) {
+			return true
+		}
+	}
+	return false
+}
+
+// ebitenSound plays beep.mp3 for the buzzer, unless the program has given
+// it an XO-CHIP audio pattern to loop over instead.
+type ebitenSound struct {
+	beep          *audio.Player
+	pattern       *patternStream
+	patternPlayer *audio.Player
+	usePattern    bool
+}
+
+func newEbitenSound() (*ebitenSound, error) {
+	audioContext, err := audio.NewContext(sampleRate)
+	if err != nil {
+		return nil, err
+	}
+	f, err := ebitenutil.OpenFile("assets/beep.mp3")
+	if err != nil {
+		return nil, err
+	}
+	d, err := mp3.Decode(audioContext, f)
+	if err != nil {
+		return nil, err
+	}
+	sound := &ebitenSound{pattern: &patternStream{}}
+	if sound.beep, err = audio.NewPlayer(audioContext, d); err != nil {
+		return nil, err
+	}
+	if sound.patternPlayer, err = audio.NewPlayer(audioContext, sound.pattern); err != nil {
+		return nil, err
+	}
+	return sound, nil
+}
+
+func (s *ebitenSound) Beep(on bool) {
+	player := s.beep
+	if s.usePattern {
+		player = s.patternPlayer
+	}
+	if on {
+		player.Rewind()
+		player.Play()
+	} else {
+		player.Pause()
+	}
+}
+
+func (s *ebitenSound) Pattern(pattern [16]byte, rate float64) {
+	s.usePattern = true
+	s.pattern.set(pattern, rate)
+}
+
+var display = &ebitenDisplay{}
+
+func halt(err error) {
+	halted = err
+	log.Printf("machine halted: %v", err)
+	if fault, ok := err.(*chip8.Fault); ok {
+		log.Printf("V=% X I=0x%03X stack=%03X delay=%d sound=%d",
+			fault.State.V, fault.State.I, fault.State.Stack(),
+			fault.State.DelayTimer(), fault.State.SoundTimer())
+	}
+}
+
+// slotKeys load the quick save slots 1 to 8, or save to them with shift
+// held down.
+var slotKeys = [...]ebiten.Key{
+	ebiten.KeyF1, ebiten.KeyF2, ebiten.KeyF3, ebiten.KeyF4,
+	ebiten.KeyF5, ebiten.KeyF6, ebiten.KeyF7, ebiten.KeyF8,
+}
+
+func handleSlots() {
+	for i, key := range slotKeys {
+		if !inpututil.IsKeyJustPressed(key) {
+			continue
+		}
+		slot := i + 1
+		if ebiten.IsKeyPressed(ebiten.KeyShift) {
+			if err := saveSlot(machine, settings.rom, slot); err != nil {
+				log.Printf("can't save to slot %d: %v", slot, err)
+			} else {
+				log.Printf("saved to slot %d", slot)
+			}
+			continue
+		}
+		if movie != nil {
+			// A movie has to be played from the start to the end.
+			log.Printf("can't load slot %d while there's a movie", slot)
+			continue
+		}
+		if err := loadSlot(machine, settings.rom, slot); err != nil {
+			log.Printf("can't load slot %d: %v", slot, err)
+			continue
+		}
+		// A machine that had halted can carry on from the save.
+		halted = nil
+		log.Printf("loaded slot %d", slot)
+	}
+}
+
+// runFrame runs the machine for a frame, through the gdb stub if there is
+// one or the movie if there's one of those.
+func runFrame() error {
+	if stub != nil {
+		return stub.RunFrame(settings.ipf)
+	}
+	if movie != nil {
+		return movie.runFrame(machine, ebitenKeypad{})
+	}
+	_, err := machine.RunFrame(settings.ipf)
+	return err
+}
+
+func update(screen *ebiten.Image) error {
+
+	// fill screen
+	screen.Fill(settings.palette[0])
+
+	updateGamepads()
+	if updateRemap() {
+		renderRemap(screen)
+		return nil
+	}
+	handleSlots()
+	togglePanel()
+	if ebiten.IsKeyPressed(ebiten.KeyBackspace) {
+		// Go back a frame for every frame backspace is held down for.
+		ok, err := rewind.Back(machine)
+		if err != nil {
+			log.Printf("can't rewind: %v", err)
+		}
+		if ok {
+			halted = nil
+			if movie != nil {
+				movie.back()
+			}
+		}
+	} else if halted == nil && !movie.done() {
+		if err := rewind.Record(machine); err != nil {
+			log.Printf("can't record the state for rewinding: %v", err)
+		}
+		if err := runFrame(); err != nil {
+			halt(err)
+		}
+		if movie.done() {
+			message, _ := checkReplay(movie.movie, machine)
+			log.Printf("replay finished: %s", message)
+		}
+	}
+
+	display.render(screen)
+	renderPanel(screen)
+
+	return nil
+}
+
+// runWindow plays the ROM c asks for in a window until it's closed.
+func runWindow(c config) error {
+	settings = c
+	options := append(c.machineOptions(), chip8.WithDisplay(display))
+	if c.replay == nil {
+		options = append(options, chip8.WithKeypad(ebitenKeypad{}))
+	}
+	if c.audio {
+		sound, err := newEbitenSound()
+		if err != nil {
+			log.Printf("playing without sound: %v", err)
+		} else {
+			options = append(options, chip8.WithSound(sound))
+		}
+	}
+	if err := setupKeys(c.keymap); err != nil {
+		return err
+	}
+	setupSquares(c.palette)
+	machine = chip8.New(options...)
+	rewind = chip8.NewRewind(c.rewind * 60)
+	if err := loadROM(machine, c.rom); err != nil {
+		return err
+	}
+	if c.gdb != "" {
+		var err error
+		if stub, _, err = serveGDB(machine, c.gdb, c.ipf); err != nil {
+			return err
+		}
+	}
+	var err error
+	if movie, err = startMovie(c, machine); err != nil {
+		return err
+	}
+	if err := ebiten.Run(update, 64*c.scale, 32*c.scale, 1, filepath.Base(c.rom)); err != nil {
+		return err
+	}
+	if movie != nil {
+		return movie.finish(machine)
+	}
+	return nil
+}
@@ -0,0 +1,11 @@
+//go:build headless
+// +build headless
+
+package main
+
+import "errors"
+
+// runWindow can't open a window in a headless build.
+func runWindow(c config) error {
+	return errors.New("this go-8 was built without a window, run it with --headless")
+}